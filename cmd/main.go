@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/Kry0z1/fancytasks/internal/handlers"
 	"github.com/Kry0z1/fancytasks/internal/middleware"
 	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	"github.com/Kry0z1/fancytasks/internal/scheduler"
+	"github.com/Kry0z1/fancytasks/internal/stream"
+	"github.com/Kry0z1/fancytasks/internal/webhooks"
 	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/blob"
+	"github.com/Kry0z1/fancytasks/pkg/notify"
 )
 
 // TODO:
@@ -22,12 +30,124 @@ func main() {
 	}
 	h := tasks.NewHasher()
 
+	notifier := notify.Router{
+		tasks.ChannelLog:     notify.NewLogNotifier(),
+		tasks.ChannelWebhook: notify.NewWebhookNotifier(5 * time.Second),
+		tasks.ChannelEmail: notify.NewSMTPNotifier(
+			tasks.Cfg.SMTP.Host, tasks.Cfg.SMTP.Port, tasks.Cfg.SMTP.From,
+			os.Getenv("SMTP_USER"), os.Getenv("SMTP_PASS"),
+		),
+	}
+	go scheduler.RunReminders(context.Background(), notifier, tasks.Cfg.Reminders)
+	go webhooks.NewDispatcher(tasks.Cfg.Webhooks).Run(context.Background())
+
+	hub := stream.NewHub()
+	go hub.Run(context.Background(), tasks.Cfg.Stream.GetRetention())
+
+	store, err := newBlobStore(tasks.Cfg.Attachments)
+	if err != nil {
+		log.Fatalf("Couldn't create blob store: %s", err.Error())
+	}
+	go scheduler.RunBlobCleanup(
+		context.Background(), store, tasks.Cfg.Attachments.GetCleanupInterval(),
+		tasks.Cfg.Attachments.GetGrace(), tasks.Cfg.Attachments.Batch,
+	)
+	go scheduler.RunTrashPurge(
+		context.Background(), tasks.Cfg.Trash.GetPurgeInterval(),
+		tasks.Cfg.Trash.GetRetention(), tasks.Cfg.Trash.Batch,
+	)
+
 	http.Handle("POST /register", middleware.LoggerErrorFunc(handlers.Register(h)))
 	http.Handle("POST /login", middleware.LoggerErrorFunc(handlers.LoginForToken(t, h)))
 	http.Handle("GET /tasks", middleware.LoggerAuthErrorFunc(handlers.Me, t))
+	http.Handle("GET /tasks/search", middleware.LoggerAuthErrorFunc(handlers.SearchTasks, t))
 	http.Handle("POST /tasks/create", middleware.LoggerAuthErrorFunc(handlers.CreateTask, t))
+	http.Handle("POST /tasks/quick", middleware.LoggerAuthErrorFunc(handlers.QuickAddTask, t))
 	http.Handle("PUT /tasks/update", middleware.LoggerAuthErrorFunc(handlers.UpdateTask, t))
 	http.Handle("DELETE /tasks/delete", middleware.LoggerAuthErrorFunc(handlers.DeleteTask, t))
+	http.Handle("GET /tasks/next", middleware.LoggerAuthErrorFunc(handlers.NextTasks, t))
+	http.Handle("GET /tasks/assigned", middleware.LoggerAuthErrorFunc(handlers.AssignedTasks, t))
+	http.Handle("GET /settings", middleware.LoggerAuthErrorFunc(handlers.GetSettings, t))
+	http.Handle("PUT /settings", middleware.LoggerAuthErrorFunc(handlers.UpdateSettings, t))
+	http.Handle("GET /tasks/{kind}/{id}/children", middleware.LoggerAuthErrorFunc(handlers.TaskChildren, t))
+	http.Handle("GET /topics", middleware.LoggerAuthErrorFunc(handlers.GetTopics, t))
+	http.Handle("POST /topics/create", middleware.LoggerAuthErrorFunc(handlers.CreateTopic, t))
+	http.Handle("PUT /topics/{id}", middleware.LoggerAuthErrorFunc(handlers.UpdateTopic, t))
+	http.Handle("GET /tags", middleware.LoggerAuthErrorFunc(handlers.GetTags, t))
+	http.Handle("GET /tags/autocomplete", middleware.LoggerAuthErrorFunc(handlers.AutocompleteTags, t))
+	http.Handle("PUT /tags/{id}", middleware.LoggerAuthErrorFunc(handlers.RenameTag, t))
+	http.Handle("POST /tags/{id}/merge", middleware.LoggerAuthErrorFunc(handlers.MergeTag, t))
+	http.Handle("GET /workflows", middleware.LoggerAuthErrorFunc(handlers.GetWorkflows, t))
+	http.Handle("PUT /workflows", middleware.LoggerAuthErrorFunc(handlers.SaveWorkflow, t))
+	http.Handle("DELETE /workflows", middleware.LoggerAuthErrorFunc(handlers.DeleteWorkflow, t))
+	http.Handle("GET /tasks/{kind}/{id}/transitions", middleware.LoggerAuthErrorFunc(handlers.TaskTransitions, t))
+	http.Handle("GET /tasks/{kind}/{id}/history", middleware.LoggerAuthErrorFunc(handlers.TaskHistory, t))
+	http.Handle("POST /tasks/{kind}/{id}/history/{version}/revert", middleware.LoggerAuthErrorFunc(handlers.RevertTask, t))
+	http.Handle("GET /trash", middleware.LoggerAuthErrorFunc(handlers.GetTrash, t))
+	http.Handle("POST /trash/{kind}/{id}/restore", middleware.LoggerAuthErrorFunc(handlers.RestoreTask, t))
+	http.Handle("DELETE /trash/{kind}/{id}", middleware.LoggerAuthErrorFunc(handlers.PurgeTask, t))
+	http.Handle("PUT /tasks/{kind}/{id}/assignee", middleware.LoggerAuthErrorFunc(handlers.AssignTask, t))
+	http.Handle("DELETE /tasks/{kind}/{id}/assignee", middleware.LoggerAuthErrorFunc(handlers.UnassignTask, t))
+	http.Handle("GET /tasks/{kind}/{id}/comments", middleware.LoggerAuthErrorFunc(handlers.TaskComments, t))
+	http.Handle("POST /tasks/{kind}/{id}/comments", middleware.LoggerAuthErrorFunc(handlers.CreateComment, t))
+	http.Handle("GET /tasks/{kind}/{id}/checklist", middleware.LoggerAuthErrorFunc(handlers.TaskChecklist, t))
+	http.Handle("POST /tasks/{kind}/{id}/checklist", middleware.LoggerAuthErrorFunc(handlers.CreateChecklistItem, t))
+	http.Handle("PUT /tasks/{kind}/{id}/checklist", middleware.LoggerAuthErrorFunc(handlers.UpdateChecklist, t))
+	http.Handle("PUT /checklist/{id}", middleware.LoggerAuthErrorFunc(handlers.UpdateChecklistItem, t))
+	http.Handle("POST /checklist/{id}/check", middleware.LoggerAuthErrorFunc(handlers.CheckChecklistItem, t))
+	http.Handle("POST /checklist/{id}/uncheck", middleware.LoggerAuthErrorFunc(handlers.UncheckChecklistItem, t))
+	http.Handle("DELETE /checklist/{id}", middleware.LoggerAuthErrorFunc(handlers.DeleteChecklistItem, t))
+	http.Handle("GET /tasks/{kind}/{id}/attachments", middleware.LoggerAuthErrorFunc(handlers.TaskAttachments, t))
+	http.Handle("POST /tasks/{kind}/{id}/attachments", middleware.LoggerAuthErrorFunc(handlers.UploadAttachment(store), t))
+	http.Handle("GET /attachments/usage", middleware.LoggerAuthErrorFunc(handlers.AttachmentUsage, t))
+	http.Handle("GET /attachments/{id}", middleware.LoggerAuthErrorFunc(handlers.DownloadAttachment(store), t))
+	http.Handle("DELETE /attachments/{id}", middleware.LoggerAuthErrorFunc(handlers.DeleteAttachment, t))
+	http.Handle("PUT /comments/{id}", middleware.LoggerAuthErrorFunc(handlers.UpdateComment, t))
+	http.Handle("DELETE /comments/{id}", middleware.LoggerAuthErrorFunc(handlers.DeleteComment, t))
+	http.Handle("GET /comments/{id}/edits", middleware.LoggerAuthErrorFunc(handlers.CommentEdits, t))
+	http.Handle("GET /templates", middleware.LoggerAuthErrorFunc(handlers.GetTemplates, t))
+	http.Handle("POST /templates/create", middleware.LoggerAuthErrorFunc(handlers.CreateTemplate, t))
+	http.Handle("GET /templates/{id}", middleware.LoggerAuthErrorFunc(handlers.GetTemplate, t))
+	http.Handle("PUT /templates/{id}", middleware.LoggerAuthErrorFunc(handlers.UpdateTemplate, t))
+	http.Handle("DELETE /templates/{id}", middleware.LoggerAuthErrorFunc(handlers.DeleteTemplate, t))
+	http.Handle("POST /templates/{id}/instantiate", middleware.LoggerAuthErrorFunc(handlers.InstantiateTemplate, t))
+	http.Handle("GET /board", middleware.LoggerAuthErrorFunc(handlers.Board, t))
+	http.Handle("GET /shares", middleware.LoggerAuthErrorFunc(handlers.GetShares, t))
+	http.Handle("GET /shared", middleware.LoggerAuthErrorFunc(handlers.SharedWithMe, t))
+	http.Handle("POST /shares/create", middleware.LoggerAuthErrorFunc(handlers.CreateShare, t))
+	http.Handle("PUT /shares/{id}", middleware.LoggerAuthErrorFunc(handlers.UpdateShare, t))
+	http.Handle("DELETE /shares/{id}", middleware.LoggerAuthErrorFunc(handlers.DeleteShare, t))
+	http.Handle("GET /workspaces", middleware.LoggerAuthErrorFunc(handlers.GetWorkspaces, t))
+	http.Handle("POST /workspaces/create", middleware.LoggerAuthErrorFunc(handlers.CreateWorkspace, t))
+	http.Handle("PUT /workspaces/{id}", middleware.LoggerAuthErrorFunc(handlers.RenameWorkspace, t))
+	http.Handle("GET /workspaces/{id}/audit", middleware.LoggerAuthErrorFunc(handlers.WorkspaceAudit, t))
+	http.Handle("GET /workspaces/{id}/members", middleware.LoggerAuthErrorFunc(handlers.GetWorkspaceMembers, t))
+	http.Handle("POST /workspaces/{id}/members", middleware.LoggerAuthErrorFunc(handlers.AddWorkspaceMember, t))
+	http.Handle("PUT /workspaces/{id}/members/{username}", middleware.LoggerAuthErrorFunc(handlers.UpdateWorkspaceMember, t))
+	http.Handle("DELETE /workspaces/{id}/members/{username}", middleware.LoggerAuthErrorFunc(handlers.RemoveWorkspaceMember, t))
+	http.Handle("GET /dependencies", middleware.LoggerAuthErrorFunc(handlers.GetDependencies, t))
+	http.Handle("POST /dependencies/create", middleware.LoggerAuthErrorFunc(handlers.CreateDependency, t))
+	http.Handle("DELETE /dependencies", middleware.LoggerAuthErrorFunc(handlers.DeleteDependency, t))
+	http.Handle("GET /plan", middleware.LoggerAuthErrorFunc(handlers.Plan, t))
+	http.Handle("GET /plan/actionable", middleware.LoggerAuthErrorFunc(handlers.ActionableTasks, t))
+	http.Handle("GET /plan/critical-path", middleware.LoggerAuthErrorFunc(handlers.CriticalPath, t))
+	http.Handle("GET /agenda", middleware.LoggerAuthErrorFunc(handlers.Agenda, t))
+	http.Handle("GET /events/conflicts", middleware.LoggerAuthErrorFunc(handlers.EventConflicts, t))
+	http.Handle("GET /freebusy", middleware.LoggerAuthErrorFunc(handlers.FreeBusy, t))
+	http.Handle("GET /freebusy/grants", middleware.LoggerAuthErrorFunc(handlers.GetFreeBusyGrants, t))
+	http.Handle("POST /freebusy/grants", middleware.LoggerAuthErrorFunc(handlers.GrantFreeBusy, t))
+	http.Handle("DELETE /freebusy/grants", middleware.LoggerAuthErrorFunc(handlers.RevokeFreeBusy, t))
+	http.Handle("GET /reminders", middleware.LoggerAuthErrorFunc(handlers.GetReminders, t))
+	http.Handle("POST /reminders/create", middleware.LoggerAuthErrorFunc(handlers.CreateReminder, t))
+	http.Handle("POST /reminders/{id}/snooze", middleware.LoggerAuthErrorFunc(handlers.SnoozeReminder, t))
+	http.Handle("POST /reminders/{id}/dismiss", middleware.LoggerAuthErrorFunc(handlers.DismissReminder, t))
+	http.Handle("GET /webhooks", middleware.LoggerAuthErrorFunc(handlers.GetWebhooks, t))
+	http.Handle("POST /webhooks/create", middleware.LoggerAuthErrorFunc(handlers.CreateWebhook, t))
+	http.Handle("DELETE /webhooks/{id}", middleware.LoggerAuthErrorFunc(handlers.DeleteWebhook, t))
+	http.Handle("GET /webhooks/{id}/deliveries", middleware.LoggerAuthErrorFunc(handlers.GetWebhookDeliveries, t))
+	http.Handle("POST /webhooks/{id}/test", middleware.LoggerAuthErrorFunc(handlers.TestWebhook, t))
+	http.Handle("GET /stream", middleware.LoggerAuthErrorFunc(handlers.Stream(hub), t))
+	http.Handle("GET /stream/ws", middleware.LoggerAuthErrorFunc(handlers.StreamWebSocket(hub), t))
 	http.Handle("GET /secret", middleware.LoggerAuthErrorFunc(func(w http.ResponseWriter, r *http.Request) error {
 		w.Write([]byte("ok"))
 		return nil
@@ -36,3 +156,20 @@ func main() {
 	fmt.Println("Listening on :8000")
 	log.Fatal(http.ListenAndServe(":8000", nil))
 }
+
+func newBlobStore(cfg tasks.AttachmentsConfig) (blob.BlobStore, error) {
+	switch cfg.Store {
+	case "local":
+		return blob.NewLocalStore(cfg.Local.Root)
+	case "s3":
+		return blob.NewS3Store(blob.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown store %q", cfg.Store)
+	}
+}
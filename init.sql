@@ -54,3 +54,891 @@ CREATE TABLE IF NOT EXISTS repeating_tasks(
 
     FOREIGN KEY (owner) REFERENCES users(username)
 );
+
+CREATE INDEX IF NOT EXISTS base_tasks_owner_title_idx ON base_tasks(owner, title, id);
+
+CREATE INDEX IF NOT EXISTS events_owner_starts_at_idx ON events(owner, starts_at, id);
+CREATE INDEX IF NOT EXISTS events_owner_title_idx ON events(owner, title, id);
+
+CREATE INDEX IF NOT EXISTS tasks_with_deadline_owner_deadline_idx ON tasks_with_deadline(owner, deadline, id);
+CREATE INDEX IF NOT EXISTS tasks_with_deadline_owner_title_idx ON tasks_with_deadline(owner, title, id);
+
+CREATE INDEX IF NOT EXISTS repeating_tasks_owner_starts_at_idx ON repeating_tasks(owner, starts_at, id);
+CREATE INDEX IF NOT EXISTS repeating_tasks_owner_title_idx ON repeating_tasks(owner, title, id);
+
+ALTER TABLE base_tasks ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') ||
+    setweight(to_tsvector('simple', topic), 'B') ||
+    setweight(to_tsvector('simple', description), 'C')
+) STORED;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') ||
+    setweight(to_tsvector('simple', topic), 'B') ||
+    setweight(to_tsvector('simple', description), 'C')
+) STORED;
+ALTER TABLE tasks_with_deadline ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') ||
+    setweight(to_tsvector('simple', topic), 'B') ||
+    setweight(to_tsvector('simple', description), 'C')
+) STORED;
+ALTER TABLE repeating_tasks ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') ||
+    setweight(to_tsvector('simple', topic), 'B') ||
+    setweight(to_tsvector('simple', description), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS base_tasks_search_idx ON base_tasks USING GIN(search_vector);
+CREATE INDEX IF NOT EXISTS events_search_idx ON events USING GIN(search_vector);
+CREATE INDEX IF NOT EXISTS tasks_with_deadline_search_idx ON tasks_with_deadline USING GIN(search_vector);
+CREATE INDEX IF NOT EXISTS repeating_tasks_search_idx ON repeating_tasks USING GIN(search_vector);
+
+CREATE TABLE IF NOT EXISTS freebusy_grants(
+    owner VARCHAR(128) NOT NULL,
+    grantee VARCHAR(128) NOT NULL,
+
+    PRIMARY KEY (owner, grantee),
+    FOREIGN KEY (owner) REFERENCES users(username),
+    FOREIGN KEY (grantee) REFERENCES users(username)
+);
+
+CREATE TABLE IF NOT EXISTS reminders(
+    id SERIAL PRIMARY KEY,
+    owner VARCHAR(128) NOT NULL,
+    task_kind VARCHAR(16) NOT NULL,
+    task_id INTEGER NOT NULL,
+    occurrence BIGINT,
+    remind_at TIMESTAMP NOT NULL,
+    offset_seconds BIGINT,
+    recurring BOOLEAN NOT NULL,
+    channel VARCHAR(16) NOT NULL,
+    target VARCHAR(512) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    fired_at TIMESTAMP,
+
+    FOREIGN KEY (owner) REFERENCES users(username)
+);
+
+CREATE INDEX IF NOT EXISTS reminders_due_idx ON reminders(remind_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS reminders_owner_idx ON reminders(owner, remind_at);
+
+CREATE TABLE IF NOT EXISTS webhooks(
+    id SERIAL PRIMARY KEY,
+    owner VARCHAR(128) NOT NULL,
+    url VARCHAR(512) NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    events VARCHAR(32)[] NOT NULL,
+    active BOOLEAN NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+
+    FOREIGN KEY (owner) REFERENCES users(username)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries(
+    id SERIAL PRIMARY KEY,
+    webhook_id INTEGER NOT NULL,
+    event VARCHAR(32) NOT NULL,
+    payload TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    attempts INTEGER NOT NULL,
+    next_attempt_at TIMESTAMP NOT NULL,
+    response_code INTEGER,
+    error VARCHAR(512),
+    created_at TIMESTAMP NOT NULL,
+    delivered_at TIMESTAMP,
+
+    FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webhooks_owner_idx ON webhooks(owner);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhook_deliveries(webhook_id, id);
+
+CREATE TABLE IF NOT EXISTS task_events(
+    id BIGSERIAL PRIMARY KEY,
+    owner VARCHAR(128) NOT NULL,
+    event VARCHAR(32) NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+
+    FOREIGN KEY (owner) REFERENCES users(username)
+);
+
+CREATE INDEX IF NOT EXISTS task_events_owner_idx ON task_events(owner, id);
+CREATE INDEX IF NOT EXISTS task_events_created_at_idx ON task_events(created_at);
+
+ALTER TABLE base_tasks ADD COLUMN IF NOT EXISTS parent_kind VARCHAR(16);
+ALTER TABLE base_tasks ADD COLUMN IF NOT EXISTS parent_id INTEGER;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS parent_kind VARCHAR(16);
+ALTER TABLE events ADD COLUMN IF NOT EXISTS parent_id INTEGER;
+ALTER TABLE tasks_with_deadline ADD COLUMN IF NOT EXISTS parent_kind VARCHAR(16);
+ALTER TABLE tasks_with_deadline ADD COLUMN IF NOT EXISTS parent_id INTEGER;
+ALTER TABLE repeating_tasks ADD COLUMN IF NOT EXISTS parent_kind VARCHAR(16);
+ALTER TABLE repeating_tasks ADD COLUMN IF NOT EXISTS parent_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS base_tasks_parent_idx ON base_tasks(parent_kind, parent_id);
+CREATE INDEX IF NOT EXISTS events_parent_idx ON events(parent_kind, parent_id);
+CREATE INDEX IF NOT EXISTS tasks_with_deadline_parent_idx ON tasks_with_deadline(parent_kind, parent_id);
+CREATE INDEX IF NOT EXISTS repeating_tasks_parent_idx ON repeating_tasks(parent_kind, parent_id);
+
+-- Common columns of tasks of every kind, used to walk hierarchies
+CREATE OR REPLACE VIEW all_tasks AS
+    SELECT 'basetask' AS kind, id, title, done, owner, topic, parent_kind, parent_id FROM base_tasks
+    UNION ALL
+    SELECT 'event', id, title, done, owner, topic, parent_kind, parent_id FROM events
+    UNION ALL
+    SELECT 'deadline', id, title, done, owner, topic, parent_kind, parent_id FROM tasks_with_deadline
+    UNION ALL
+    SELECT 'repeat', id, title, done, owner, topic, parent_kind, parent_id FROM repeating_tasks;
+
+CREATE TABLE IF NOT EXISTS task_dependencies(
+    owner VARCHAR(128) NOT NULL,
+    blocker_kind VARCHAR(16) NOT NULL,
+    blocker_id INTEGER NOT NULL,
+    blocked_kind VARCHAR(16) NOT NULL,
+    blocked_id INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (blocker_kind, blocker_id, blocked_kind, blocked_id),
+    FOREIGN KEY (owner) REFERENCES users(username)
+);
+
+CREATE INDEX IF NOT EXISTS task_dependencies_blocked_idx ON task_dependencies(blocked_kind, blocked_id);
+CREATE INDEX IF NOT EXISTS task_dependencies_owner_idx ON task_dependencies(owner);
+
+-- Removes rows referencing deleted task, kind of task is passed as trigger argument
+CREATE OR REPLACE FUNCTION delete_task_references() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM task_dependencies
+        WHERE (blocker_kind = TG_ARGV[0] AND blocker_id = OLD.id)
+           OR (blocked_kind = TG_ARGV[0] AND blocked_id = OLD.id);
+    DELETE FROM reminders WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER base_tasks_delete_references AFTER DELETE ON base_tasks
+    FOR EACH ROW EXECUTE FUNCTION delete_task_references('basetask');
+CREATE OR REPLACE TRIGGER events_delete_references AFTER DELETE ON events
+    FOR EACH ROW EXECUTE FUNCTION delete_task_references('event');
+CREATE OR REPLACE TRIGGER tasks_with_deadline_delete_references AFTER DELETE ON tasks_with_deadline
+    FOR EACH ROW EXECUTE FUNCTION delete_task_references('deadline');
+CREATE OR REPLACE TRIGGER repeating_tasks_delete_references AFTER DELETE ON repeating_tasks
+    FOR EACH ROW EXECUTE FUNCTION delete_task_references('repeat');
+
+CREATE TABLE IF NOT EXISTS topics(
+    id SERIAL PRIMARY KEY,
+    owner VARCHAR(128) NOT NULL,
+    name VARCHAR(128) NOT NULL,
+    color VARCHAR(16) NOT NULL DEFAULT '',
+    icon VARCHAR(64) NOT NULL DEFAULT '',
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    UNIQUE (owner, name),
+    FOREIGN KEY (owner) REFERENCES users(username)
+);
+
+-- Topics used to be free text, every distinct name becomes topic of its owner.
+-- Skipped once topics belong to workspaces, every task has topic by then
+INSERT INTO topics(owner, name)
+    SELECT DISTINCT owner, topic FROM all_tasks
+    WHERE NOT EXISTS (
+        SELECT 1 FROM information_schema.columns WHERE table_name = 'topics' AND column_name = 'workspace_id'
+    )
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE base_tasks ADD COLUMN IF NOT EXISTS topic_id INTEGER REFERENCES topics(id);
+ALTER TABLE events ADD COLUMN IF NOT EXISTS topic_id INTEGER REFERENCES topics(id);
+ALTER TABLE tasks_with_deadline ADD COLUMN IF NOT EXISTS topic_id INTEGER REFERENCES topics(id);
+ALTER TABLE repeating_tasks ADD COLUMN IF NOT EXISTS topic_id INTEGER REFERENCES topics(id);
+
+UPDATE base_tasks t SET topic_id = p.id FROM topics p
+    WHERE t.topic_id IS NULL AND p.owner = t.owner AND p.name = t.topic;
+UPDATE events t SET topic_id = p.id FROM topics p
+    WHERE t.topic_id IS NULL AND p.owner = t.owner AND p.name = t.topic;
+UPDATE tasks_with_deadline t SET topic_id = p.id FROM topics p
+    WHERE t.topic_id IS NULL AND p.owner = t.owner AND p.name = t.topic;
+UPDATE repeating_tasks t SET topic_id = p.id FROM topics p
+    WHERE t.topic_id IS NULL AND p.owner = t.owner AND p.name = t.topic;
+
+ALTER TABLE base_tasks ALTER COLUMN topic_id SET NOT NULL;
+ALTER TABLE events ALTER COLUMN topic_id SET NOT NULL;
+ALTER TABLE tasks_with_deadline ALTER COLUMN topic_id SET NOT NULL;
+ALTER TABLE repeating_tasks ALTER COLUMN topic_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS base_tasks_topic_idx ON base_tasks(topic_id);
+CREATE INDEX IF NOT EXISTS events_topic_idx ON events(topic_id);
+CREATE INDEX IF NOT EXISTS tasks_with_deadline_topic_idx ON tasks_with_deadline(topic_id);
+CREATE INDEX IF NOT EXISTS repeating_tasks_topic_idx ON repeating_tasks(topic_id);
+
+-- Tasks are overdue when they are not done after due_at
+CREATE OR REPLACE VIEW all_tasks AS
+    SELECT 'basetask' AS kind, id, title, done, owner, topic, parent_kind, parent_id,
+        topic_id, NULL::TIMESTAMP AS due_at FROM base_tasks
+    UNION ALL
+    SELECT 'event', id, title, done, owner, topic, parent_kind, parent_id,
+        topic_id, ends_at FROM events
+    UNION ALL
+    SELECT 'deadline', id, title, done, owner, topic, parent_kind, parent_id,
+        topic_id, deadline FROM tasks_with_deadline
+    UNION ALL
+    SELECT 'repeat', id, title, done, owner, topic, parent_kind, parent_id,
+        topic_id, NULL::TIMESTAMP FROM repeating_tasks;
+
+ALTER TABLE topics ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES topics(id);
+
+CREATE INDEX IF NOT EXISTS topics_parent_idx ON topics(parent_id);
+
+CREATE TABLE IF NOT EXISTS tags(
+    id SERIAL PRIMARY KEY,
+    owner VARCHAR(128) NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    UNIQUE (owner, name),
+    FOREIGN KEY (owner) REFERENCES users(username)
+);
+
+CREATE TABLE IF NOT EXISTS task_tags(
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    task_kind VARCHAR(16) NOT NULL,
+    task_id INTEGER NOT NULL,
+
+    PRIMARY KEY (tag_id, task_kind, task_id)
+);
+
+CREATE INDEX IF NOT EXISTS task_tags_task_idx ON task_tags(task_kind, task_id);
+
+CREATE OR REPLACE FUNCTION delete_task_references() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM task_dependencies
+        WHERE (blocker_kind = TG_ARGV[0] AND blocker_id = OLD.id)
+           OR (blocked_kind = TG_ARGV[0] AND blocked_id = OLD.id);
+    DELETE FROM reminders WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM task_tags WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE base_tasks ADD COLUMN IF NOT EXISTS priority SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE base_tasks ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE events ADD COLUMN IF NOT EXISTS priority SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE tasks_with_deadline ADD COLUMN IF NOT EXISTS priority SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE tasks_with_deadline ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE repeating_tasks ADD COLUMN IF NOT EXISTS priority SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE repeating_tasks ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+CREATE TABLE IF NOT EXISTS user_settings(
+    username VARCHAR(128) PRIMARY KEY REFERENCES users(username),
+    settings JSONB NOT NULL
+);
+
+ALTER TABLE base_tasks ADD COLUMN IF NOT EXISTS status VARCHAR(32) NOT NULL DEFAULT 'backlog';
+ALTER TABLE base_tasks ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE events ADD COLUMN IF NOT EXISTS status VARCHAR(32) NOT NULL DEFAULT 'backlog';
+ALTER TABLE events ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE tasks_with_deadline ADD COLUMN IF NOT EXISTS status VARCHAR(32) NOT NULL DEFAULT 'backlog';
+ALTER TABLE tasks_with_deadline ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE repeating_tasks ADD COLUMN IF NOT EXISTS status VARCHAR(32) NOT NULL DEFAULT 'backlog';
+ALTER TABLE repeating_tasks ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+-- Tasks completed before statuses existed
+UPDATE base_tasks SET status = 'done' WHERE done AND status = 'backlog';
+UPDATE events SET status = 'done' WHERE done AND status = 'backlog';
+UPDATE tasks_with_deadline SET status = 'done' WHERE done AND status = 'backlog';
+UPDATE repeating_tasks SET status = 'done' WHERE done AND status = 'backlog';
+
+-- Workflow of topic or, if topic_id is NULL, default workflow of owner
+CREATE TABLE IF NOT EXISTS workflows(
+    id SERIAL PRIMARY KEY,
+    owner VARCHAR(128) NOT NULL,
+    topic_id INTEGER REFERENCES topics(id) ON DELETE CASCADE,
+    states JSONB NOT NULL,
+    transitions JSONB NOT NULL,
+
+    FOREIGN KEY (owner) REFERENCES users(username)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS workflows_owner_topic_idx ON workflows(owner, COALESCE(topic_id, 0));
+
+CREATE TABLE IF NOT EXISTS task_transitions(
+    id SERIAL PRIMARY KEY,
+    task_kind VARCHAR(16) NOT NULL,
+    task_id INTEGER NOT NULL,
+    -- NULL for the status task was created with
+    from_status VARCHAR(32),
+    to_status VARCHAR(32) NOT NULL,
+    at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS task_transitions_task_idx ON task_transitions(task_kind, task_id);
+
+CREATE OR REPLACE FUNCTION record_task_transition() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' OR OLD.status <> NEW.status THEN
+        IF TG_OP = 'UPDATE' AND NEW.status_changed_at = OLD.status_changed_at THEN
+            NEW.status_changed_at := NOW();
+        END IF;
+        INSERT INTO task_transitions(task_kind, task_id, from_status, to_status, at)
+            VALUES (TG_ARGV[0], NEW.id, CASE WHEN TG_OP = 'UPDATE' THEN OLD.status END, NEW.status, NEW.status_changed_at);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER base_tasks_record_transition BEFORE INSERT OR UPDATE OF status ON base_tasks
+    FOR EACH ROW EXECUTE FUNCTION record_task_transition('basetask');
+CREATE OR REPLACE TRIGGER events_record_transition BEFORE INSERT OR UPDATE OF status ON events
+    FOR EACH ROW EXECUTE FUNCTION record_task_transition('event');
+CREATE OR REPLACE TRIGGER tasks_with_deadline_record_transition BEFORE INSERT OR UPDATE OF status ON tasks_with_deadline
+    FOR EACH ROW EXECUTE FUNCTION record_task_transition('deadline');
+CREATE OR REPLACE TRIGGER repeating_tasks_record_transition BEFORE INSERT OR UPDATE OF status ON repeating_tasks
+    FOR EACH ROW EXECUTE FUNCTION record_task_transition('repeat');
+
+CREATE OR REPLACE FUNCTION delete_task_references() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM task_dependencies
+        WHERE (blocker_kind = TG_ARGV[0] AND blocker_id = OLD.id)
+           OR (blocked_kind = TG_ARGV[0] AND blocked_id = OLD.id);
+    DELETE FROM reminders WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM task_tags WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM task_transitions WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Access of grantee to a task or a whole topic subtree of owner
+CREATE TABLE IF NOT EXISTS acl(
+    id SERIAL PRIMARY KEY,
+    owner VARCHAR(128) NOT NULL,
+    grantee VARCHAR(128) NOT NULL,
+    task_kind VARCHAR(16),
+    task_id INTEGER,
+    topic_id INTEGER REFERENCES topics(id) ON DELETE CASCADE,
+    level VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    CHECK ((task_id IS NULL) <> (topic_id IS NULL)),
+    FOREIGN KEY (owner) REFERENCES users(username),
+    FOREIGN KEY (grantee) REFERENCES users(username)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS acl_task_idx ON acl(grantee, task_kind, task_id) WHERE task_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS acl_topic_idx ON acl(grantee, topic_id) WHERE topic_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS acl_owner_idx ON acl(owner);
+
+CREATE OR REPLACE FUNCTION acl_rank(VARCHAR) RETURNS SMALLINT AS $$
+    SELECT (CASE $1 WHEN 'admin' THEN 3 WHEN 'editor' THEN 2 WHEN 'viewer' THEN 1 END)::SMALLINT
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- Rank of access of user $1 to topic $2 through shares of the topic or its ancestors:
+-- 4 owner, 3 admin, 2 editor, 1 viewer, NULL none
+CREATE OR REPLACE FUNCTION topic_access(VARCHAR, INTEGER) RETURNS SMALLINT AS $$
+    WITH RECURSIVE ancestors(id) AS (
+        SELECT $2
+        UNION
+        SELECT t.parent_id FROM topics t JOIN ancestors a ON t.id = a.id WHERE t.parent_id IS NOT NULL
+    )
+    SELECT (CASE WHEN (SELECT owner FROM topics WHERE id = $2) = $1 THEN 4 ELSE (
+        SELECT MAX(acl_rank(s.level)) FROM acl s JOIN ancestors a ON s.topic_id = a.id WHERE s.grantee = $1
+    ) END)::SMALLINT
+$$ LANGUAGE SQL STABLE;
+
+-- Rank of access of user $1 to task of kind $2 with id $3, owner $4 and topic_id $5
+CREATE OR REPLACE FUNCTION task_access(VARCHAR, VARCHAR, INTEGER, VARCHAR, INTEGER) RETURNS SMALLINT AS $$
+    SELECT (CASE WHEN $4 = $1 THEN 4 ELSE GREATEST(
+        (SELECT MAX(acl_rank(level)) FROM acl WHERE grantee = $1 AND task_kind = $2 AND task_id = $3),
+        topic_access($1, $5)
+    ) END)::SMALLINT
+$$ LANGUAGE SQL STABLE;
+
+CREATE OR REPLACE FUNCTION delete_task_references() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM task_dependencies
+        WHERE (blocker_kind = TG_ARGV[0] AND blocker_id = OLD.id)
+           OR (blocked_kind = TG_ARGV[0] AND blocked_id = OLD.id);
+    DELETE FROM reminders WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM task_tags WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM task_transitions WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM acl WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TABLE IF NOT EXISTS workspaces(
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(128) NOT NULL,
+    owner VARCHAR(128) NOT NULL,
+    -- Implicit workspace of owner, nobody else can join it
+    personal BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    FOREIGN KEY (owner) REFERENCES users(username)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS workspaces_personal_idx ON workspaces(owner) WHERE personal;
+
+CREATE TABLE IF NOT EXISTS workspace_members(
+    workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    username VARCHAR(128) NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (workspace_id, username),
+    FOREIGN KEY (username) REFERENCES users(username)
+);
+
+CREATE INDEX IF NOT EXISTS workspace_members_username_idx ON workspace_members(username);
+
+CREATE OR REPLACE FUNCTION create_personal_workspace() RETURNS TRIGGER AS $$
+BEGIN
+    WITH w AS (
+        INSERT INTO workspaces(name, owner, personal) VALUES ('Personal', NEW.username, TRUE)
+        ON CONFLICT DO NOTHING
+        RETURNING id
+    )
+    INSERT INTO workspace_members(workspace_id, username, role) SELECT id, NEW.username, 'owner' FROM w;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER users_personal_workspace AFTER INSERT ON users
+    FOR EACH ROW EXECUTE FUNCTION create_personal_workspace();
+
+-- Users registered before workspaces existed
+WITH w AS (
+    INSERT INTO workspaces(name, owner, personal) SELECT 'Personal', username, TRUE FROM users
+    ON CONFLICT DO NOTHING
+    RETURNING id, owner
+)
+INSERT INTO workspace_members(workspace_id, username, role) SELECT id, owner, 'owner' FROM w;
+
+ALTER TABLE topics ADD COLUMN IF NOT EXISTS workspace_id INTEGER REFERENCES workspaces(id);
+ALTER TABLE base_tasks ADD COLUMN IF NOT EXISTS workspace_id INTEGER REFERENCES workspaces(id);
+ALTER TABLE events ADD COLUMN IF NOT EXISTS workspace_id INTEGER REFERENCES workspaces(id);
+ALTER TABLE tasks_with_deadline ADD COLUMN IF NOT EXISTS workspace_id INTEGER REFERENCES workspaces(id);
+ALTER TABLE repeating_tasks ADD COLUMN IF NOT EXISTS workspace_id INTEGER REFERENCES workspaces(id);
+
+UPDATE topics t SET workspace_id = w.id FROM workspaces w
+    WHERE t.workspace_id IS NULL AND w.personal AND w.owner = t.owner;
+UPDATE base_tasks t SET workspace_id = w.id FROM workspaces w
+    WHERE t.workspace_id IS NULL AND w.personal AND w.owner = t.owner;
+UPDATE events t SET workspace_id = w.id FROM workspaces w
+    WHERE t.workspace_id IS NULL AND w.personal AND w.owner = t.owner;
+UPDATE tasks_with_deadline t SET workspace_id = w.id FROM workspaces w
+    WHERE t.workspace_id IS NULL AND w.personal AND w.owner = t.owner;
+UPDATE repeating_tasks t SET workspace_id = w.id FROM workspaces w
+    WHERE t.workspace_id IS NULL AND w.personal AND w.owner = t.owner;
+
+ALTER TABLE topics ALTER COLUMN workspace_id SET NOT NULL;
+ALTER TABLE base_tasks ALTER COLUMN workspace_id SET NOT NULL;
+ALTER TABLE events ALTER COLUMN workspace_id SET NOT NULL;
+ALTER TABLE tasks_with_deadline ALTER COLUMN workspace_id SET NOT NULL;
+ALTER TABLE repeating_tasks ALTER COLUMN workspace_id SET NOT NULL;
+
+-- Topic names are unique among children of the same parent within workspace instead of owner
+ALTER TABLE topics DROP CONSTRAINT IF EXISTS topics_owner_name_key;
+DROP INDEX IF EXISTS topics_workspace_name_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS topics_workspace_parent_name_idx ON topics(workspace_id, COALESCE(parent_id, 0), name);
+
+-- Topic workflows are shared by members of topic workspace
+CREATE UNIQUE INDEX IF NOT EXISTS workflows_topic_idx ON workflows(topic_id) WHERE topic_id IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION role_rank(VARCHAR) RETURNS SMALLINT AS $$
+    SELECT (CASE $1 WHEN 'owner' THEN 3 WHEN 'admin' THEN 3 WHEN 'member' THEN 2 WHEN 'guest' THEN 1 END)::SMALLINT
+$$ LANGUAGE SQL IMMUTABLE;
+
+-- Owner of topic has full access to it only in personal workspace,
+-- in team workspaces access comes from role of member
+CREATE OR REPLACE FUNCTION topic_access(VARCHAR, INTEGER) RETURNS SMALLINT AS $$
+    WITH RECURSIVE ancestors(id) AS (
+        SELECT $2
+        UNION
+        SELECT t.parent_id FROM topics t JOIN ancestors a ON t.id = a.id WHERE t.parent_id IS NOT NULL
+    )
+    SELECT GREATEST(
+        (SELECT 4 FROM topics t JOIN workspaces w ON w.id = t.workspace_id
+            WHERE t.id = $2 AND t.owner = $1 AND w.personal),
+        (SELECT MAX(acl_rank(s.level)) FROM acl s JOIN ancestors a ON s.topic_id = a.id
+            WHERE s.grantee = $1),
+        (SELECT role_rank(m.role) FROM topics t
+            JOIN workspaces w ON w.id = t.workspace_id
+            JOIN workspace_members m ON m.workspace_id = w.id
+            WHERE t.id = $2 AND m.username = $1 AND NOT w.personal)
+    )::SMALLINT
+$$ LANGUAGE SQL STABLE;
+
+CREATE OR REPLACE VIEW all_tasks AS
+    SELECT 'basetask' AS kind, id, title, done, owner, topic, parent_kind, parent_id,
+        topic_id, NULL::TIMESTAMP AS due_at, workspace_id FROM base_tasks
+    UNION ALL
+    SELECT 'event', id, title, done, owner, topic, parent_kind, parent_id,
+        topic_id, ends_at, workspace_id FROM events
+    UNION ALL
+    SELECT 'deadline', id, title, done, owner, topic, parent_kind, parent_id,
+        topic_id, deadline, workspace_id FROM tasks_with_deadline
+    UNION ALL
+    SELECT 'repeat', id, title, done, owner, topic, parent_kind, parent_id,
+        topic_id, NULL::TIMESTAMP, workspace_id FROM repeating_tasks;
+
+-- User working on task, owner keeps managing it
+ALTER TABLE base_tasks ADD COLUMN IF NOT EXISTS assignee VARCHAR(128) REFERENCES users(username);
+ALTER TABLE events ADD COLUMN IF NOT EXISTS assignee VARCHAR(128) REFERENCES users(username);
+ALTER TABLE tasks_with_deadline ADD COLUMN IF NOT EXISTS assignee VARCHAR(128) REFERENCES users(username);
+ALTER TABLE repeating_tasks ADD COLUMN IF NOT EXISTS assignee VARCHAR(128) REFERENCES users(username);
+
+CREATE INDEX IF NOT EXISTS base_tasks_assignee_idx ON base_tasks(assignee) WHERE assignee IS NOT NULL;
+CREATE INDEX IF NOT EXISTS events_assignee_idx ON events(assignee) WHERE assignee IS NOT NULL;
+CREATE INDEX IF NOT EXISTS tasks_with_deadline_assignee_idx ON tasks_with_deadline(assignee) WHERE assignee IS NOT NULL;
+CREATE INDEX IF NOT EXISTS repeating_tasks_assignee_idx ON repeating_tasks(assignee) WHERE assignee IS NOT NULL;
+
+-- Same as task_access with assignee $6 of task, who can always view it
+CREATE OR REPLACE FUNCTION task_access(VARCHAR, VARCHAR, INTEGER, VARCHAR, INTEGER, VARCHAR) RETURNS SMALLINT AS $$
+    SELECT GREATEST(task_access($1, $2, $3, $4, $5), (CASE WHEN $6 = $1 THEN 1 ELSE 0 END))::SMALLINT
+$$ LANGUAGE SQL STABLE;
+
+CREATE OR REPLACE VIEW all_tasks AS
+    SELECT 'basetask' AS kind, id, title, done, owner, topic, parent_kind, parent_id,
+        topic_id, NULL::TIMESTAMP AS due_at, workspace_id, assignee FROM base_tasks
+    UNION ALL
+    SELECT 'event', id, title, done, owner, topic, parent_kind, parent_id,
+        topic_id, ends_at, workspace_id, assignee FROM events
+    UNION ALL
+    SELECT 'deadline', id, title, done, owner, topic, parent_kind, parent_id,
+        topic_id, deadline, workspace_id, assignee FROM tasks_with_deadline
+    UNION ALL
+    SELECT 'repeat', id, title, done, owner, topic, parent_kind, parent_id,
+        topic_id, NULL::TIMESTAMP, workspace_id, assignee FROM repeating_tasks;
+
+CREATE TABLE IF NOT EXISTS comments(
+    id SERIAL PRIMARY KEY,
+    task_kind VARCHAR(16) NOT NULL,
+    task_id INTEGER NOT NULL,
+    author VARCHAR(128) NOT NULL,
+    body VARCHAR(4096) NOT NULL,
+    -- Usernames mentioned in body who could view the task
+    mentions VARCHAR(128)[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL,
+    edited_at TIMESTAMP,
+
+    FOREIGN KEY (author) REFERENCES users(username)
+);
+
+CREATE INDEX IF NOT EXISTS comments_task_idx ON comments(task_kind, task_id, id);
+
+-- Previous bodies of edited comments
+CREATE TABLE IF NOT EXISTS comment_edits(
+    id SERIAL PRIMARY KEY,
+    comment_id INTEGER NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    body VARCHAR(4096) NOT NULL,
+    edited_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS comment_edits_comment_idx ON comment_edits(comment_id);
+
+ALTER TABLE base_tasks ADD COLUMN IF NOT EXISTS comment_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS comment_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tasks_with_deadline ADD COLUMN IF NOT EXISTS comment_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE repeating_tasks ADD COLUMN IF NOT EXISTS comment_count INTEGER NOT NULL DEFAULT 0;
+
+-- Keeps comment_count of tasks in sync with their comments
+CREATE OR REPLACE FUNCTION count_task_comments() RETURNS TRIGGER AS $$
+DECLARE
+    c comments%ROWTYPE;
+    delta INTEGER;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        c := NEW;
+        delta := 1;
+    ELSE
+        c := OLD;
+        delta := -1;
+    END IF;
+
+    CASE c.task_kind
+        WHEN 'basetask' THEN
+            UPDATE base_tasks SET comment_count = comment_count + delta WHERE id = c.task_id;
+        WHEN 'event' THEN
+            UPDATE events SET comment_count = comment_count + delta WHERE id = c.task_id;
+        WHEN 'deadline' THEN
+            UPDATE tasks_with_deadline SET comment_count = comment_count + delta WHERE id = c.task_id;
+        WHEN 'repeat' THEN
+            UPDATE repeating_tasks SET comment_count = comment_count + delta WHERE id = c.task_id;
+        ELSE NULL;
+    END CASE;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER comments_count AFTER INSERT OR DELETE ON comments
+    FOR EACH ROW EXECUTE FUNCTION count_task_comments();
+
+CREATE OR REPLACE FUNCTION delete_task_references() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM task_dependencies
+        WHERE (blocker_kind = TG_ARGV[0] AND blocker_id = OLD.id)
+           OR (blocked_kind = TG_ARGV[0] AND blocked_id = OLD.id);
+    DELETE FROM reminders WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM task_tags WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM task_transitions WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM acl WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM comments WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Content of attachments, key is SHA-256 of content so equal files are stored once
+CREATE TABLE IF NOT EXISTS blobs(
+    key VARCHAR(64) PRIMARY KEY,
+    size BIGINT NOT NULL,
+    -- Unreferenced blobs are removed some time after last upload of their content
+    touched_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS attachments(
+    id SERIAL PRIMARY KEY,
+    task_kind VARCHAR(16) NOT NULL,
+    task_id INTEGER NOT NULL,
+    -- User who uploaded the file, its size counts towards their quota
+    owner VARCHAR(128) NOT NULL,
+    blob_key VARCHAR(64) NOT NULL REFERENCES blobs(key),
+    name VARCHAR(255) NOT NULL,
+    mime VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+
+    FOREIGN KEY (owner) REFERENCES users(username)
+);
+
+CREATE INDEX IF NOT EXISTS attachments_task_idx ON attachments(task_kind, task_id);
+CREATE INDEX IF NOT EXISTS attachments_owner_idx ON attachments(owner);
+CREATE INDEX IF NOT EXISTS attachments_blob_idx ON attachments(blob_key);
+
+CREATE OR REPLACE FUNCTION delete_task_references() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM task_dependencies
+        WHERE (blocker_kind = TG_ARGV[0] AND blocker_id = OLD.id)
+           OR (blocked_kind = TG_ARGV[0] AND blocked_id = OLD.id);
+    DELETE FROM reminders WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM task_tags WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM task_transitions WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM acl WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM comments WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM attachments WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Ordered steps of task, positions of items of a task go from 0 without gaps
+CREATE TABLE IF NOT EXISTS checklist_items(
+    id SERIAL PRIMARY KEY,
+    task_kind VARCHAR(16) NOT NULL,
+    task_id INTEGER NOT NULL,
+    title VARCHAR(256) NOT NULL,
+    checked BOOLEAN NOT NULL DEFAULT FALSE,
+    position INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    checked_at TIMESTAMP,
+    checked_by VARCHAR(128),
+
+    FOREIGN KEY (checked_by) REFERENCES users(username)
+);
+
+CREATE INDEX IF NOT EXISTS checklist_items_task_idx ON checklist_items(task_kind, task_id, position);
+
+ALTER TABLE base_tasks ADD COLUMN IF NOT EXISTS checklist_total INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE base_tasks ADD COLUMN IF NOT EXISTS checklist_done INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE base_tasks ADD COLUMN IF NOT EXISTS checklist_autocomplete BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS checklist_total INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS checklist_done INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS checklist_autocomplete BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE tasks_with_deadline ADD COLUMN IF NOT EXISTS checklist_total INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tasks_with_deadline ADD COLUMN IF NOT EXISTS checklist_done INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tasks_with_deadline ADD COLUMN IF NOT EXISTS checklist_autocomplete BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE repeating_tasks ADD COLUMN IF NOT EXISTS checklist_total INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE repeating_tasks ADD COLUMN IF NOT EXISTS checklist_done INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE repeating_tasks ADD COLUMN IF NOT EXISTS checklist_autocomplete BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Keeps checklist_total and checklist_done of tasks in sync with their checklist items
+CREATE OR REPLACE FUNCTION count_task_checklist() RETURNS TRIGGER AS $$
+DECLARE
+    c checklist_items%ROWTYPE;
+    total_delta INTEGER := 0;
+    done_delta INTEGER := 0;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        c := NEW;
+        total_delta := 1;
+        done_delta := CASE WHEN NEW.checked THEN 1 ELSE 0 END;
+    ELSIF TG_OP = 'DELETE' THEN
+        c := OLD;
+        total_delta := -1;
+        done_delta := CASE WHEN OLD.checked THEN -1 ELSE 0 END;
+    ELSE
+        c := NEW;
+        done_delta := (CASE WHEN NEW.checked THEN 1 ELSE 0 END) - (CASE WHEN OLD.checked THEN 1 ELSE 0 END);
+    END IF;
+
+    IF total_delta = 0 AND done_delta = 0 THEN
+        RETURN NULL;
+    END IF;
+
+    CASE c.task_kind
+        WHEN 'basetask' THEN
+            UPDATE base_tasks SET checklist_total = checklist_total + total_delta, checklist_done = checklist_done + done_delta WHERE id = c.task_id;
+        WHEN 'event' THEN
+            UPDATE events SET checklist_total = checklist_total + total_delta, checklist_done = checklist_done + done_delta WHERE id = c.task_id;
+        WHEN 'deadline' THEN
+            UPDATE tasks_with_deadline SET checklist_total = checklist_total + total_delta, checklist_done = checklist_done + done_delta WHERE id = c.task_id;
+        WHEN 'repeat' THEN
+            UPDATE repeating_tasks SET checklist_total = checklist_total + total_delta, checklist_done = checklist_done + done_delta WHERE id = c.task_id;
+        ELSE NULL;
+    END CASE;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER checklist_items_count AFTER INSERT OR UPDATE OF checked OR DELETE ON checklist_items
+    FOR EACH ROW EXECUTE FUNCTION count_task_checklist();
+
+CREATE OR REPLACE FUNCTION delete_task_references() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM task_dependencies
+        WHERE (blocker_kind = TG_ARGV[0] AND blocker_id = OLD.id)
+           OR (blocked_kind = TG_ARGV[0] AND blocked_id = OLD.id);
+    DELETE FROM reminders WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM task_tags WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM task_transitions WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM acl WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM comments WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM attachments WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    DELETE FROM checklist_items WHERE task_kind = TG_ARGV[0] AND task_id = OLD.id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Reusable set-ups of tasks, task is JSON of root template task with its children
+CREATE TABLE IF NOT EXISTS templates(
+    id SERIAL PRIMARY KEY,
+    owner VARCHAR(128) NOT NULL,
+    workspace_id INTEGER NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+    name VARCHAR(128) NOT NULL,
+    task JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+
+    FOREIGN KEY (owner) REFERENCES users(username)
+);
+
+CREATE INDEX IF NOT EXISTS templates_workspace_idx ON templates(workspace_id, name);
+
+-- Versions of tasks recorded on every change, kept after tasks are deleted
+CREATE TABLE IF NOT EXISTS task_history(
+    id BIGSERIAL PRIMARY KEY,
+    task_kind VARCHAR(16) NOT NULL,
+    task_id INTEGER NOT NULL,
+    workspace_id INTEGER NOT NULL,
+    -- NULL for changes made by server itself
+    actor VARCHAR(128),
+    action VARCHAR(32) NOT NULL,
+    changes JSONB NOT NULL,
+    snapshot JSONB NOT NULL,
+    at TIMESTAMP NOT NULL,
+
+    FOREIGN KEY (actor) REFERENCES users(username)
+);
+
+CREATE INDEX IF NOT EXISTS task_history_task_idx ON task_history(task_kind, task_id, id);
+CREATE INDEX IF NOT EXISTS task_history_workspace_idx ON task_history(workspace_id, id);
+
+CREATE OR REPLACE FUNCTION forbid_history_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'task_history is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER task_history_append_only BEFORE UPDATE OR DELETE ON task_history
+    FOR EACH STATEMENT EXECUTE FUNCTION forbid_history_change();
+
+-- Deleted tasks stay in trash until they are restored or purged
+ALTER TABLE base_tasks ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE tasks_with_deadline ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE repeating_tasks ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS base_tasks_deleted_idx ON base_tasks(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS events_deleted_idx ON events(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS tasks_with_deadline_deleted_idx ON tasks_with_deadline(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS repeating_tasks_deleted_idx ON repeating_tasks(deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE OR REPLACE VIEW all_tasks AS
+    SELECT 'basetask' AS kind, id, title, done, owner, topic, parent_kind, parent_id,
+        topic_id, NULL::TIMESTAMP AS due_at, workspace_id, assignee FROM base_tasks WHERE deleted_at IS NULL
+    UNION ALL
+    SELECT 'event', id, title, done, owner, topic, parent_kind, parent_id,
+        topic_id, ends_at, workspace_id, assignee FROM events WHERE deleted_at IS NULL
+    UNION ALL
+    SELECT 'deadline', id, title, done, owner, topic, parent_kind, parent_id,
+        topic_id, deadline, workspace_id, assignee FROM tasks_with_deadline WHERE deleted_at IS NULL
+    UNION ALL
+    SELECT 'repeat', id, title, done, owner, topic, parent_kind, parent_id,
+        topic_id, NULL::TIMESTAMP, workspace_id, assignee FROM repeating_tasks WHERE deleted_at IS NULL;
+
+CREATE OR REPLACE VIEW trashed_tasks AS
+    SELECT 'basetask' AS kind, id, owner, parent_kind, parent_id, topic_id, workspace_id, assignee, deleted_at
+        FROM base_tasks WHERE deleted_at IS NOT NULL
+    UNION ALL
+    SELECT 'event', id, owner, parent_kind, parent_id, topic_id, workspace_id, assignee, deleted_at
+        FROM events WHERE deleted_at IS NOT NULL
+    UNION ALL
+    SELECT 'deadline', id, owner, parent_kind, parent_id, topic_id, workspace_id, assignee, deleted_at
+        FROM tasks_with_deadline WHERE deleted_at IS NOT NULL
+    UNION ALL
+    SELECT 'repeat', id, owner, parent_kind, parent_id, topic_id, workspace_id, assignee, deleted_at
+        FROM repeating_tasks WHERE deleted_at IS NOT NULL;
+
+-- Workspace of task of kind $1 with id $2, whether in trash or not
+CREATE OR REPLACE FUNCTION task_workspace(VARCHAR, INTEGER) RETURNS INTEGER AS $$
+    SELECT workspace_id FROM base_tasks WHERE $1 = 'basetask' AND id = $2
+    UNION ALL
+    SELECT workspace_id FROM events WHERE $1 = 'event' AND id = $2
+    UNION ALL
+    SELECT workspace_id FROM tasks_with_deadline WHERE $1 = 'deadline' AND id = $2
+    UNION ALL
+    SELECT workspace_id FROM repeating_tasks WHERE $1 = 'repeat' AND id = $2
+$$ LANGUAGE SQL STABLE;
+
+-- Owner of task has full access to it only while member of its workspace,
+-- personal workspace always has its owner as member
+CREATE OR REPLACE FUNCTION task_access(VARCHAR, VARCHAR, INTEGER, VARCHAR, INTEGER) RETURNS SMALLINT AS $$
+    SELECT GREATEST(
+        (SELECT 4 FROM workspace_members m
+            WHERE $4 = $1 AND m.username = $1 AND m.workspace_id = task_workspace($2, $3)),
+        (SELECT MAX(acl_rank(level)) FROM acl WHERE grantee = $1 AND task_kind = $2 AND task_id = $3),
+        topic_access($1, $5)
+    )::SMALLINT
+$$ LANGUAGE SQL STABLE;
+
+-- Reminder is claimed as sending while notification is sent outside of transaction,
+-- claim expires at claimed_until in case instance sending it dies
+ALTER TABLE reminders ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE reminders ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP;
+ALTER TABLE reminders ADD COLUMN IF NOT EXISTS error VARCHAR(512);
+ALTER TABLE reminders ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS reminders_sending_idx ON reminders(claimed_until) WHERE status = 'sending';
+
+-- Repeating tasks with positive months repeat every that many calendar months instead of period seconds
+ALTER TABLE repeating_tasks ADD COLUMN IF NOT EXISTS months INTEGER NOT NULL DEFAULT 0;
+
+-- Path of topic $1 from root topic like 'work/auth'
+CREATE OR REPLACE FUNCTION topic_path(INTEGER) RETURNS VARCHAR AS $$
+    WITH RECURSIVE ancestors(name, parent_id, depth) AS (
+        SELECT name, parent_id, 0 FROM topics WHERE id = $1
+        UNION ALL
+        SELECT t.name, t.parent_id, a.depth + 1 FROM topics t JOIN ancestors a ON t.id = a.parent_id
+    )
+    SELECT string_agg(name, '/' ORDER BY depth DESC) FROM ancestors
+$$ LANGUAGE SQL STABLE;
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+// Loads task of given kind owned by username
+func getOwnTask(ctx context.Context, username, kind string, id int) (any, *tasks.BaseTask, error) {
+	return getTask(ctx, username, kind, id, tasks.AccessOwner)
+}
+
+// Loads task of given kind which username has at least given access to
+func getTask(ctx context.Context, username, kind string, id int, level string) (any, *tasks.BaseTask, error) {
+	if kind == "" {
+		return nil, nil, middleware.HTTPError{
+			Err:     nil,
+			Message: "Task type not found in form",
+			Code:    http.StatusNotFound,
+		}
+	}
+
+	task, base, err := database.GetTask(ctx, kind, id)
+	if err == database.ErrInvalidKind {
+		return nil, nil, middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid task type",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	if err == sql.ErrNoRows {
+		return nil, nil, middleware.HTTPError{
+			Err:     nil,
+			Message: "Task with such id not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Owner of task may have left its workspace, so access is checked for owner too
+	if err := checkAccess(ctx, username, tasks.TaskRef{Kind: kind, ID: id}, level); err != nil {
+		return nil, nil, err
+	}
+
+	return task, base, nil
+}
+
+// Loads task username can comment and attach files to, which requires editor access unless username is assignee
+func getContributedTask(ctx context.Context, username, kind string, id int) (any, *tasks.BaseTask, error) {
+	task, base, err := getTask(ctx, username, kind, id, tasks.AccessViewer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if base.Assignee == nil || *base.Assignee != username {
+		if err := checkAccess(ctx, username, tasks.TaskRef{Kind: kind, ID: id}, tasks.AccessEditor); err != nil {
+			return nil, nil, err
+		}
+	}
+	return task, base, nil
+}
+
+// Returns error if username has lower access to task than level
+func checkAccess(ctx context.Context, username string, ref tasks.TaskRef, level string) error {
+	access, err := database.GetTaskAccess(ctx, username, ref)
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Task with such id not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return accessError(access, level)
+}
+
+func accessError(access, level string) error {
+	if access == "" {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Cannot access tasks of other users",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+	if tasks.AccessRank(access) < tasks.AccessRank(level) {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Requires " + level + " access",
+			Code:    http.StatusForbidden,
+		}
+	}
+	return nil
+}
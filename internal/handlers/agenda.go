@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+// Longest window repeating tasks are expanded in
+const maxWindow = 366 * 24 * time.Hour
+
+func Agenda(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	from, to, err := parseWindow(r)
+	if err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	userDB, err := database.GetUserAgendaTasks(dctx, user.Username, from, to)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(tasks.BuildAgenda(userDB, from, to))
+}
+
+// Parses required `from` and `to` query parameters
+func parseWindow(r *http.Request) (time.Time, time.Time, error) {
+	q := r.URL.Query()
+
+	if !q.Has("from") || !q.Has("to") {
+		return time.Time{}, time.Time{}, middleware.HTTPError{
+			Err:     nil,
+			Message: "Missing from or to",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	from, err := parseUnixParam(q, "from")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	to, err := parseUnixParam(q, "to")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if !to.After(from) || to.Sub(from) > maxWindow {
+		return time.Time{}, time.Time{}, middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid window",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	return from, to, nil
+}
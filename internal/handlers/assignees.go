@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+// Fields of update form assignee can send without editor access to task
+var assigneeFields = map[string]bool{"id": true, "tasktype": true, "status": true, "done": true, "workspace": true}
+
+// Whether username is assignee of task and form changes only its status
+func assigneeUpdate(form url.Values, task *tasks.BaseTask, username string) bool {
+	if task.Assignee == nil || *task.Assignee != username {
+		return false
+	}
+	for field := range form {
+		if !assigneeFields[field] {
+			return false
+		}
+	}
+	return true
+}
+
+func setAssignee(ctx context.Context, r *http.Request, kind string, task any, base *tasks.BaseTask, assignee *string) error {
+	err := database.AssignTask(ctx, tasks.TaskRef{Kind: kind, ID: base.ID}, assignee)
+	if err == database.ErrUserNotFound {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: "User not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err == database.ErrCannotAssign {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: err.Error(),
+			Code:    http.StatusForbidden,
+		}
+	}
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Task with such id not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	base.Assignee = assignee
+	emitTaskEvent(r.Context(), base.Owner, tasks.EventTaskUpdated, kind, task)
+	return nil
+}
+
+// Assigns or reassigns task to user from `username`.
+// Assignee must share topic or workspace of task with its owner
+func AssignTask(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	kind := r.PathValue("kind")
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	username := r.Form.Get("username")
+	if username == "" {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid username",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	task, base, err := getTask(dctx, user.Username, kind, id, tasks.AccessEditor)
+	if err != nil {
+		return err
+	}
+
+	if err := setAssignee(dctx, r, kind, task, base, &username); err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(task)
+}
+
+// Leaves task without assignee. Assignee can also unassign themselves
+func UnassignTask(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	kind := r.PathValue("kind")
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	task, base, err := getTask(dctx, user.Username, kind, id, tasks.AccessViewer)
+	if err != nil {
+		return err
+	}
+	if base.Assignee == nil || *base.Assignee != user.Username {
+		if err := checkAccess(dctx, user.Username, tasks.TaskRef{Kind: kind, ID: id}, tasks.AccessEditor); err != nil {
+			return err
+		}
+	}
+
+	if err := setAssignee(dctx, r, kind, task, base, nil); err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(task)
+}
+
+// Returns tasks assigned to user in every workspace, filtered the same way as GET /tasks
+func AssignedTasks(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	filter, err := parseTaskFilter(r.URL.Query())
+	if err != nil {
+		return err
+	}
+	filter.Assignee = user.Username
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	userDB, next, err := database.ListUserTasks(dctx, user.Username, filter)
+	if err == database.ErrInvalidCursor || err == database.ErrInvalidSort {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(tasksPage{User: userDB, NextCursor: next})
+}
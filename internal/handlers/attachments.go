@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/blob"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+const maxAttachmentName = 255
+
+// Room for multipart boundaries and headers around the file
+const multipartOverhead = 1 << 20
+
+// Sniffed type wins over one of file extension unless sniffing found nothing specific
+func attachmentMIME(sniffed, name string) string {
+	if sniffed == "application/octet-stream" || strings.HasPrefix(sniffed, "text/plain") {
+		if byExt := mime.TypeByExtension(filepath.Ext(name)); byExt != "" {
+			return byExt
+		}
+	}
+	return sniffed
+}
+
+// Returns part of multipart request with `file` field
+func filePart(r *http.Request) (io.Reader, string, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid multipart form",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, "", middleware.HTTPError{
+				Err:     nil,
+				Message: "Missing file",
+				Code:    http.StatusBadRequest,
+			}
+		}
+		if err != nil {
+			return nil, "", middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid multipart form",
+				Code:    http.StatusBadRequest,
+			}
+		}
+
+		if part.FormName() == "file" {
+			name := filepath.Base(part.FileName())
+			if name == "." || name == string(filepath.Separator) || utf8.RuneCountInString(name) > maxAttachmentName {
+				return nil, "", middleware.HTTPError{
+					Err:     nil,
+					Message: "Invalid file name",
+					Code:    http.StatusBadRequest,
+				}
+			}
+			return part, name, nil
+		}
+	}
+}
+
+// Loads attachment from path with its task which username can view
+func getAttachment(ctx context.Context, r *http.Request, username string) (*tasks.Attachment, *tasks.BaseTask, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attachment, err := database.GetAttachment(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, nil, middleware.HTTPError{
+			Err:     nil,
+			Message: "Attachment not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, base, err := getTask(ctx, username, attachment.TaskType, attachment.TaskID, tasks.AccessViewer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return attachment, base, nil
+}
+
+// Attaches `file` of multipart form to task. Requires editor access, assignee can attach files as well
+func UploadAttachment(store blob.BlobStore) func(http.ResponseWriter, *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		user := auth.ContextUser(r.Context())
+		if user == nil {
+			return middleware.HTTPError{
+				Err:     nil,
+				Message: "Unauthorized",
+				Code:    http.StatusUnauthorized,
+			}
+		}
+
+		id, err := pathID(r, "id")
+		if err != nil {
+			return err
+		}
+		kind := r.PathValue("kind")
+
+		dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+		defer cancel()
+
+		if _, _, err := getContributedTask(dctx, user.Username, kind, id); err != nil {
+			return err
+		}
+
+		maxSize, quota := tasks.Cfg.Attachments.GetMaxSize(), tasks.Cfg.Attachments.GetQuota()
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize+multipartOverhead)
+
+		part, name, err := filePart(r)
+		if err != nil {
+			return err
+		}
+
+		// Upload may take longer than deadline of database calls
+		spooled, err := blob.Spool(part, maxSize)
+		var maxBytesErr *http.MaxBytesError
+		if err == blob.ErrTooLarge || errors.As(err, &maxBytesErr) {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "File is too large",
+				Code:    http.StatusRequestEntityTooLarge,
+			}
+		}
+		if err != nil {
+			return err
+		}
+		defer spooled.Close()
+
+		dctx, cancel = context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+		defer cancel()
+
+		used, err := database.GetAttachmentUsage(dctx, user.Username)
+		if err != nil {
+			return err
+		}
+		if used+spooled.Size > quota {
+			return middleware.HTTPError{
+				Err:     database.ErrQuotaExceeded,
+				Message: database.ErrQuotaExceeded.Error(),
+				Code:    http.StatusRequestEntityTooLarge,
+			}
+		}
+
+		if err := database.TouchBlob(dctx, spooled.Key, spooled.Size); err != nil {
+			return err
+		}
+		content, err := spooled.Reader()
+		if err != nil {
+			return err
+		}
+		if err := store.Put(r.Context(), spooled.Key, content, spooled.Size); err != nil {
+			return err
+		}
+
+		dctx, cancel = context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+		defer cancel()
+
+		attachment := tasks.Attachment{
+			TaskType: kind,
+			TaskID:   id,
+			Owner:    user.Username,
+			BlobKey:  spooled.Key,
+			Name:     name,
+			MIME:     attachmentMIME(spooled.MIME, name),
+			Size:     spooled.Size,
+		}
+		err = database.CreateAttachment(dctx, &attachment, quota)
+		if err == database.ErrQuotaExceeded {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: err.Error(),
+				Code:    http.StatusRequestEntityTooLarge,
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		return json.NewEncoder(w).Encode(attachment)
+	}
+}
+
+func TaskAttachments(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	kind := r.PathValue("kind")
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	if _, _, err := getTask(dctx, user.Username, kind, id, tasks.AccessViewer); err != nil {
+		return err
+	}
+
+	attachments, err := database.GetTaskAttachments(dctx, tasks.TaskRef{Kind: kind, ID: id})
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(attachments)
+}
+
+// Streams content of attachment, Range and conditional requests are supported
+func DownloadAttachment(store blob.BlobStore) func(http.ResponseWriter, *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		user := auth.ContextUser(r.Context())
+		if user == nil {
+			return middleware.HTTPError{
+				Err:     nil,
+				Message: "Unauthorized",
+				Code:    http.StatusUnauthorized,
+			}
+		}
+
+		dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+		defer cancel()
+
+		attachment, _, err := getAttachment(dctx, r, user.Username)
+		if err != nil {
+			return err
+		}
+
+		content, err := store.Open(r.Context(), attachment.BlobKey)
+		if err == blob.ErrNotFound {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Attachment content not found",
+				Code:    http.StatusNotFound,
+			}
+		}
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+
+		w.Header().Set("Content-Type", attachment.MIME)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("ETag", `"`+attachment.BlobKey+`"`)
+		http.ServeContent(w, r, attachment.Name, attachment.CreatedAt, content)
+		return nil
+	}
+}
+
+// Deletes own attachment or any attachment of task user administers.
+// Content is removed later if no other attachment has it
+func DeleteAttachment(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	attachment, _, err := getAttachment(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+	if attachment.Owner != user.Username {
+		ref := tasks.TaskRef{Kind: attachment.TaskType, ID: attachment.TaskID}
+		if err := checkAccess(dctx, user.Username, ref, tasks.AccessAdmin); err != nil {
+			return err
+		}
+	}
+
+	if err := database.DeleteAttachment(dctx, attachment.ID); err != nil {
+		return err
+	}
+
+	w.Write([]byte("Successful"))
+	return nil
+}
+
+// Returns total size of attachments uploaded by user and their quota
+func AttachmentUsage(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	used, err := database.GetAttachmentUsage(dctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(tasks.AttachmentUsage{Used: used, Quota: tasks.Cfg.Attachments.GetQuota()})
+}
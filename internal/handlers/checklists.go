@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+type checklistItemResponse struct {
+	*tasks.ChecklistItem
+	// Set when the change completed task of the item
+	TaskCompleted bool `json:"task_completed,omitempty"`
+}
+
+type checklistResponse struct {
+	tasks.ChecklistProgress
+	TaskCompleted bool `json:"task_completed,omitempty"`
+}
+
+func parseChecklistTitle(r *http.Request, item *tasks.ChecklistItem) error {
+	title := strings.TrimSpace(r.Form.Get("title"))
+	if title == "" || utf8.RuneCountInString(title) > tasks.MaxChecklistItemTitle {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid title",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	item.Title = title
+	return nil
+}
+
+func parseChecklistPosition(r *http.Request, item *tasks.ChecklistItem) error {
+	position, err := strconv.Atoi(r.Form.Get("position"))
+	if err != nil || position < 0 {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid position",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	item.Position = position
+	return nil
+}
+
+// Loads checklist item from path with its task which username can view
+func getChecklistItem(ctx context.Context, r *http.Request, username string) (*tasks.ChecklistItem, *tasks.BaseTask, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	item, err := database.GetChecklistItem(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, nil, middleware.HTTPError{
+			Err:     nil,
+			Message: "Checklist item not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, base, err := getTask(ctx, username, item.TaskType, item.TaskID, tasks.AccessViewer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return item, base, nil
+}
+
+// Completes task whose checklist asks for it once all items are checked.
+// Task stays open if its subtasks or blockers wouldn't let it be completed. Reports whether task got completed
+func autoCompleteTask(ctx context.Context, ref tasks.TaskRef) (bool, error) {
+	_, base, err := database.GetTask(ctx, ref.Kind, ref.ID)
+	if err != nil {
+		return false, err
+	}
+	if base.Done || !base.Checklist.Completes() {
+		return false, nil
+	}
+
+	policy := tasks.Cfg.Subtasks.OnComplete
+	if policy == tasks.ChildrenBlock {
+		has, err := database.HasTaskDescendants(ctx, ref, true)
+		if err != nil || has {
+			return false, err
+		}
+	}
+
+	blockers, err := database.GetOpenBlockers(ctx, ref)
+	if err != nil || len(blockers) > 0 {
+		return false, err
+	}
+
+	node, u, err := database.AutoCompleteTask(ctx, ref)
+	if err != nil || node == nil {
+		return false, err
+	}
+	defer u.Rollback()
+
+	notifyChildren, err := completeChildren(ctx, u, base.Owner, policy)
+	if err != nil {
+		return false, err
+	}
+	if err = u.Commit(ctx); err != nil {
+		return false, err
+	}
+
+	emitTaskEvent(ctx, base.Owner, tasks.EventTaskUpdated, node.TaskType, node.Task)
+	emitTaskEvent(ctx, base.Owner, tasks.EventTaskCompleted, node.TaskType, node.Task)
+	notifyChildren(ctx)
+	return true, nil
+}
+
+// Returns items of task checklist in order
+func TaskChecklist(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	kind := r.PathValue("kind")
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	if _, _, err := getTask(dctx, user.Username, kind, id, tasks.AccessViewer); err != nil {
+		return err
+	}
+
+	items, err := database.GetChecklist(dctx, tasks.TaskRef{Kind: kind, ID: id})
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(items)
+}
+
+// Adds item with `title` to task checklist at `position`, the last if it is absent
+func CreateChecklistItem(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	kind := r.PathValue("kind")
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	item := tasks.ChecklistItem{TaskType: kind, TaskID: id, Position: -1}
+	if err := parseChecklistTitle(r, &item); err != nil {
+		return err
+	}
+	if r.Form.Has("position") {
+		if err := parseChecklistPosition(r, &item); err != nil {
+			return err
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	if _, _, err := getTask(dctx, user.Username, kind, id, tasks.AccessEditor); err != nil {
+		return err
+	}
+
+	if err := database.CreateChecklistItem(dctx, &item); err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(item)
+}
+
+// Turns completion of task by its checklist on or off with `auto_complete`.
+// Task with all items already checked gets completed right away
+func UpdateChecklist(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	kind := r.PathValue("kind")
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	_, base, err := getTask(dctx, user.Username, kind, id, tasks.AccessEditor)
+	if err != nil {
+		return err
+	}
+
+	ref := tasks.TaskRef{Kind: kind, ID: id}
+	base.Checklist.AutoComplete = r.Form.Get("auto_complete") == "true"
+	if err := database.SetChecklistAutoComplete(dctx, ref, base.Checklist.AutoComplete); err != nil {
+		return err
+	}
+
+	completed, err := autoCompleteTask(dctx, ref)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(checklistResponse{base.Checklist, completed})
+}
+
+// Changes `title` of item and moves it to `position`, both are optional
+func UpdateChecklistItem(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	item, _, err := getChecklistItem(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+	ref := tasks.TaskRef{Kind: item.TaskType, ID: item.TaskID}
+	if err := checkAccess(dctx, user.Username, ref, tasks.AccessEditor); err != nil {
+		return err
+	}
+
+	if r.Form.Has("title") {
+		if err := parseChecklistTitle(r, item); err != nil {
+			return err
+		}
+	}
+	if r.Form.Has("position") {
+		if err := parseChecklistPosition(r, item); err != nil {
+			return err
+		}
+	}
+
+	err = database.UpdateChecklistItem(dctx, item)
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Checklist item not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(item)
+}
+
+// Checks item or unchecks it. Requires editor access, assignee can check items as well
+func setChecklistItemChecked(w http.ResponseWriter, r *http.Request, checked bool) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	item, base, err := getChecklistItem(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+	if base.Assignee == nil || *base.Assignee != user.Username {
+		ref := tasks.TaskRef{Kind: item.TaskType, ID: item.TaskID}
+		if err := checkAccess(dctx, user.Username, ref, tasks.AccessEditor); err != nil {
+			return err
+		}
+	}
+
+	item, err = database.SetChecklistItemChecked(dctx, item.ID, checked, user.Username)
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Checklist item not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	completed := false
+	if checked {
+		completed, err = autoCompleteTask(dctx, tasks.TaskRef{Kind: item.TaskType, ID: item.TaskID})
+		if err != nil {
+			return err
+		}
+	}
+
+	return json.NewEncoder(w).Encode(checklistItemResponse{item, completed})
+}
+
+// Checks item, which completes its task if checklist asks for it and all items are checked
+func CheckChecklistItem(w http.ResponseWriter, r *http.Request) error {
+	return setChecklistItemChecked(w, r, true)
+}
+
+func UncheckChecklistItem(w http.ResponseWriter, r *http.Request) error {
+	return setChecklistItemChecked(w, r, false)
+}
+
+// Deletes item, which completes its task if checklist asks for it and the rest of items are checked.
+// Returns checklist progress after deletion
+func DeleteChecklistItem(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	item, _, err := getChecklistItem(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+	ref := tasks.TaskRef{Kind: item.TaskType, ID: item.TaskID}
+	if err := checkAccess(dctx, user.Username, ref, tasks.AccessEditor); err != nil {
+		return err
+	}
+
+	if err := database.DeleteChecklistItem(dctx, item.ID); err != nil {
+		return err
+	}
+
+	completed, err := autoCompleteTask(dctx, ref)
+	if err != nil {
+		return err
+	}
+
+	_, base, err := database.GetTask(dctx, ref.Kind, ref.ID)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(checklistResponse{base.Checklist, completed})
+}
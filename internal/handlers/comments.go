@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	"github.com/Kry0z1/fancytasks/internal/stream"
+	"github.com/Kry0z1/fancytasks/internal/webhooks"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+const defaultCommentsLimit = 50
+
+type commentsPage struct {
+	Comments   []tasks.Comment `json:"comments"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// Notifies webhooks and live streams of recipient about comment event
+func emitCommentEvent(ctx context.Context, recipient, event string, c *tasks.Comment) {
+	raw, err := json.Marshal(tasks.CommentEvent{Event: event, Comment: *c, OccurredAt: time.Now()})
+	if err != nil {
+		log.Printf("Couldn't emit %s: %s", event, err.Error())
+		return
+	}
+
+	webhooks.Emit(ctx, recipient, event, string(raw))
+	stream.Publish(ctx, recipient, event, string(raw))
+}
+
+// Notifies users of mentions except author
+func notifyMentions(ctx context.Context, c *tasks.Comment, mentions []string) {
+	for _, username := range mentions {
+		if username != c.Author {
+			emitCommentEvent(ctx, username, tasks.EventCommentMentioned, c)
+		}
+	}
+}
+
+// Sets body of comment from `body` form value with mentions of users who can view its task
+func parseCommentBody(ctx context.Context, r *http.Request, c *tasks.Comment) error {
+	body := strings.TrimSpace(r.Form.Get("body"))
+	if body == "" || utf8.RuneCountInString(body) > tasks.MaxCommentBody {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid body",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	mentions, err := database.GetTaskViewers(ctx, tasks.TaskRef{Kind: c.TaskType, ID: c.TaskID}, tasks.ParseMentions(body))
+	if err != nil {
+		return err
+	}
+
+	c.Body, c.Mentions = body, mentions
+	return nil
+}
+
+// Loads comment from path with its task which username can view
+func getComment(ctx context.Context, r *http.Request, username string) (*tasks.Comment, *tasks.BaseTask, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	comment, err := database.GetComment(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, nil, middleware.HTTPError{
+			Err:     nil,
+			Message: "Comment not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, base, err := getTask(ctx, username, comment.TaskType, comment.TaskID, tasks.AccessViewer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return comment, base, nil
+}
+
+// Returns comments of task, the oldest first. Next page starts after `cursor`
+func TaskComments(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	kind := r.PathValue("kind")
+
+	q := r.URL.Query()
+	limit := defaultCommentsLimit
+	if q.Has("limit") {
+		if limit, err = strconv.Atoi(q.Get("limit")); err != nil || limit <= 0 || limit > maxLimit {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid limit",
+				Code:    http.StatusBadRequest,
+			}
+		}
+	}
+
+	after := 0
+	if q.Has("cursor") {
+		if after, err = strconv.Atoi(q.Get("cursor")); err != nil {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid cursor",
+				Code:    http.StatusBadRequest,
+			}
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	if _, _, err := getTask(dctx, user.Username, kind, id, tasks.AccessViewer); err != nil {
+		return err
+	}
+
+	comments, err := database.GetTaskComments(dctx, tasks.TaskRef{Kind: kind, ID: id}, after, limit)
+	if err != nil {
+		return err
+	}
+
+	page := commentsPage{Comments: comments}
+	if len(comments) == limit {
+		page.NextCursor = strconv.Itoa(comments[len(comments)-1].ID)
+	}
+
+	return json.NewEncoder(w).Encode(page)
+}
+
+// Comments task with `body`. Requires editor access, assignee can comment as well
+func CreateComment(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	kind := r.PathValue("kind")
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	_, base, err := getContributedTask(dctx, user.Username, kind, id)
+	if err != nil {
+		return err
+	}
+
+	comment := tasks.Comment{TaskType: kind, TaskID: id, Author: user.Username}
+	if err := parseCommentBody(dctx, r, &comment); err != nil {
+		return err
+	}
+
+	if err := database.CreateComment(dctx, &comment); err != nil {
+		return err
+	}
+
+	emitCommentEvent(r.Context(), base.Owner, tasks.EventCommentCreated, &comment)
+	notifyMentions(r.Context(), &comment, comment.Mentions)
+
+	return json.NewEncoder(w).Encode(comment)
+}
+
+// Changes `body` of own comment. Only users mentioned for the first time are notified
+func UpdateComment(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	comment, _, err := getComment(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+	if comment.Author != user.Username {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Cannot edit comments of other users",
+			Code:    http.StatusForbidden,
+		}
+	}
+
+	previous := comment.Mentions
+	if err := parseCommentBody(dctx, r, comment); err != nil {
+		return err
+	}
+
+	err = database.UpdateComment(dctx, comment)
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Comment not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	notifyMentions(r.Context(), comment, tasks.NewMentions(previous, comment.Mentions))
+
+	return json.NewEncoder(w).Encode(comment)
+}
+
+// Deletes own comment or any comment of task user administers
+func DeleteComment(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	comment, _, err := getComment(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+	if comment.Author != user.Username {
+		ref := tasks.TaskRef{Kind: comment.TaskType, ID: comment.TaskID}
+		if err := checkAccess(dctx, user.Username, ref, tasks.AccessAdmin); err != nil {
+			return err
+		}
+	}
+
+	if err := database.DeleteComment(dctx, comment.ID); err != nil {
+		return err
+	}
+
+	w.Write([]byte("Successful"))
+	return nil
+}
+
+// Returns previous bodies of comment, the oldest first
+func CommentEdits(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	comment, _, err := getComment(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	edits, err := database.GetCommentEdits(dctx, comment.ID)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(edits)
+}
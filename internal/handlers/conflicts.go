@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+type eventResponse struct {
+	*tasks.Event
+	Conflicts []tasks.Slot `json:"conflicts,omitempty"`
+}
+
+type repeatingTaskResponse struct {
+	*tasks.RepeatingTask
+	Conflicts []tasks.Slot `json:"conflicts,omitempty"`
+}
+
+// Occurrences of repeating task checked for overlaps start from now or
+// from the first one if it is later
+func repeatingSlots(t *tasks.RepeatingTask) []tasks.Slot {
+	from := time.Now()
+	if t.StartsAt.After(from) {
+		from = t.StartsAt
+	}
+	return t.Slots(from, from.Add(tasks.Cfg.Conflicts.GetHorizon()))
+}
+
+// Returns slots of other user's tasks overlapping given ones.
+// If request has strict=true and there are any, conflict error is returned
+func findConflicts(ctx context.Context, r *http.Request, username string, slots []tasks.Slot) ([]tasks.Slot, error) {
+	if len(slots) == 0 {
+		return nil, nil
+	}
+
+	from, to := tasks.SlotsWindow(slots)
+	userDB, err := database.GetUserAgendaTasks(ctx, username, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := tasks.Conflicts(slots, tasks.Slots(userDB, from, to))
+	if len(conflicts) > 0 && r.Form.Get("strict") == "true" {
+		return nil, middleware.HTTPError{
+			Err:     nil,
+			Message: "Task overlaps with existing events",
+			Code:    http.StatusConflict,
+		}
+	}
+
+	return conflicts, nil
+}
+
+func EventConflicts(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	from, to, err := parseWindow(r)
+	if err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	userDB, err := database.GetUserAgendaTasks(dctx, user.Username, from, to)
+	if err != nil {
+		return err
+	}
+
+	pairs := tasks.ConflictPairs(tasks.Slots(userDB, from, to))
+	if pairs == nil {
+		pairs = []tasks.ConflictPair{}
+	}
+
+	return json.NewEncoder(w).Encode(pairs)
+}
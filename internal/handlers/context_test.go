@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+type stubTokenizer struct {
+	user *tasks.User
+}
+
+func (s stubTokenizer) CreateToken(map[string]any, time.Duration) (string, error) {
+	return "token", nil
+}
+
+func (s stubTokenizer) CheckToken(context.Context, string) (*tasks.User, error) {
+	return s.user, nil
+}
+
+// Values put in context by auth, history recording and revert must not be mistaken for each other
+func TestContextValues(t *testing.T) {
+	alice := &tasks.User{Username: "alice"}
+
+	tests := []struct {
+		name   string
+		revert bool
+	}{
+		{"request", false},
+		{"revert", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUser *tasks.User
+			var gotRevert bool
+			handler := auth.CheckAuth(stubTokenizer{alice})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.revert {
+					r = r.WithContext(context.WithValue(r.Context(), contextRevertKey{}, true))
+				}
+				gotUser = auth.ContextUser(r.Context())
+				gotRevert = isRevert(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/tasks/update", nil)
+			req.Header.Set("Authorization", "Bearer token")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if gotUser != alice {
+				t.Errorf("ContextUser() = %v, want %v", gotUser, alice)
+			}
+			if gotRevert != tt.revert {
+				t.Errorf("isRevert() = %v, want %v", gotRevert, tt.revert)
+			}
+		})
+	}
+
+	if user := auth.ContextUser(context.Background()); user != nil {
+		t.Errorf("ContextUser() of empty context = %v, want nil", user)
+	}
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+// Parses `<prefix>_tasktype` and `<prefix>_id` form values and checks that task belongs to username
+func parseOwnTaskRef(ctx context.Context, r *http.Request, username, prefix string) (tasks.TaskRef, error) {
+	id, err := strconv.Atoi(r.Form.Get(prefix + "_id"))
+	if err != nil {
+		return tasks.TaskRef{}, middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid " + prefix + "_id",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	kind := r.Form.Get(prefix + "_tasktype")
+	if _, _, err := getOwnTask(ctx, username, kind, id); err != nil {
+		return tasks.TaskRef{}, err
+	}
+
+	return tasks.TaskRef{Kind: kind, ID: id}, nil
+}
+
+// Parses blocker and blocked tasks of dependency from form
+func parseDependency(ctx context.Context, r *http.Request, username string) (tasks.TaskRef, tasks.TaskRef, error) {
+	if err := r.ParseForm(); err != nil {
+		return tasks.TaskRef{}, tasks.TaskRef{}, err
+	}
+
+	blocker, err := parseOwnTaskRef(ctx, r, username, "blocker")
+	if err != nil {
+		return tasks.TaskRef{}, tasks.TaskRef{}, err
+	}
+
+	blocked, err := parseOwnTaskRef(ctx, r, username, "blocked")
+	if err != nil {
+		return tasks.TaskRef{}, tasks.TaskRef{}, err
+	}
+
+	return blocker, blocked, nil
+}
+
+// Returns conflict error if task has blockers which are not done
+func checkBlockers(ctx context.Context, ref tasks.TaskRef) error {
+	blockers, err := database.GetOpenBlockers(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if len(blockers) > 0 {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Task is blocked by tasks which are not done",
+			Code:    http.StatusConflict,
+		}
+	}
+	return nil
+}
+
+func GetDependencies(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	deps, err := database.GetUserDependencies(dctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(deps)
+}
+
+func CreateDependency(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	blocker, blocked, err := parseDependency(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	d := tasks.Dependency{Owner: user.Username, Blocker: blocker, Blocked: blocked}
+	err = database.CreateDependency(dctx, &d)
+	if err == database.ErrDependencyCycle {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: err.Error(),
+			Code:    http.StatusConflict,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(d)
+}
+
+func DeleteDependency(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	blocker, blocked, err := parseDependency(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	err = database.DeleteDependency(dctx, user.Username, blocker, blocked)
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Dependency not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	w.Write([]byte("Successful"))
+	return nil
+}
+
+// Serves result of f applied to open tasks and dependencies of user
+func servePlan(w http.ResponseWriter, r *http.Request, f func([]tasks.TaskNode, []tasks.Dependency) []tasks.PlanItem) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws, err := currentWorkspace(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	open := false
+	userDB, _, err := database.ListUserTasks(dctx, user.Username, database.TaskFilter{Done: &open, Workspace: ws})
+	if err != nil {
+		return err
+	}
+
+	deps, err := database.GetUserDependencies(dctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(f(tasks.UserNodes(userDB), deps))
+}
+
+// Returns open tasks ordered so that every task comes after its blockers
+func Plan(w http.ResponseWriter, r *http.Request) error {
+	return servePlan(w, r, tasks.Plan)
+}
+
+// Returns open tasks without open blockers
+func ActionableTasks(w http.ResponseWriter, r *http.Request) error {
+	return servePlan(w, r, tasks.Actionable)
+}
+
+// Returns the longest chain of dependent tasks ending at a due date
+func CriticalPath(w http.ResponseWriter, r *http.Request) error {
+	return servePlan(w, r, tasks.CriticalPath)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/stream"
+	"github.com/Kry0z1/fancytasks/internal/webhooks"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+func taskEventPayload(event, taskType string, task any) (string, error) {
+	raw, err := json.Marshal(tasks.TaskEvent{
+		Event:      event,
+		TaskType:   taskType,
+		Task:       task,
+		OccurredAt: time.Now(),
+	})
+	return string(raw), err
+}
+
+// Notifies webhooks and live streams of owner about task event unless owner has left workspace of task
+func emitTaskEvent(ctx context.Context, owner, event, taskType string, task any) {
+	if _, err := database.GetWorkspaceMember(ctx, tasks.Base(task).WorkspaceID, owner); err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Couldn't emit %s: %s", event, err.Error())
+		}
+		return
+	}
+
+	payload, err := taskEventPayload(event, taskType, task)
+	if err != nil {
+		log.Printf("Couldn't emit %s: %s", event, err.Error())
+		return
+	}
+
+	webhooks.Emit(ctx, owner, event, payload)
+	stream.Publish(ctx, owner, event, payload)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+const defaultGranularity = 15 * time.Minute
+
+func FreeBusy(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	from, to, err := parseWindow(r)
+	if err != nil {
+		return err
+	}
+
+	q := r.URL.Query()
+
+	granularity := defaultGranularity
+	if q.Has("granularity") {
+		seconds, err := strconv.ParseInt(q.Get("granularity"), 10, 0)
+		if err != nil || seconds <= 0 {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid granularity",
+				Code:    http.StatusBadRequest,
+			}
+		}
+		granularity = time.Duration(seconds) * time.Second
+	}
+
+	username := user.Username
+	if q.Get("user") != "" {
+		username = q.Get("user")
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	allowed, err := database.CanSeeFreeBusy(dctx, username, user.Username)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "User didn't share free/busy with you",
+			Code:    http.StatusForbidden,
+		}
+	}
+
+	userDB, err := database.GetUserAgendaTasks(dctx, username, from, to)
+	if err != nil {
+		return err
+	}
+
+	result := tasks.FreeBusy{
+		Username: username,
+		Busy:     tasks.BusyIntervals(tasks.Slots(userDB, from, to), from, to, granularity),
+	}
+	result.Free = tasks.FreeIntervals(result.Busy, from, to)
+
+	if q.Get("working_hours") == "true" {
+		result.Free = tasks.IntersectIntervals(result.Free, tasks.Cfg.FreeBusy.GetWorkingIntervals(from, to))
+	}
+	result.Free = tasks.DropShorter(result.Free, granularity)
+
+	return json.NewEncoder(w).Encode(result)
+}
+
+func GetFreeBusyGrants(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	grantees, err := database.GetFreeBusyGrantees(dctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(grantees)
+}
+
+func GrantFreeBusy(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	grantee := r.Form.Get("username")
+	if grantee == "" || grantee == user.Username {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid username",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	err := database.GrantFreeBusy(dctx, user.Username, grantee)
+	if err == database.ErrUserNotFound {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: "User with such username not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	w.Write([]byte("Successful"))
+	return nil
+}
+
+func RevokeFreeBusy(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	err := database.RevokeFreeBusy(dctx, user.Username, r.Form.Get("username"))
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Grant for such user not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	w.Write([]byte("Successful"))
+	return nil
+}
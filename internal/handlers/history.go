@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+const defaultHistoryLimit = 50
+
+// Marks context of UpdateTask called to revert task to its earlier version
+type contextRevertKey struct{}
+
+func isRevert(ctx context.Context) bool {
+	revert, _ := ctx.Value(contextRevertKey{}).(bool)
+	return revert
+}
+
+type historyPage struct {
+	Entries    []tasks.HistoryEntry `json:"entries"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+func parseHistoryPage(q url.Values) (limit int, cursor int64, err error) {
+	limit = defaultHistoryLimit
+	if q.Has("limit") {
+		if limit, err = strconv.Atoi(q.Get("limit")); err != nil || limit <= 0 || limit > maxLimit {
+			return 0, 0, middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid limit",
+				Code:    http.StatusBadRequest,
+			}
+		}
+	}
+
+	if q.Has("cursor") {
+		if cursor, err = strconv.ParseInt(q.Get("cursor"), 10, 64); err != nil {
+			return 0, 0, middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid cursor",
+				Code:    http.StatusBadRequest,
+			}
+		}
+	}
+	return limit, cursor, nil
+}
+
+func newHistoryPage(entries []tasks.HistoryEntry, limit int) historyPage {
+	page := historyPage{Entries: entries}
+	if len(entries) == limit {
+		page.NextCursor = strconv.FormatInt(entries[len(entries)-1].ID, 10)
+	}
+	return page
+}
+
+// Returns versions of task, the oldest first. Next page starts after `cursor`
+func TaskHistory(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	kind := r.PathValue("kind")
+
+	limit, after, err := parseHistoryPage(r.URL.Query())
+	if err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	if _, _, err := getTask(dctx, user.Username, kind, id, tasks.AccessViewer); err != nil {
+		return err
+	}
+
+	entries, err := database.GetTaskHistory(dctx, tasks.TaskRef{Kind: kind, ID: id}, after, limit)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(newHistoryPage(entries, limit))
+}
+
+// Makes update form setting task to its snapshot. Assignee is not part of it as it has its own endpoint
+func revertForm(entry *tasks.HistoryEntry) (url.Values, error) {
+	var task any
+	switch entry.TaskType {
+	case tasks.KindBaseTask:
+		task = &tasks.BaseTask{}
+	case tasks.KindEvent:
+		task = &tasks.Event{}
+	case tasks.KindTaskWithDeadline:
+		task = &tasks.TaskWithDeadline{}
+	case tasks.KindRepeatingTask:
+		task = &tasks.RepeatingTask{}
+	}
+
+	raw, err := json.Marshal(entry.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, task); err != nil {
+		return nil, err
+	}
+	base := tasks.Base(task)
+
+	form := url.Values{
+		"id":          {strconv.Itoa(entry.TaskID)},
+		"tasktype":    {entry.TaskType},
+		"title":       {base.Title},
+		"description": {base.Description},
+		"priority":    {strconv.Itoa(base.Priority)},
+		"topic_id":    {strconv.Itoa(base.TopicID)},
+		"status":      {base.Status},
+		"tags":        append([]string{}, base.Tags...),
+		"parent_id":   {""},
+	}
+	if base.ParentID != nil {
+		form.Set("parent_id", strconv.Itoa(*base.ParentID))
+		form.Set("parent_tasktype", *base.ParentType)
+	}
+
+	unix := func(t time.Time) string {
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+
+	switch t := task.(type) {
+	case *tasks.Event:
+		form.Set("starts_at", unix(t.StartsAt))
+		form.Set("ends_at", unix(t.EndsAt))
+	case *tasks.TaskWithDeadline:
+		form.Set("deadline", unix(t.Deadline))
+	case *tasks.RepeatingTask:
+		form.Set("starts_at", unix(t.StartsAt))
+		form.Set("ends_at", unix(t.EndsAt))
+		form.Set("period", strconv.FormatInt(t.Period, 10))
+		form.Set("months", strconv.FormatInt(t.Months, 10))
+		form.Set("loop", strconv.FormatInt(t.Loop, 10))
+		form["except"] = []string{}
+		for _, i := range t.Except {
+			form.Add("except", strconv.FormatInt(i, 10))
+		}
+	}
+
+	return form, nil
+}
+
+// Sets task back to its version from history entry `version` as an ordinary update requiring editor access,
+// except that transitions of workflow are not checked. `force` and `children` are passed to the update
+func RevertTask(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	kind := r.PathValue("kind")
+
+	version, err := strconv.ParseInt(r.PathValue("version"), 10, 64)
+	if err != nil {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid version",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	if _, _, err := getTask(dctx, user.Username, kind, id, tasks.AccessEditor); err != nil {
+		return err
+	}
+
+	entry, err := database.GetHistoryEntry(dctx, tasks.TaskRef{Kind: kind, ID: id}, version)
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Version not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	form, err := revertForm(entry)
+	if err != nil {
+		return err
+	}
+	for _, field := range []string{"force", "children"} {
+		if r.Form.Has(field) {
+			form.Set(field, r.Form.Get(field))
+		}
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), contextRevertKey{}, true))
+	r.Form = form
+	return UpdateTask(w, r)
+}
+
+// Returns history of all tasks of workspace, the newest first, to its admins.
+// Filters are `actor`, `tasktype`, `task_id`, `action`, `since` and `until` (unix), next page starts before `cursor`
+func WorkspaceAudit(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+
+	q := r.URL.Query()
+	f := database.AuditFilter{
+		Actor:    q.Get("actor"),
+		TaskType: q.Get("tasktype"),
+		Action:   q.Get("action"),
+	}
+	if f.Limit, f.Before, err = parseHistoryPage(q); err != nil {
+		return err
+	}
+	if q.Has("task_id") {
+		if f.TaskID, err = strconv.Atoi(q.Get("task_id")); err != nil {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid task_id",
+				Code:    http.StatusBadRequest,
+			}
+		}
+	}
+	for name, dest := range map[string]**time.Time{"since": &f.Since, "until": &f.Until} {
+		if !q.Has(name) {
+			continue
+		}
+		unix, err := strconv.ParseInt(q.Get(name), 10, 64)
+		if err != nil {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid " + name,
+				Code:    http.StatusBadRequest,
+			}
+		}
+		t := time.Unix(unix, 0)
+		*dest = &t
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws, err := memberWorkspace(dctx, user.Username, id)
+	if err != nil {
+		return err
+	}
+	if err := requireRole(ws, tasks.RoleAdmin); err != nil {
+		return err
+	}
+
+	entries, err := database.GetWorkspaceHistory(dctx, ws.ID, f)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(newHistoryPage(entries, f.Limit))
+}
@@ -4,7 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
-	"slices"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/Kry0z1/fancytasks/internal/middleware"
@@ -13,6 +14,23 @@ import (
 	"github.com/Kry0z1/fancytasks/pkg/database"
 )
 
+const maxLimit = 1000
+
+// Values of `filter` query parameter
+var filterKinds = map[string]string{
+	"base":     tasks.KindBaseTask,
+	"events":   tasks.KindEvent,
+	"deadline": tasks.KindTaskWithDeadline,
+	"repeat":   tasks.KindRepeatingTask,
+}
+
+type tasksPage struct {
+	*tasks.User
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Tasks of the page linked by parents, present if request has tree=true
+	Tree []tasks.TaskNode `json:"tree,omitempty"`
+}
+
 func Me(w http.ResponseWriter, r *http.Request) error {
 	user := auth.ContextUser(r.Context())
 	if user == nil {
@@ -23,39 +41,154 @@ func Me(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
-	filter := r.URL.Query()["filter"]
-	var userDB *tasks.User = &tasks.User{Username: user.Username}
-	var err error
+	filter, err := parseTaskFilter(r.URL.Query())
+	if err != nil {
+		return err
+	}
 
 	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
 	defer cancel()
 
-	var found bool
+	if filter.Workspace, err = currentWorkspace(dctx, r, user.Username); err != nil {
+		return err
+	}
 
-	if slices.Contains(filter, "base") {
-		userDB.BaseTasks, err = database.GetUserBaseTasks(dctx, user.Username)
-		found = true
+	userDB, next, err := database.ListUserTasks(dctx, user.Username, filter)
+	if err == database.ErrInvalidCursor || err == database.ErrInvalidSort {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
 	}
-	if slices.Contains(filter, "events") {
-		userDB.Events, err = database.GetUserEvents(dctx, user.Username)
-		found = true
+	if err != nil {
+		return err
 	}
-	if slices.Contains(filter, "repeat") {
-		userDB.RepeatingTasks, err = database.GetUserRepeatingTasks(dctx, user.Username)
-		found = true
+
+	page := tasksPage{User: userDB, NextCursor: next}
+	if r.URL.Query().Get("tree") == "true" {
+		page.Tree = tasks.BuildTree(tasks.UserNodes(userDB))
 	}
-	if slices.Contains(filter, "deadline") {
-		userDB.TasksWithDeadline, err = database.GetUserTasksWithDeadline(dctx, user.Username)
-		found = true
+
+	return json.NewEncoder(w).Encode(page)
+}
+
+func parseTaskFilter(q url.Values) (database.TaskFilter, error) {
+	var f database.TaskFilter
+	var err error
+
+	for _, name := range q["filter"] {
+		if kind, ok := filterKinds[name]; ok {
+			f.Kinds = append(f.Kinds, kind)
+		}
 	}
 
-	if !found {
-		userDB, err = database.GetUserWithTasks(dctx, user.Username)
+	if q.Has("limit") {
+		if f.Limit, err = strconv.Atoi(q.Get("limit")); err != nil || f.Limit <= 0 || f.Limit > maxLimit {
+			return f, middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid limit",
+				Code:    http.StatusBadRequest,
+			}
+		}
 	}
 
-	if err != nil {
-		return err
+	// Shared tasks are listed along with own ones unless asked otherwise
+	switch q.Get("shared") {
+	case "":
+		f.Scope = database.ScopeVisible
+	case "only":
+		f.Scope = database.ScopeShared
+	case "false":
+		f.Scope = database.ScopeOwn
+	default:
+		return f, middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid shared",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	f.Cursor = q.Get("cursor")
+	f.Sort = q.Get("sort")
+
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		f.Desc = true
+	default:
+		return f, middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid order",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	if q.Has("done") {
+		done := q.Get("done") == "true"
+		f.Done = &done
+	}
+
+	f.Status = q.Get("status")
+	f.Assignee = q.Get("assignee")
+	f.Topic = q.Get("topic")
+	f.Subtopics = q.Get("subtopics") == "true"
+
+	tags := []struct {
+		name string
+		dst  *[]string
+	}{
+		{"tag", &f.AllTags},
+		{"any_tag", &f.AnyTags},
+		{"not_tag", &f.NoTags},
+	}
+	for _, t := range tags {
+		if *t.dst, err = parseTagNames(q[t.name]); err != nil {
+			return f, err
+		}
+	}
+	if q.Has("topic_id") {
+		if f.TopicID, err = strconv.Atoi(q.Get("topic_id")); err != nil {
+			return f, middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid topic_id",
+				Code:    http.StatusBadRequest,
+			}
+		}
+	}
+
+	bounds := []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"deadline_from", &f.DeadlineFrom},
+		{"deadline_to", &f.DeadlineTo},
+		{"starts_from", &f.StartsFrom},
+		{"starts_to", &f.StartsTo},
+	}
+	for _, b := range bounds {
+		if *b.dst, err = parseUnixParam(q, b.name); err != nil {
+			return f, err
+		}
+	}
+
+	return f, nil
+}
+
+// Returns zero time if parameter is absent
+func parseUnixParam(q url.Values, name string) (time.Time, error) {
+	if !q.Has(name) {
+		return time.Time{}, nil
+	}
+
+	unix, err := strconv.ParseInt(q.Get(name), 10, 0)
+	if err != nil || unix < 0 {
+		return time.Time{}, middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid " + name,
+			Code:    http.StatusBadRequest,
+		}
 	}
 
-	return json.NewEncoder(w).Encode(userDB)
+	return time.Unix(unix, 0), nil
 }
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+// Returns open tasks ranked by what should be done next using weights from user settings
+func NextTasks(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	q := r.URL.Query()
+	limit := tasks.Cfg.Next.Limit
+	if q.Has("limit") {
+		var err error
+		if limit, err = strconv.Atoi(q.Get("limit")); err != nil || limit <= 0 || limit > maxLimit {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid limit",
+				Code:    http.StatusBadRequest,
+			}
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws, err := currentWorkspace(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	open := false
+	userDB, _, err := database.ListUserTasks(dctx, user.Username, database.TaskFilter{Done: &open, Workspace: ws})
+	if err != nil {
+		return err
+	}
+
+	deps, err := database.GetUserDependencies(dctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	settings, err := database.GetUserSettings(dctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	items := tasks.NextUp(tasks.UserNodes(userDB), deps, *effectiveSettings(settings).NextWeights, time.Now())
+	if len(items) > limit {
+		items = items[:limit]
+	}
+
+	return json.NewEncoder(w).Encode(items)
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+)
+
+// Parses integer path value
+func pathID(r *http.Request, name string) (int, error) {
+	id, err := strconv.Atoi(r.PathValue(name))
+	if err != nil {
+		return 0, middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid " + name,
+			Code:    http.StatusBadRequest,
+		}
+	}
+	return id, nil
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/quickadd"
+)
+
+// Creates task from `text` like "Call Bob tomorrow 15:00-15:30 #work !high", times in it are read in `tz`
+// or the configured timezone. With `dry_run=true` returns what text was parsed into without creating task.
+// Other fields like `workspace`, `description` or `parent_id` are handled as by CreateTask
+func QuickAddTask(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	text := strings.TrimSpace(r.Form.Get("text"))
+	if text == "" {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Missing text",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	loc := tasks.Cfg.FreeBusy.GetLocation()
+	if tz := r.Form.Get("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid tz",
+				Code:    http.StatusBadRequest,
+			}
+		}
+	}
+
+	result := quickadd.Parse(text, time.Now().In(loc))
+	if r.Form.Get("dry_run") == "true" {
+		return json.NewEncoder(w).Encode(result)
+	}
+
+	if result.Title == "" {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Missing task title",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	unix := func(t *time.Time) string {
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+
+	r.Form.Set("tasktype", result.TaskType)
+	r.Form.Set("title", result.Title)
+	r.Form.Set("priority", strconv.Itoa(result.Priority))
+	if result.Topic != "" {
+		r.Form.Del("topic_id")
+		r.Form.Set("topic", result.Topic)
+	}
+	if len(result.Tags) > 0 {
+		r.Form["tags"] = result.Tags
+	}
+
+	switch result.TaskType {
+	case tasks.KindEvent:
+		r.Form.Set("starts_at", unix(result.StartsAt))
+		r.Form.Set("ends_at", unix(result.EndsAt))
+	case tasks.KindTaskWithDeadline:
+		r.Form.Set("deadline", unix(result.Deadline))
+	case tasks.KindRepeatingTask:
+		r.Form.Set("starts_at", unix(result.StartsAt))
+		r.Form.Set("ends_at", unix(result.EndsAt))
+		r.Form.Set("period", strconv.FormatInt(result.Period, 10))
+		r.Form.Set("months", strconv.FormatInt(result.Months, 10))
+		r.Form.Set("loop", strconv.FormatInt(result.Loop, 10))
+		r.Form.Del("except")
+		for _, i := range result.Except {
+			r.Form.Add("except", strconv.FormatInt(i, 10))
+		}
+	}
+
+	return CreateTask(w, r)
+}
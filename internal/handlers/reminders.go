@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+	"github.com/Kry0z1/fancytasks/pkg/notify"
+)
+
+func GetReminders(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	reminders, err := database.GetUserReminders(dctx, user.Username, r.URL.Query().Get("status"))
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(reminders)
+}
+
+func CreateReminder(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(r.Form.Get("id"))
+	if err != nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid id",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	reminder := tasks.Reminder{
+		Owner:    user.Username,
+		TaskType: r.Form.Get("tasktype"),
+		TaskID:   id,
+		Channel:  r.Form.Get("channel"),
+		Target:   r.Form.Get("target"),
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	if err := parseReminderChannel(dctx, &reminder); err != nil {
+		return err
+	}
+
+	task, _, err := getOwnTask(dctx, user.Username, reminder.TaskType, reminder.TaskID)
+	if err != nil {
+		return err
+	}
+
+	if err := parseReminderTime(r, task, &reminder); err != nil {
+		return err
+	}
+
+	if err := database.CreateReminder(dctx, &reminder); err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(reminder)
+}
+
+func parseReminderChannel(ctx context.Context, reminder *tasks.Reminder) error {
+	switch reminder.Channel {
+	case "":
+		reminder.Channel = tasks.ChannelLog
+	case tasks.ChannelLog:
+	case tasks.ChannelEmail:
+		if reminder.Target == "" {
+			return middleware.HTTPError{
+				Err:     nil,
+				Message: "Missing target",
+				Code:    http.StatusBadRequest,
+			}
+		}
+		addr, err := mail.ParseAddress(reminder.Target)
+		if err != nil {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid target",
+				Code:    http.StatusBadRequest,
+			}
+		}
+		reminder.Target = addr.Address
+	case tasks.ChannelWebhook:
+		if err := notify.CheckPublicURL(ctx, reminder.Target); err != nil {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid target",
+				Code:    http.StatusBadRequest,
+			}
+		}
+	default:
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid channel",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	return nil
+}
+
+// Reminder is either at absolute time `at` or `offset` seconds before deadline or start of task.
+// Reminder with offset for repeating task without `occurrence` fires for every occurrence
+func parseReminderTime(r *http.Request, task any, reminder *tasks.Reminder) error {
+	if r.Form.Has("occurrence") {
+		occurrence, err := strconv.ParseInt(r.Form.Get("occurrence"), 10, 0)
+		rt, ok := task.(*tasks.RepeatingTask)
+		if err != nil || occurrence < 0 || !ok || rt.Skipped(occurrence) {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid occurrence",
+				Code:    http.StatusBadRequest,
+			}
+		}
+		reminder.Occurrence = &occurrence
+	}
+
+	if r.Form.Has("at") {
+		at, err := strconv.ParseInt(r.Form.Get("at"), 10, 0)
+		if err != nil || at < 0 {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid at",
+				Code:    http.StatusBadRequest,
+			}
+		}
+		reminder.RemindAt = time.Unix(at, 0)
+		return nil
+	}
+
+	offset, err := strconv.ParseInt(r.Form.Get("offset"), 10, 0)
+	if err != nil || offset < 0 {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid at or offset",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	reminder.Offset = &offset
+	before := time.Duration(offset) * time.Second
+
+	switch t := task.(type) {
+	case *tasks.TaskWithDeadline:
+		reminder.RemindAt = t.Deadline.Add(-before)
+	case *tasks.Event:
+		reminder.RemindAt = t.StartsAt.Add(-before)
+	case *tasks.RepeatingTask:
+		if reminder.Occurrence != nil {
+			reminder.RemindAt = t.Occurrence(*reminder.Occurrence).StartsAt.Add(-before)
+			return nil
+		}
+
+		o, ok := t.OccurrenceAfter(time.Now().Add(before))
+		if !ok {
+			return middleware.HTTPError{
+				Err:     nil,
+				Message: "Task has no upcoming occurrences",
+				Code:    http.StatusBadRequest,
+			}
+		}
+		reminder.Occurrence = &o.Index
+		reminder.RemindAt = o.StartsAt.Add(-before)
+		reminder.Recurring = true
+	default:
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Task has no time to remind before",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	return nil
+}
+
+func SnoozeReminder(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	var until time.Time
+	if r.Form.Has("until") {
+		unix, err := strconv.ParseInt(r.Form.Get("until"), 10, 0)
+		if err != nil || unix < 0 {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid until",
+				Code:    http.StatusBadRequest,
+			}
+		}
+		until = time.Unix(unix, 0)
+	} else {
+		seconds, err := strconv.ParseInt(r.Form.Get("duration"), 10, 0)
+		if err != nil || seconds <= 0 {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid until or duration",
+				Code:    http.StatusBadRequest,
+			}
+		}
+		until = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	reminder, err := database.SnoozeReminder(dctx, user.Username, id, until)
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Reminder with such id not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(reminder)
+}
+
+func DismissReminder(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	reminder, err := database.DismissReminder(dctx, user.Username, id)
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Reminder with such id not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(reminder)
+}
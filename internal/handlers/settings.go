@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+// Fills settings user hasn't set from config
+func effectiveSettings(s *tasks.UserSettings) *tasks.UserSettings {
+	result := *s
+	if result.NextWeights == nil {
+		weights := tasks.Cfg.Next.Weights
+		result.NextWeights = &weights
+	}
+	return &result
+}
+
+func GetSettings(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	settings, err := database.GetUserSettings(dctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(effectiveSettings(settings))
+}
+
+// Updates settings present in form. reset_weights=true restores default weights
+func UpdateSettings(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	settings, err := database.GetUserSettings(dctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	if r.Form.Get("reset_weights") == "true" {
+		settings.NextWeights = nil
+	}
+
+	weights := *effectiveSettings(settings).NextWeights
+	fields := []struct {
+		name string
+		dst  *float64
+	}{
+		{"weight_priority", &weights.Priority},
+		{"weight_deadline", &weights.Deadline},
+		{"weight_event", &weights.Event},
+		{"weight_age", &weights.Age},
+		{"weight_blocked", &weights.Blocked},
+	}
+	changed := false
+	for _, f := range fields {
+		if !r.Form.Has(f.name) {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(r.Form.Get(f.name), 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid " + f.name,
+				Code:    http.StatusBadRequest,
+			}
+		}
+		*f.dst = v
+		changed = true
+	}
+	if changed {
+		settings.NextWeights = &weights
+	}
+
+	if err := database.SaveUserSettings(dctx, user.Username, settings); err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(effectiveSettings(settings))
+}
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+// Reads shared task from `tasktype` and `id` or topic from `topic_id` and checks that
+// username has at least given access to it. Returned share has Owner, Task or TopicID set
+func parseShareTarget(ctx context.Context, form url.Values, username, level string) (*tasks.Share, error) {
+	if form.Has("topic_id") {
+		id, err := strconv.Atoi(form.Get("topic_id"))
+		if err != nil {
+			return nil, middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid topic_id",
+				Code:    http.StatusBadRequest,
+			}
+		}
+
+		owner, access, err := database.GetTopicAccess(ctx, username, id)
+		if err == sql.ErrNoRows {
+			return nil, middleware.HTTPError{
+				Err:     nil,
+				Message: "Topic not found",
+				Code:    http.StatusNotFound,
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := accessError(access, level); err != nil {
+			return nil, err
+		}
+
+		return &tasks.Share{Owner: owner, TopicID: &id}, nil
+	}
+
+	id, err := strconv.Atoi(form.Get("id"))
+	if err != nil {
+		return nil, middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid id",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	kind := form.Get("tasktype")
+	_, base, err := getTask(ctx, username, kind, id, level)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tasks.Share{Owner: base.Owner, Task: &tasks.TaskRef{Kind: kind, ID: id}}, nil
+}
+
+func parseShareLevel(form url.Values) (string, error) {
+	level := form.Get("level")
+	if !tasks.Grantable(level) {
+		return "", middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid level",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	return level, nil
+}
+
+// Returns access of username to task or topic of share
+func shareAccess(ctx context.Context, username string, s *tasks.Share) (string, error) {
+	if s.Task != nil {
+		return database.GetTaskAccess(ctx, username, *s.Task)
+	}
+	_, access, err := database.GetTopicAccess(ctx, username, *s.TopicID)
+	return access, err
+}
+
+// Loads share which username can manage, that is administer its task or topic
+func getManagedShare(ctx context.Context, r *http.Request, username string) (*tasks.Share, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	share, err := database.GetShare(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, middleware.HTTPError{
+			Err:     nil,
+			Message: "Share not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := shareAccess(ctx, username, share)
+	if err != nil {
+		return nil, err
+	}
+	if err := accessError(access, tasks.AccessAdmin); err != nil {
+		return nil, err
+	}
+
+	return share, nil
+}
+
+// Returns shares of task from `tasktype` and `id` or topic from `topic_id`,
+// or every share of own tasks and topics if neither is given
+func GetShares(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	q := r.URL.Query()
+	var shares []tasks.Share
+	var err error
+	if !q.Has("topic_id") && !q.Has("id") {
+		shares, err = database.GetOwnerShares(dctx, user.Username)
+	} else {
+		var target *tasks.Share
+		if target, err = parseShareTarget(dctx, q, user.Username, tasks.AccessAdmin); err != nil {
+			return err
+		}
+
+		if target.Task != nil {
+			shares, err = database.GetTaskShares(dctx, *target.Task)
+		} else {
+			shares, err = database.GetTopicShares(dctx, *target.TopicID)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(shares)
+}
+
+// Returns shares granted to user by others. Shared tasks themselves are listed by GET /tasks?shared=only
+func SharedWithMe(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	shares, err := database.GetGranteeShares(dctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(shares)
+}
+
+// Shares task or topic with user from `username` at `level`.
+// Sharing with the same user again changes level
+func CreateShare(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	level, err := parseShareLevel(r.Form)
+	if err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	share, err := parseShareTarget(dctx, r.Form, user.Username, tasks.AccessAdmin)
+	if err != nil {
+		return err
+	}
+
+	share.Grantee = r.Form.Get("username")
+	share.Level = level
+	if share.Grantee == "" || share.Grantee == share.Owner {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid username",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	err = database.CreateShare(dctx, share)
+	if err == database.ErrUserNotFound {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: "User not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(share)
+}
+
+func UpdateShare(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	level, err := parseShareLevel(r.Form)
+	if err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	share, err := getManagedShare(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	if err := database.UpdateShareLevel(dctx, share.ID, level); err != nil {
+		return err
+	}
+	share.Level = level
+
+	return json.NewEncoder(w).Encode(share)
+}
+
+// Revokes share. Grantee can also revoke shares granted to them
+func DeleteShare(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	share, err := database.GetShare(dctx, id)
+	if err == nil && share.Grantee != user.Username {
+		share, err = getManagedShare(dctx, r, user.Username)
+	}
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Share not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := database.DeleteShare(dctx, share.ID); err != nil {
+		return err
+	}
+
+	w.Write([]byte("Successful"))
+	return nil
+}
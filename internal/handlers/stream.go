@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	"github.com/Kry0z1/fancytasks/internal/stream"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+	"github.com/gorilla/websocket"
+)
+
+const streamBatch = 100
+
+var upgrader = websocket.Upgrader{}
+
+// Returns id of last event client has seen. Without one client gets only new events
+func lastEventID(ctx context.Context, r *http.Request, username string) (int64, error) {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("last_event_id")
+	}
+	if raw == "" {
+		return database.GetLastTaskEventID(ctx, username)
+	}
+
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid Last-Event-ID",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	return id, nil
+}
+
+// Sends events of user after lastID and then new ones until ctx is done
+func streamEvents(
+	ctx context.Context,
+	hub *stream.Hub,
+	username string,
+	lastID int64,
+	send func(database.StoredTaskEvent) error,
+	ping func() error,
+) error {
+	wake, unsubscribe := hub.Subscribe(username)
+	defer unsubscribe()
+
+	heartbeat := time.NewTicker(tasks.Cfg.Stream.GetHeartbeat())
+	defer heartbeat.Stop()
+
+	for {
+		for {
+			events, err := database.GetTaskEventsAfter(ctx, username, lastID, streamBatch)
+			if err != nil {
+				return err
+			}
+
+			for _, e := range events {
+				if err := send(e); err != nil {
+					return err
+				}
+				lastID = e.ID
+			}
+
+			if len(events) < streamBatch {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wake:
+		case <-heartbeat.C:
+			if err := ping(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Streams task events of user as Server-Sent Events
+func Stream(hub *stream.Hub) func(http.ResponseWriter, *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		user := auth.ContextUser(r.Context())
+		if user == nil {
+			return middleware.HTTPError{
+				Err:     nil,
+				Message: "Unauthorized",
+				Code:    http.StatusUnauthorized,
+			}
+		}
+
+		lastID, err := lastEventID(r.Context(), r, user.Username)
+		if err != nil {
+			return err
+		}
+
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		if err := rc.Flush(); err != nil {
+			return err
+		}
+
+		send := func(e database.StoredTaskEvent) error {
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Event, e.Payload); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		ping := func() error {
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+
+		// Response is already started, so errors can only be logged
+		if err := streamEvents(r.Context(), hub, user.Username, lastID, send, ping); err != nil {
+			log.Printf("Stream of %s closed: %s", user.Username, err.Error())
+		}
+		return nil
+	}
+}
+
+type streamMessage struct {
+	ID    int64           `json:"id"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Streams task events of user as JSON messages over WebSocket
+func StreamWebSocket(hub *stream.Hub) func(http.ResponseWriter, *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		user := auth.ContextUser(r.Context())
+		if user == nil {
+			return middleware.HTTPError{
+				Err:     nil,
+				Message: "Unauthorized",
+				Code:    http.StatusUnauthorized,
+			}
+		}
+
+		lastID, err := lastEventID(r.Context(), r, user.Username)
+		if err != nil {
+			return err
+		}
+
+		// Upgrader replies with error itself
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return nil
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		// Client messages are ignored, reading is needed to handle close and pong frames
+		go func() {
+			defer cancel()
+			for {
+				if _, _, err := conn.NextReader(); err != nil {
+					return
+				}
+			}
+		}()
+
+		send := func(e database.StoredTaskEvent) error {
+			return conn.WriteJSON(streamMessage{e.ID, e.Event, json.RawMessage(e.Payload)})
+		}
+		ping := func() error {
+			return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second))
+		}
+
+		if err := streamEvents(ctx, hub, user.Username, lastID, send, ping); err != nil {
+			log.Printf("Stream of %s closed: %s", user.Username, err.Error())
+		}
+		return nil
+	}
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+// Sets parent of task from `parent_tasktype` and `parent_id` form values.
+// Empty parent_id makes task top-level
+func parseParent(ctx context.Context, r *http.Request, task *tasks.BaseTask) error {
+	if !r.Form.Has("parent_id") {
+		return nil
+	}
+
+	if r.Form.Get("parent_id") == "" {
+		task.SetParent(nil)
+		return nil
+	}
+
+	id, err := strconv.Atoi(r.Form.Get("parent_id"))
+	if err != nil {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid parent_id",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	parentKind := r.Form.Get("parent_tasktype")
+	_, base, err := getOwnTask(ctx, task.Owner, parentKind, id)
+	if err != nil {
+		return err
+	}
+	if base.WorkspaceID != task.WorkspaceID {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Parent task is in another workspace",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	task.SetParent(&tasks.TaskRef{Kind: parentKind, ID: id})
+	return nil
+}
+
+// Returns error if parent of task being updated is the task itself or one of its descendants
+func checkCycle(ctx context.Context, u *database.TaskUpdate, task *tasks.BaseTask) error {
+	if task.ParentID == nil {
+		return nil
+	}
+
+	parent := tasks.TaskRef{Kind: *task.ParentType, ID: *task.ParentID}
+	cycle, err := u.WouldCreateCycle(ctx, parent, task.WorkspaceID)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Task cannot be nested into itself or its descendants",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	return nil
+}
+
+// Returns policy from `children` form value or def if it is absent
+func childrenPolicy(r *http.Request, def string) (string, error) {
+	policy := r.Form.Get("children")
+	if policy == "" {
+		policy = def
+	}
+
+	switch policy {
+	case tasks.ChildrenCascade, tasks.ChildrenOrphan, tasks.ChildrenBlock:
+		return policy, nil
+	}
+
+	return "", middleware.HTTPError{
+		Err:     nil,
+		Message: "Invalid children policy",
+		Code:    http.StatusBadRequest,
+	}
+}
+
+// Returns conflict error if policy is block and task has descendants.
+// If openOnly is set, only not done descendants are taken into account
+func checkChildren(ctx context.Context, policy string, ref tasks.TaskRef, openOnly bool) error {
+	if policy != tasks.ChildrenBlock {
+		return nil
+	}
+
+	has, err := database.HasTaskDescendants(ctx, ref, openOnly)
+	if err != nil {
+		return err
+	}
+	if has {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Task has subtasks",
+			Code:    http.StatusConflict,
+		}
+	}
+
+	return nil
+}
+
+// Applies policy to descendants of task moved to trash at given time.
+// Returned function notifies about changed descendants and should be called once deletion is committed
+func deleteChildren(ctx context.Context, u *database.TaskUpdate, owner, policy string, at time.Time) (func(context.Context), error) {
+	switch policy {
+	case tasks.ChildrenCascade:
+		nodes, err := u.DeleteDescendants(ctx, at)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) {
+			emitNodes(ctx, owner, tasks.EventTaskDeleted, nodes)
+		}, nil
+	case tasks.ChildrenOrphan:
+		nodes, err := u.DetachChildren(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) {
+			emitNodes(ctx, owner, tasks.EventTaskUpdated, nodes)
+		}, nil
+	}
+	return func(context.Context) {}, nil
+}
+
+// Applies policy to descendants of task completed by update.
+// Returned function notifies about changed descendants and should be called once update is committed
+func completeChildren(ctx context.Context, u *database.TaskUpdate, owner, policy string) (func(context.Context), error) {
+	switch policy {
+	case tasks.ChildrenCascade:
+		nodes, err := u.CompleteDescendants(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) {
+			emitNodes(ctx, owner, tasks.EventTaskUpdated, nodes)
+			emitNodes(ctx, owner, tasks.EventTaskCompleted, nodes)
+		}, nil
+	case tasks.ChildrenOrphan:
+		nodes, err := u.DetachChildren(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) {
+			emitNodes(ctx, owner, tasks.EventTaskUpdated, nodes)
+		}, nil
+	}
+	return func(context.Context) {}, nil
+}
+
+func emitNodes(ctx context.Context, owner, event string, nodes []tasks.TaskNode) {
+	for _, n := range nodes {
+		emitTaskEvent(ctx, owner, event, n.TaskType, n.Task)
+	}
+}
+
+// Returns task with its children and roll-up progress.
+// Grandchildren are included if request has recursive=true
+func TaskChildren(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	kind := r.PathValue("kind")
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	task, _, err := getTask(dctx, user.Username, kind, id, tasks.AccessViewer)
+	if err != nil {
+		return err
+	}
+
+	descendants, err := database.GetTaskDescendants(dctx, tasks.TaskRef{Kind: kind, ID: id})
+	if err != nil {
+		return err
+	}
+
+	node := tasks.BuildTree(append([]tasks.TaskNode{{TaskType: kind, Task: task}}, descendants...))[0]
+
+	if r.URL.Query().Get("recursive") != "true" {
+		for i := range node.Children {
+			node.Children[i].Children = nil
+		}
+	}
+
+	return json.NewEncoder(w).Encode(node)
+}
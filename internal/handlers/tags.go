@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+const (
+	maxTagName          = 64
+	maxTagsPerTask      = 32
+	defaultAutocomplete = 10
+)
+
+// Splits values on commas, trims spaces and drops duplicates and empty names.
+// Returned names are sorted
+func parseTagNames(values []string) ([]string, error) {
+	var names []string
+	for _, v := range values {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if len(name) > maxTagName {
+				return nil, middleware.HTTPError{
+					Err:     nil,
+					Message: "Too long tag",
+					Code:    http.StatusBadRequest,
+				}
+			}
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+	names = slices.Compact(names)
+	if len(names) > maxTagsPerTask {
+		return nil, middleware.HTTPError{
+			Err:     nil,
+			Message: "Too many tags",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	return names, nil
+}
+
+// Sets tags of task from `tags` form values if present
+func parseTaskTags(r *http.Request, task *tasks.BaseTask) (bool, error) {
+	if !r.Form.Has("tags") {
+		return false, nil
+	}
+
+	names, err := parseTagNames(r.Form["tags"])
+	if err != nil {
+		return false, err
+	}
+
+	task.Tags = names
+	return true, nil
+}
+
+// Notifies about created task
+func afterCreate(ctx context.Context, kind string, base *tasks.BaseTask, task any) {
+	emitTaskEvent(ctx, base.Owner, tasks.EventTaskCreated, kind, task)
+}
+
+// Returns tags ordered by usage. If request has prefix, only tags starting with it are returned
+func GetTags(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	tags, err := database.GetUserTags(dctx, user.Username, r.URL.Query().Get("prefix"), 0)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(tags)
+}
+
+// Returns most used tags starting with prefix
+func AutocompleteTags(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	q := r.URL.Query()
+	limit := defaultAutocomplete
+	if q.Has("limit") {
+		var err error
+		if limit, err = strconv.Atoi(q.Get("limit")); err != nil || limit <= 0 || limit > maxLimit {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid limit",
+				Code:    http.StatusBadRequest,
+			}
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	tags, err := database.GetUserTags(dctx, user.Username, q.Get("prefix"), limit)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(tags)
+}
+
+func RenameTag(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	name := strings.TrimSpace(r.Form.Get("name"))
+	if name == "" || len(name) > maxTagName || strings.Contains(name, ",") {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid name",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	tag, err := database.RenameTag(dctx, user.Username, id, name)
+	if err == database.ErrTagExists {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: "Tag with such name already exists, merge them instead",
+			Code:    http.StatusConflict,
+		}
+	}
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Tag not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(tag)
+}
+
+// Moves tasks of tag to tag `into` and deletes it
+func MergeTag(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	into, err := strconv.Atoi(r.Form.Get("into"))
+	if err != nil {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid into",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	if into == id {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Tag cannot be merged into itself",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	err = database.MergeTags(dctx, user.Username, id, into)
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Tag not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	tag, err := database.GetTag(dctx, user.Username, into)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(tag)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
@@ -41,6 +42,7 @@ func CreateTask(w http.ResponseWriter, r *http.Request) error {
 			if err := database.CreateBaseTask(r.Context(), t); err != nil {
 				return err
 			}
+			afterCreate(r.Context(), tasks.KindBaseTask, t, t)
 			return json.NewEncoder(w).Encode(t)
 		}
 	case "event":
@@ -61,6 +63,31 @@ func CreateTask(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws, err := currentWorkspace(dctx, r, task.Owner)
+	if err != nil {
+		return err
+	}
+	if err := requireRole(ws, tasks.RoleMember); err != nil {
+		return err
+	}
+	task.WorkspaceID = ws.ID
+
+	if err := parseTopic(dctx, r, task); err != nil {
+		return err
+	}
+	if err := parseStatus(dctx, r, task); err != nil {
+		return err
+	}
+	if _, err := parseTaskTags(r, task); err != nil {
+		return err
+	}
+	if err := parseParent(dctx, r, task); err != nil {
+		return err
+	}
 	return endFunc(w, r, task)
 }
 
@@ -77,9 +104,10 @@ func createBaseTask(r *http.Request) (*tasks.BaseTask, error) {
 
 	task.Description = r.Form.Get("description")
 	task.Owner = auth.ContextUser(r.Context()).Username
-	task.Topic = r.Form.Get("topic")
-	if task.Topic == "" {
-		task.Topic = "default"
+	task.CreatedAt = time.Now()
+
+	if err := parsePriority(r, &task); err != nil {
+		return nil, err
 	}
 
 	return &task, nil
@@ -127,11 +155,17 @@ func createEvent(w http.ResponseWriter, r *http.Request, t *tasks.BaseTask) erro
 		EndsAt:   time.Unix(endsUnix, 0),
 	}
 
+	conflicts, err := findConflicts(r.Context(), r, t.Owner, []tasks.Slot{result.Slot()})
+	if err != nil {
+		return err
+	}
+
 	if err := database.CreateEvent(r.Context(), &result); err != nil {
 		return err
 	}
+	afterCreate(r.Context(), tasks.KindEvent, &result.BaseTask, &result)
 
-	return json.NewEncoder(w).Encode(result)
+	return json.NewEncoder(w).Encode(eventResponse{&result, conflicts})
 }
 
 func createDeadline(w http.ResponseWriter, r *http.Request, t *tasks.BaseTask) error {
@@ -162,12 +196,13 @@ func createDeadline(w http.ResponseWriter, r *http.Request, t *tasks.BaseTask) e
 	if err := database.CreateTaskWithDeadline(r.Context(), &result); err != nil {
 		return err
 	}
+	afterCreate(r.Context(), tasks.KindTaskWithDeadline, &result.BaseTask, &result)
 
 	return json.NewEncoder(w).Encode(result)
 }
 
 func createRepeatingTask(w http.ResponseWriter, r *http.Request, t *tasks.BaseTask) error {
-	var startsUnix, endsUnix, period, loop int64
+	var startsUnix, endsUnix, period, months, loop int64
 	var err error
 
 	if startsUnix, err = strconv.ParseInt(r.Form.Get("starts_at"), 10, 0); err != nil {
@@ -194,6 +229,17 @@ func createRepeatingTask(w http.ResponseWriter, r *http.Request, t *tasks.BaseTa
 		}
 	}
 
+	// Optional, calendar months replace period if given
+	if r.Form.Has("months") {
+		if months, err = strconv.ParseInt(r.Form.Get("months"), 10, 0); err != nil || months < 0 {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid months",
+				Code:    http.StatusBadRequest,
+			}
+		}
+	}
+
 	if loop, err = strconv.ParseInt(r.Form.Get("loop"), 10, 0); err != nil || loop <= 0 {
 		return middleware.HTTPError{
 			Err:     err,
@@ -233,13 +279,20 @@ func createRepeatingTask(w http.ResponseWriter, r *http.Request, t *tasks.BaseTa
 			EndsAt:   time.Unix(endsUnix, 0),
 		},
 		Period: period,
+		Months: months,
 		Loop:   loop,
 		Except: except,
 	}
 
+	conflicts, err := findConflicts(r.Context(), r, t.Owner, repeatingSlots(&result))
+	if err != nil {
+		return err
+	}
+
 	if err := database.CreateRepeatingTask(r.Context(), &result); err != nil {
 		return err
 	}
+	afterCreate(r.Context(), tasks.KindRepeatingTask, &result.BaseTask, &result)
 
-	return json.NewEncoder(w).Encode(result)
+	return json.NewEncoder(w).Encode(repeatingTaskResponse{&result, conflicts})
 }
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"net/http"
 	"strconv"
 	"time"
@@ -13,12 +14,14 @@ import (
 	"github.com/Kry0z1/fancytasks/pkg/database"
 )
 
+// Moves task to trash and returns it. Its descendants are handled according to `children`
 func DeleteTask(w http.ResponseWriter, r *http.Request) error {
 	var (
 		id       int
 		err      error
-		delete   func() error
+		delete   func() (*database.TaskUpdate, error)
 		baseTask *tasks.BaseTask
+		deleted  any
 	)
 
 	user := auth.ContextUser(r.Context())
@@ -55,20 +58,24 @@ func DeleteTask(w http.ResponseWriter, r *http.Request) error {
 		}
 	case "basetask":
 		var task tasks.BaseTask
-		delete = func() error { return database.DeleteBaseTask(dctx, &task) }
+		delete = func() (*database.TaskUpdate, error) { return database.DeleteBaseTask(dctx, &task) }
 		baseTask = &task
+		deleted = &task
 	case "event":
 		var task tasks.Event
-		delete = func() error { return database.DeleteEvent(dctx, &task) }
+		delete = func() (*database.TaskUpdate, error) { return database.DeleteEvent(dctx, &task) }
 		baseTask = &task.BaseTask
+		deleted = &task
 	case "deadline":
 		var task tasks.TaskWithDeadline
-		delete = func() error { return database.DeleteTaskWithDeadline(dctx, &task) }
+		delete = func() (*database.TaskUpdate, error) { return database.DeleteTaskWithDeadline(dctx, &task) }
 		baseTask = &task.BaseTask
+		deleted = &task
 	case "repeat":
 		var task tasks.RepeatingTask
-		delete = func() error { return database.DeleteRepeatingTask(dctx, &task) }
+		delete = func() (*database.TaskUpdate, error) { return database.DeleteRepeatingTask(dctx, &task) }
 		baseTask = &task.BaseTask
+		deleted = &task
 	default:
 		return middleware.HTTPError{
 			Err:     nil,
@@ -77,8 +84,23 @@ func DeleteTask(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
+	ref := tasks.TaskRef{Kind: taskType, ID: id}
+	if err = checkAccess(dctx, user.Username, ref, tasks.AccessAdmin); err != nil {
+		return err
+	}
+
+	policy, err := childrenPolicy(r, tasks.Cfg.Subtasks.OnDelete)
+	if err != nil {
+		return err
+	}
+	if err = checkChildren(dctx, policy, ref, false); err != nil {
+		return err
+	}
+
+	now := time.Now()
 	baseTask.ID = id
-	err = delete()
+	baseTask.DeletedAt = &now
+	u, err := delete()
 	if err == sql.ErrNoRows {
 		return middleware.HTTPError{
 			Err:     nil,
@@ -89,7 +111,18 @@ func DeleteTask(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
+	defer u.Rollback()
+
+	notifyChildren, err := deleteChildren(dctx, u, baseTask.Owner, policy, now)
+	if err != nil {
+		return err
+	}
+	if err = u.Commit(dctx); err != nil {
+		return err
+	}
+
+	emitTaskEvent(r.Context(), baseTask.Owner, tasks.EventTaskDeleted, taskType, deleted)
+	notifyChildren(r.Context())
 
-	w.Write([]byte("Successful"))
-	return nil
+	return json.NewEncoder(w).Encode(deleted)
 }
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+const defaultSearchLimit = 20
+
+func SearchTasks(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	q := r.URL.Query()
+
+	limit := defaultSearchLimit
+	if q.Has("limit") {
+		var err error
+		if limit, err = strconv.Atoi(q.Get("limit")); err != nil || limit <= 0 || limit > maxLimit {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid limit",
+				Code:    http.StatusBadRequest,
+			}
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws, err := currentWorkspace(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	result, err := database.SearchUserTasks(dctx, user.Username, ws.ID, q.Get("q"), limit)
+	if err == database.ErrEmptyQuery {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: "Missing search query",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := range result {
+		result[i].Snippet = highlightSnippet(result[i].Snippet)
+	}
+
+	return json.NewEncoder(w).Encode(result)
+}
+
+// Escapes text of snippet, which may contain anything user wrote, and wraps matches in <b></b>
+func highlightSnippet(snippet string) string {
+	return strings.NewReplacer(
+		database.SnippetMatchStart, "<b>",
+		database.SnippetMatchStop, "</b>",
+	).Replace(html.EscapeString(snippet))
+}
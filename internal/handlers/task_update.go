@@ -18,11 +18,15 @@ func UpdateTask(w http.ResponseWriter, r *http.Request) error {
 	var (
 		id       int
 		err      error
-		update   func() (func(context.Context) error, error)
-		callback func(context.Context) error
+		update   func() (*database.TaskUpdate, error)
+		u        *database.TaskUpdate
 		parse    func() error
+		check    func() error
 		send     func() error
 		baseTask *tasks.BaseTask
+		updated  any
+
+		conflicts []tasks.Slot
 	)
 
 	user := auth.ContextUser(r.Context())
@@ -60,27 +64,41 @@ func UpdateTask(w http.ResponseWriter, r *http.Request) error {
 	case "basetask":
 		var task tasks.BaseTask
 		baseTask = &task
-		update = func() (func(context.Context) error, error) { return database.UpdateBaseTask(dctx, &task) }
+		updated = &task
+		update = func() (*database.TaskUpdate, error) { return database.UpdateBaseTask(dctx, &task) }
 		parse = func() error { return nil }
+		check = func() error { return nil }
 		send = func() error { return json.NewEncoder(w).Encode(&task) }
 	case "event":
 		var task tasks.Event
 		baseTask = &task.BaseTask
-		update = func() (func(context.Context) error, error) { return database.UpdateEvent(dctx, &task) }
+		updated = &task
+		update = func() (*database.TaskUpdate, error) { return database.UpdateEvent(dctx, &task) }
 		parse = func() error { return parseEvent(r, &task) }
-		send = func() error { return json.NewEncoder(w).Encode(&task) }
+		check = func() error {
+			conflicts, err = findConflicts(dctx, r, user.Username, []tasks.Slot{task.Slot()})
+			return err
+		}
+		send = func() error { return json.NewEncoder(w).Encode(eventResponse{&task, conflicts}) }
 	case "deadline":
 		var task tasks.TaskWithDeadline
 		baseTask = &task.BaseTask
-		update = func() (func(context.Context) error, error) { return database.UpdateTaskWithDeadline(dctx, &task) }
+		updated = &task
+		update = func() (*database.TaskUpdate, error) { return database.UpdateTaskWithDeadline(dctx, &task) }
 		parse = func() error { return parseDeadline(r, &task) }
+		check = func() error { return nil }
 		send = func() error { return json.NewEncoder(w).Encode(&task) }
 	case "repeat":
 		var task tasks.RepeatingTask
 		baseTask = &task.BaseTask
-		update = func() (func(context.Context) error, error) { return database.UpdateRepeatingTask(dctx, &task) }
+		updated = &task
+		update = func() (*database.TaskUpdate, error) { return database.UpdateRepeatingTask(dctx, &task) }
 		parse = func() error { return parseRepeatingTask(r, &task) }
-		send = func() error { return json.NewEncoder(w).Encode(&task) }
+		check = func() error {
+			conflicts, err = findConflicts(dctx, r, user.Username, repeatingSlots(&task))
+			return err
+		}
+		send = func() error { return json.NewEncoder(w).Encode(repeatingTaskResponse{&task, conflicts}) }
 	default:
 		return middleware.HTTPError{
 			Err:     nil,
@@ -90,7 +108,7 @@ func UpdateTask(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	baseTask.ID = id
-	callback, err = update()
+	u, err = update()
 	if err == sql.ErrNoRows {
 		return middleware.HTTPError{
 			Err:     nil,
@@ -101,25 +119,95 @@ func UpdateTask(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
+	defer u.Rollback()
 
-	if baseTask.Owner != user.Username {
-		callback(dctx)
-		return middleware.HTTPError{
-			Err:     nil,
-			Message: "Cannot update tasks of other users",
-			Code:    http.StatusUnauthorized,
-		}
+	ref := tasks.TaskRef{Kind: taskType, ID: id}
+	level := tasks.AccessEditor
+	if assigneeUpdate(r.Form, baseTask, user.Username) {
+		level = tasks.AccessViewer
+	}
+	if err = checkAccess(dctx, user.Username, ref, level); err != nil {
+		return err
 	}
 
+	wasDone := baseTask.Done
 	parseBaseTask(r, baseTask)
+	if err = parsePriority(r, baseTask); err != nil {
+		return err
+	}
+
 	if err = parse(); err != nil {
-		callback(dctx)
 		return err
 	}
 
-	if err = callback(dctx); err != nil {
+	if err = parseTopic(dctx, r, baseTask); err != nil {
+		return err
+	}
+
+	setStatus := parseStatus
+	if isRevert(r.Context()) {
+		setStatus = revertStatus
+	}
+	if err = setStatus(dctx, r, baseTask); err != nil {
+		return err
+	}
+
+	hasTags, err := parseTaskTags(r, baseTask)
+	if err != nil {
+		return err
+	}
+
+	if err = parseParent(dctx, r, baseTask); err != nil {
+		return err
+	}
+	if err = checkCycle(dctx, u, baseTask); err != nil {
+		return err
+	}
+
+	completed := !wasDone && baseTask.Done
+	policy, err := childrenPolicy(r, tasks.Cfg.Subtasks.OnComplete)
+	if err == nil && completed {
+		err = checkChildren(dctx, policy, ref, true)
+	}
+	if err == nil && completed && r.Form.Get("force") != "true" {
+		err = checkBlockers(dctx, ref)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = check(); err != nil {
+		return err
+	}
+
+	notifyChildren := func(context.Context) {}
+	if completed {
+		if notifyChildren, err = completeChildren(dctx, u, baseTask.Owner, policy); err != nil {
+			return err
+		}
+	}
+
+	if hasTags {
+		if err = u.SetTags(dctx, baseTask.Owner, baseTask.Tags); err != nil {
+			return err
+		}
+	}
+	if err = u.Commit(dctx); err != nil {
 		return err
 	}
+
+	if !hasTags {
+		if baseTask.Tags, err = database.GetTaskTags(dctx, ref); err != nil {
+			return err
+		}
+	}
+
+	emitTaskEvent(r.Context(), baseTask.Owner, tasks.EventTaskUpdated, taskType, updated)
+	if completed {
+		emitTaskEvent(r.Context(), baseTask.Owner, tasks.EventTaskCompleted, taskType, updated)
+	}
+	notifyChildren(r.Context())
+
 	return send()
 }
 
@@ -131,14 +219,24 @@ func parseBaseTask(r *http.Request, task *tasks.BaseTask) {
 	if r.Form.Has("description") {
 		task.Description = r.Form.Get("description")
 	}
+}
 
-	if r.Form.Has("done") {
-		task.Done = r.Form.Get("done") == "true"
+func parsePriority(r *http.Request, task *tasks.BaseTask) error {
+	if !r.Form.Has("priority") {
+		return nil
 	}
 
-	if r.Form.Has("topic") {
-		task.Topic = r.Form.Get("topic")
+	priority, err := strconv.Atoi(r.Form.Get("priority"))
+	if err != nil || priority < 0 || priority > tasks.MaxPriority {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid priority",
+			Code:    http.StatusBadRequest,
+		}
 	}
+
+	task.Priority = priority
+	return nil
 }
 
 func parseEvent(r *http.Request, t *tasks.Event) error {
@@ -220,6 +318,7 @@ func parseRepeatingTask(r *http.Request, t *tasks.RepeatingTask) error {
 		startsUnix = t.StartsAt.Unix()
 		endsUnix   = t.EndsAt.Unix()
 		period     = t.Period
+		months     = t.Months
 		loop       = t.Loop
 	)
 	var err error
@@ -244,7 +343,7 @@ func parseRepeatingTask(r *http.Request, t *tasks.RepeatingTask) error {
 		}
 	}
 
-	if r.Form.Has("period_at") {
+	if r.Form.Has("period") {
 		if period, err = strconv.ParseInt(r.Form.Get("period"), 10, 0); err != nil {
 			return middleware.HTTPError{
 				Err:     err,
@@ -254,6 +353,16 @@ func parseRepeatingTask(r *http.Request, t *tasks.RepeatingTask) error {
 		}
 	}
 
+	if r.Form.Has("months") {
+		if months, err = strconv.ParseInt(r.Form.Get("months"), 10, 0); err != nil || months < 0 {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid months",
+				Code:    http.StatusBadRequest,
+			}
+		}
+	}
+
 	if r.Form.Has("loop") {
 		if loop, err = strconv.ParseInt(r.Form.Get("loop"), 10, 0); err != nil || loop <= 0 {
 			return middleware.HTTPError{
@@ -294,6 +403,7 @@ func parseRepeatingTask(r *http.Request, t *tasks.RepeatingTask) error {
 	t.StartsAt = time.Unix(startsUnix, 0)
 	t.EndsAt = time.Unix(endsUnix, 0)
 	t.Period = period
+	t.Months = months
 	t.Loop = loop
 
 	return nil
@@ -0,0 +1,436 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+// Form values with this prefix set variables of instantiated template, like var.name=Alice
+const templateVariablePrefix = "var."
+
+type templateResponse struct {
+	*tasks.Template
+	// Variables to give on instantiation, date is always known
+	Variables []string `json:"variables"`
+}
+
+func newTemplateResponse(t *tasks.Template) templateResponse {
+	return templateResponse{t, t.Task.Variables()}
+}
+
+func parseTemplateName(r *http.Request, t *tasks.Template) error {
+	name := strings.TrimSpace(r.Form.Get("name"))
+	if name == "" || utf8.RuneCountInString(name) > tasks.MaxTemplateName {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid name",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	t.Name = name
+	return nil
+}
+
+// Sets task of template from JSON in `task` form value
+func parseTemplateTask(r *http.Request, t *tasks.Template) error {
+	var task tasks.TemplateTask
+	if err := json.Unmarshal([]byte(r.Form.Get("task")), &task); err != nil {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid task",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	if err := task.Validate(); err != nil {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+	}
+	t.Task = task
+	return nil
+}
+
+// Captures task given by `tasktype` and `id` with its descendants into template task.
+// Times become offsets from the earliest start or deadline among them
+func captureTemplateTask(ctx context.Context, username, kind string, id int) (tasks.TemplateTask, error) {
+	task, _, err := getTask(ctx, username, kind, id, tasks.AccessViewer)
+	if err != nil {
+		return tasks.TemplateTask{}, err
+	}
+
+	descendants, err := database.GetTaskDescendants(ctx, tasks.TaskRef{Kind: kind, ID: id})
+	if err != nil {
+		return tasks.TemplateTask{}, err
+	}
+	if len(descendants)+1 > tasks.MaxTemplateTasks {
+		return tasks.TemplateTask{}, middleware.HTTPError{
+			Err:     tasks.ErrTooLargeTemplate,
+			Message: tasks.ErrTooLargeTemplate.Error(),
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	nodes := append([]tasks.TaskNode{{TaskType: kind, Task: task}}, descendants...)
+	checklists := map[tasks.TaskRef][]string{}
+	for _, n := range nodes {
+		ref := n.Ref()
+		base := tasks.Base(n.Task)
+		if base.Tags, err = database.GetTaskTags(ctx, ref); err != nil {
+			return tasks.TemplateTask{}, err
+		}
+		// Topic is looked up by path when template is instantiated
+		if base.Topic, err = database.GetTopicPath(ctx, base.TopicID); err != nil {
+			return tasks.TemplateTask{}, err
+		}
+
+		items, err := database.GetChecklist(ctx, ref)
+		if err != nil {
+			return tasks.TemplateTask{}, err
+		}
+		for _, item := range items {
+			checklists[ref] = append(checklists[ref], item.Title)
+		}
+	}
+
+	// The captured task comes first, so it is the first root even if it has parent
+	root := tasks.BuildTree(nodes)[0]
+	return tasks.NewTemplateTask(root, tasks.TemplateAnchor(root), checklists), nil
+}
+
+// Loads template from path which belongs to current workspace
+func getTemplate(ctx context.Context, r *http.Request, username string) (*tasks.Template, *tasks.Workspace, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ws, err := currentWorkspace(ctx, r, username)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t, err := database.GetTemplate(ctx, ws.ID, id)
+	if err == sql.ErrNoRows {
+		return nil, nil, middleware.HTTPError{
+			Err:     nil,
+			Message: "Template not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return t, ws, nil
+}
+
+// Loads template from path which user created or administers workspace of
+func getManagedTemplate(ctx context.Context, r *http.Request, username string) (*tasks.Template, error) {
+	t, ws, err := getTemplate(ctx, r, username)
+	if err != nil {
+		return nil, err
+	}
+	if t.Owner != username {
+		if err := requireRole(ws, tasks.RoleAdmin); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Returns templates of current workspace
+func GetTemplates(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws, err := currentWorkspace(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	templates, err := database.GetWorkspaceTemplates(dctx, ws.ID)
+	if err != nil {
+		return err
+	}
+
+	result := make([]templateResponse, 0, len(templates))
+	for i := range templates {
+		result = append(result, newTemplateResponse(&templates[i]))
+	}
+
+	return json.NewEncoder(w).Encode(result)
+}
+
+func GetTemplate(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	t, _, err := getTemplate(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(newTemplateResponse(t))
+}
+
+// Creates template in current workspace from existing task given by `tasktype` and `id`
+// or from template task in JSON given by `task`. Name defaults to title of the task
+func CreateTemplate(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws, err := currentWorkspace(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+	if err := requireRole(ws, tasks.RoleMember); err != nil {
+		return err
+	}
+
+	t := tasks.Template{Owner: user.Username, WorkspaceID: ws.ID}
+	if r.Form.Has("id") {
+		id, err := strconv.Atoi(r.Form.Get("id"))
+		if err != nil {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid id",
+				Code:    http.StatusBadRequest,
+			}
+		}
+		if t.Task, err = captureTemplateTask(dctx, user.Username, r.Form.Get("tasktype"), id); err != nil {
+			return err
+		}
+	} else if err := parseTemplateTask(r, &t); err != nil {
+		return err
+	}
+
+	if !r.Form.Has("name") {
+		r.Form.Set("name", t.Task.Title)
+	}
+	if err := parseTemplateName(r, &t); err != nil {
+		return err
+	}
+
+	if err := database.CreateTemplate(dctx, &t); err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(newTemplateResponse(&t))
+}
+
+// Changes `name` and replaces task with JSON in `task`, both are optional.
+// Allowed to creator of template and admins of workspace
+func UpdateTemplate(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	t, err := getManagedTemplate(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	if r.Form.Has("name") {
+		if err := parseTemplateName(r, t); err != nil {
+			return err
+		}
+	}
+	if r.Form.Has("task") {
+		if err := parseTemplateTask(r, t); err != nil {
+			return err
+		}
+	}
+
+	if err := database.UpdateTemplate(dctx, t); err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(newTemplateResponse(t))
+}
+
+// Allowed to creator of template and admins of workspace
+func DeleteTemplate(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	t, err := getManagedTemplate(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	if err := database.DeleteTemplate(dctx, t.ID); err != nil {
+		return err
+	}
+
+	w.Write([]byte("Successful"))
+	return nil
+}
+
+// Creates tasks of template in its workspace with times relative to `at` (unix seconds, now by default).
+// Every variable of template must be given as var.<name> form value
+func InstantiateTemplate(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	at := time.Now()
+	if r.Form.Has("at") {
+		unix, err := strconv.ParseInt(r.Form.Get("at"), 10, 0)
+		if err != nil || unix < 0 {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid at",
+				Code:    http.StatusBadRequest,
+			}
+		}
+		at = time.Unix(unix, 0)
+	}
+
+	vars := map[string]string{}
+	for key := range r.Form {
+		if name, ok := strings.CutPrefix(key, templateVariablePrefix); ok {
+			vars[name] = r.Form.Get(key)
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	t, ws, err := getTemplate(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+	if err := requireRole(ws, tasks.RoleMember); err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, name := range t.Task.Variables() {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Missing variables: " + strings.Join(missing, ", "),
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	workflows, err := database.GetUserWorkflows(dctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	root := t.Task.Instantiate(at, vars)
+	now := time.Now()
+	prepare := func(instance *tasks.TemplateInstance, topic *tasks.Topic) error {
+		if topic.Archived {
+			return middleware.HTTPError{
+				Err:     nil,
+				Message: "Topic is archived: " + topic.Name,
+				Code:    http.StatusBadRequest,
+			}
+		}
+
+		base := tasks.Base(instance.Task)
+		base.Owner, base.WorkspaceID, base.CreatedAt = user.Username, ws.ID, now
+		base.TopicID, base.Topic = topic.ID, topic.Name
+		base.SetStatus(workflows.For(topic.ID), workflows.For(topic.ID).Initial(), now)
+		return nil
+	}
+
+	err = database.CreateTaskTree(dctx, &root, user.Username, ws.ID, tasks.Cfg.Topics.AutoCreate, prepare)
+	if errors.Is(err, database.ErrTopicNotFound) {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: err.Error(),
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	root.Walk(func(instance, _ *tasks.TemplateInstance) {
+		emitTaskEvent(r.Context(), user.Username, tasks.EventTaskCreated, instance.TaskType, instance.Task)
+	})
+
+	return json.NewEncoder(w).Encode(root.Node())
+}
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+var colorRegexp = regexp.MustCompile(`^(#[0-9a-fA-F]{6})?$`)
+
+const maxTopicName = 128
+const maxIcon = 64
+
+// Sets topic of task from `topic_id` or `topic` form values, looked up in workspace of task.
+// Tasks without topic are put into default one
+func parseTopic(ctx context.Context, r *http.Request, task *tasks.BaseTask) error {
+	var (
+		topic *tasks.Topic
+		id    int
+		err   error
+	)
+
+	switch {
+	case r.Form.Get("topic_id") != "":
+		if id, err = strconv.Atoi(r.Form.Get("topic_id")); err != nil {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid topic_id",
+				Code:    http.StatusBadRequest,
+			}
+		}
+		topic, err = database.GetTopic(ctx, task.WorkspaceID, id)
+	case r.Form.Has("topic") || task.TopicID == 0:
+		name := r.Form.Get("topic")
+		if name == "" {
+			name = tasks.DefaultTopic
+		}
+
+		if len(name) > maxTopicName {
+			return middleware.HTTPError{
+				Err:     nil,
+				Message: "Too long topic",
+				Code:    http.StatusBadRequest,
+			}
+		}
+		// Path of topic from root topic
+		if slices.Contains(strings.Split(name, tasks.TopicPathSeparator), "") {
+			return middleware.HTTPError{
+				Err:     nil,
+				Message: "Invalid topic",
+				Code:    http.StatusBadRequest,
+			}
+		}
+
+		topic, err = database.GetTopicByName(ctx, task.WorkspaceID, name)
+		if err == sql.ErrNoRows && (tasks.Cfg.Topics.AutoCreate || name == tasks.DefaultTopic) {
+			topic, err = database.EnsureTopic(ctx, task.Owner, task.WorkspaceID, name)
+		}
+	default:
+		return nil
+	}
+
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Topic not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if topic.Archived && topic.ID != task.TopicID {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Topic is archived",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	task.TopicID, task.Topic = topic.ID, topic.Name
+	return nil
+}
+
+// Sets fields of topic present in form. Empty parent_id makes topic top-level
+func parseTopicForm(ctx context.Context, r *http.Request, t *tasks.Topic) error {
+	if r.Form.Has("parent_id") {
+		t.ParentID = nil
+	}
+	if r.Form.Get("parent_id") != "" {
+		parentID, err := strconv.Atoi(r.Form.Get("parent_id"))
+		if err != nil {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid parent_id",
+				Code:    http.StatusBadRequest,
+			}
+		}
+
+		_, err = database.GetTopic(ctx, t.WorkspaceID, parentID)
+		if err == sql.ErrNoRows {
+			return middleware.HTTPError{
+				Err:     nil,
+				Message: "Parent topic not found",
+				Code:    http.StatusNotFound,
+			}
+		}
+		if err != nil {
+			return err
+		}
+		t.ParentID = &parentID
+	}
+
+	if r.Form.Has("name") {
+		t.Name = r.Form.Get("name")
+		if strings.Contains(t.Name, tasks.TopicPathSeparator) {
+			return middleware.HTTPError{
+				Err:     nil,
+				Message: "Invalid name",
+				Code:    http.StatusBadRequest,
+			}
+		}
+	}
+	if t.Name == "" || len(t.Name) > maxTopicName {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid name",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	if r.Form.Has("color") {
+		t.Color = r.Form.Get("color")
+	}
+	if !colorRegexp.MatchString(t.Color) {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid color",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	if r.Form.Has("icon") {
+		t.Icon = r.Form.Get("icon")
+	}
+	if len(t.Icon) > maxIcon {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid icon",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	if r.Form.Has("sort_order") {
+		order, err := strconv.Atoi(r.Form.Get("sort_order"))
+		if err != nil {
+			return middleware.HTTPError{
+				Err:     err,
+				Message: "Invalid sort_order",
+				Code:    http.StatusBadRequest,
+			}
+		}
+		t.SortOrder = order
+	}
+
+	if r.Form.Has("archived") {
+		t.Archived = r.Form.Get("archived") == "true"
+	}
+
+	return nil
+}
+
+// Returns topics with counts of open, done and overdue tasks of every topic and its subtree.
+// Archived topics are included if request has archived=true, topics are nested if it has tree=true
+func GetTopics(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws, err := currentWorkspace(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	topics, err := database.GetWorkspaceTopics(dctx, ws.ID, time.Now())
+	if err != nil {
+		return err
+	}
+	tasks.RollUpTopics(topics)
+
+	q := r.URL.Query()
+	if q.Get("archived") != "true" {
+		topics = slices.DeleteFunc(topics, func(t tasks.TopicStats) bool { return t.Archived })
+	}
+	if q.Get("tree") == "true" {
+		topics = tasks.BuildTopicTree(topics)
+	}
+
+	return json.NewEncoder(w).Encode(topics)
+}
+
+func CreateTopic(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws, err := currentWorkspace(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+	if err := requireRole(ws, tasks.RoleMember); err != nil {
+		return err
+	}
+
+	topic := tasks.Topic{Owner: user.Username, WorkspaceID: ws.ID}
+	if err := parseTopicForm(dctx, r, &topic); err != nil {
+		return err
+	}
+
+	err = database.CreateTopic(dctx, &topic)
+	if err == database.ErrTopicExists {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: err.Error(),
+			Code:    http.StatusConflict,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(topic)
+}
+
+// Renames, recolors, reorders, archives or moves topic with its subtree.
+// Only creator of topic and workspace admins can change it
+func UpdateTopic(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws, err := currentWorkspace(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	topic, err := database.GetTopic(dctx, ws.ID, id)
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Topic not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if topic.Owner != user.Username {
+		if err := requireRole(ws, tasks.RoleAdmin); err != nil {
+			return err
+		}
+	}
+
+	if err := parseTopicForm(dctx, r, topic); err != nil {
+		return err
+	}
+
+	err = database.UpdateTopic(dctx, topic)
+	if err == database.ErrTopicExists || err == database.ErrTopicCycle {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: err.Error(),
+			Code:    http.StatusConflict,
+		}
+	}
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Topic not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(topic)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+// Returns error unless username has admin access to task in trash, as deleting it required
+func checkTrashedAccess(ctx context.Context, username string, ref tasks.TaskRef) error {
+	access, err := database.GetTrashedTaskAccess(ctx, username, ref)
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Task with such id not found in trash",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return accessError(access, tasks.AccessAdmin)
+}
+
+// Returns tasks in trash of current workspace which user can restore, the most recently deleted first
+func GetTrash(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws, err := currentWorkspace(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := database.GetTrashedTasks(dctx, user.Username, ws.ID)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(nodes)
+}
+
+// Takes task out of trash along with subtasks deleted with it and returns them, task first
+func RestoreTask(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	ref := tasks.TaskRef{Kind: r.PathValue("kind"), ID: id}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	if err := checkTrashedAccess(dctx, user.Username, ref); err != nil {
+		return err
+	}
+
+	nodes, err := database.RestoreTask(dctx, ref)
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Task with such id not found in trash",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	emitNodes(r.Context(), tasks.Base(nodes[0].Task).Owner, tasks.EventTaskRestored, nodes)
+	return json.NewEncoder(w).Encode(nodes)
+}
+
+// Permanently deletes task in trash along with subtasks deleted with it
+func PurgeTask(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	ref := tasks.TaskRef{Kind: r.PathValue("kind"), ID: id}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	if err := checkTrashedAccess(dctx, user.Username, ref); err != nil {
+		return err
+	}
+
+	nodes, err := database.PurgeTask(dctx, ref)
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Task with such id not found in trash",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	emitNodes(r.Context(), tasks.Base(nodes[0].Task).Owner, tasks.EventTaskPurged, nodes)
+	w.Write([]byte("Successful"))
+	return nil
+}
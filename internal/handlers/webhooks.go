@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+	"github.com/Kry0z1/fancytasks/pkg/notify"
+)
+
+const deliveriesLimit = 100
+
+func GetWebhooks(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	hooks, err := database.GetUserWebhooks(dctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	return json.NewEncoder(w).Encode(hooks)
+}
+
+func CreateWebhook(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	hook := tasks.Webhook{
+		Owner:  user.Username,
+		URL:    r.Form.Get("url"),
+		Secret: r.Form.Get("secret"),
+		Events: r.Form["events"],
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	if err := notify.CheckPublicURL(dctx, hook.URL); err != nil {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid url",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	if len(hook.Events) == 0 {
+		hook.Events = tasks.TaskEvents
+	}
+	for _, event := range hook.Events {
+		if !slices.Contains(tasks.TaskEvents, event) {
+			return middleware.HTTPError{
+				Err:     nil,
+				Message: "Invalid event " + event,
+				Code:    http.StatusBadRequest,
+			}
+		}
+	}
+
+	if hook.Secret == "" {
+		secret := make([]byte, 32)
+		rand.Read(secret)
+		hook.Secret = hex.EncodeToString(secret)
+	}
+
+	if err := database.CreateWebhook(dctx, &hook); err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(hook)
+}
+
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	err = database.DeleteWebhook(dctx, user.Username, id)
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Webhook with such id not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	w.Write([]byte("Successful"))
+	return nil
+}
+
+// Loads webhook from path owned by username
+func getOwnWebhook(ctx context.Context, r *http.Request, username string) (*tasks.Webhook, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	hook, err := database.GetWebhook(ctx, username, id)
+	if err == sql.ErrNoRows {
+		return nil, middleware.HTTPError{
+			Err:     nil,
+			Message: "Webhook with such id not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	return hook, err
+}
+
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	hook, err := getOwnWebhook(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := database.GetWebhookDeliveries(dctx, hook.ID, deliveriesLimit)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(deliveries)
+}
+
+// Schedules delivery of test event to webhook
+func TestWebhook(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	hook, err := getOwnWebhook(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	payload, err := taskEventPayload(tasks.EventWebhookTest, tasks.KindBaseTask, tasks.BaseTask{
+		Title: "Test task",
+		Owner: user.Username,
+		Topic: "default",
+	})
+	if err != nil {
+		return err
+	}
+
+	delivery, err := database.EnqueueWebhookDelivery(dctx, hook.ID, tasks.EventWebhookTest, payload)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(delivery)
+}
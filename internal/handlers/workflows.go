@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+// Sets status of task from `status` form field or, for compatibility, from `done`.
+// New tasks get initial state of workflow. Topic of task must be already known
+func parseStatus(ctx context.Context, r *http.Request, task *tasks.BaseTask) error {
+	workflows, err := database.GetUserWorkflows(ctx, task.Owner)
+	if err != nil {
+		return err
+	}
+	w := workflows.For(task.TopicID)
+
+	status := task.Status
+	switch {
+	case r.Form.Has("status"):
+		status = r.Form.Get("status")
+	case r.Form.Has("done"):
+		if done := r.Form.Get("done") == "true"; done != task.Done {
+			if done {
+				status = w.DoneState()
+			} else {
+				status = w.Initial()
+			}
+		}
+	case status == "":
+		status = w.Initial()
+	}
+
+	if _, ok := w.State(status); !ok && status != task.Status {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unknown status",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	if !w.CanTransition(task.Status, status) {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Transition from " + task.Status + " to " + status + " is not allowed",
+			Code:    http.StatusConflict,
+		}
+	}
+
+	task.SetStatus(w, status, time.Now())
+	return nil
+}
+
+// Sets status of task reverted to its version from history to `status` form value.
+// Transitions of workflow are not checked, as task goes back to status it already had
+func revertStatus(ctx context.Context, r *http.Request, task *tasks.BaseTask) error {
+	workflows, err := database.GetUserWorkflows(ctx, task.Owner)
+	if err != nil {
+		return err
+	}
+	w := workflows.For(task.TopicID)
+
+	status := r.Form.Get("status")
+	if _, ok := w.State(status); !ok && status != task.Status {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Status " + status + " is no longer in workflow",
+			Code:    http.StatusConflict,
+		}
+	}
+
+	task.SetStatus(w, status, time.Now())
+	return nil
+}
+
+// Returns nil if request has no topic_id. Workflows of topics in team workspaces
+// are shared by members and can be changed only by admins
+func parseWorkflowTopic(ctx context.Context, r *http.Request, username string) (*int, error) {
+	if r.Form.Get("topic_id") == "" {
+		return nil, nil
+	}
+
+	ws, err := currentWorkspace(ctx, r, username)
+	if err != nil {
+		return nil, err
+	}
+	if !ws.Personal {
+		if err := requireRole(ws, tasks.RoleAdmin); err != nil {
+			return nil, err
+		}
+	}
+
+	id, err := strconv.Atoi(r.Form.Get("topic_id"))
+	if err != nil {
+		return nil, middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid topic_id",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	if _, err := database.GetTopic(ctx, ws.ID, id); err == sql.ErrNoRows {
+		return nil, middleware.HTTPError{
+			Err:     nil,
+			Message: "Topic not found",
+			Code:    http.StatusNotFound,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &id, nil
+}
+
+// States come in order from `state` values as `name` or `name:done`.
+// Transitions come from `transition` values as `from:to1,to2`, empty list forbids leaving the state
+func parseWorkflow(r *http.Request, w *tasks.Workflow) error {
+	for _, v := range r.Form["state"] {
+		name, flag, _ := strings.Cut(v, ":")
+		w.States = append(w.States, tasks.WorkflowState{
+			Name: strings.TrimSpace(name),
+			Done: strings.TrimSpace(flag) == "done",
+		})
+	}
+
+	w.Transitions = map[string][]string{}
+	for _, v := range r.Form["transition"] {
+		from, to, ok := strings.Cut(v, ":")
+		if !ok {
+			return middleware.HTTPError{
+				Err:     nil,
+				Message: "Invalid transition",
+				Code:    http.StatusBadRequest,
+			}
+		}
+
+		targets := []string{}
+		for _, name := range strings.Split(to, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				targets = append(targets, name)
+			}
+		}
+		w.Transitions[strings.TrimSpace(from)] = targets
+	}
+
+	if err := w.Validate(); err != nil {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: err.Error(),
+			Code:    http.StatusBadRequest,
+		}
+	}
+	return nil
+}
+
+// Returns default workflow of user and workflows of topics
+func GetWorkflows(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	workflows, err := database.GetUserWorkflows(dctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(workflows)
+}
+
+// Replaces workflow of topic from topic_id or default workflow of user if it is absent.
+// Tasks keep their statuses even if new workflow doesn't have them
+func SaveWorkflow(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	workflow := tasks.Workflow{Owner: user.Username}
+	var err error
+	if workflow.TopicID, err = parseWorkflowTopic(dctx, r, user.Username); err != nil {
+		return err
+	}
+	if err := parseWorkflow(r, &workflow); err != nil {
+		return err
+	}
+
+	if err := database.SaveWorkflow(dctx, &workflow); err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(workflow)
+}
+
+// Makes topic from topic_id or user fall back to default workflow
+func DeleteWorkflow(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	topicID, err := parseWorkflowTopic(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	err = database.DeleteWorkflow(dctx, user.Username, topicID)
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Workflow not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	w.Write([]byte("Successful"))
+	return nil
+}
+
+// Returns status changes of task from the oldest
+func TaskTransitions(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	kind := r.PathValue("kind")
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	if _, _, err := getTask(dctx, user.Username, kind, id, tasks.AccessViewer); err != nil {
+		return err
+	}
+
+	transitions, err := database.GetTaskTransitions(dctx, tasks.TaskRef{Kind: kind, ID: id})
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(transitions)
+}
+
+// Returns tasks matching the same filters as GET /tasks grouped into columns by status.
+// Columns follow workflow of topic if it is given and default workflow of user otherwise
+func Board(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	f, err := parseTaskFilter(r.URL.Query())
+	if err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws, err := currentWorkspace(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+	f.Workspace = ws
+
+	workflows, err := database.GetUserWorkflows(dctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	workflow := workflows.Default
+	switch {
+	case f.TopicID != 0:
+		workflow = workflows.For(f.TopicID)
+	case f.Topic != "":
+		topic, err := database.GetTopicByName(dctx, ws.ID, f.Topic)
+		if err == sql.ErrNoRows {
+			return middleware.HTTPError{
+				Err:     nil,
+				Message: "Topic not found",
+				Code:    http.StatusNotFound,
+			}
+		}
+		if err != nil {
+			return err
+		}
+		workflow = workflows.For(topic.ID)
+	}
+
+	userDB, _, err := database.ListUserTasks(dctx, user.Username, f)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(tasks.BuildBoard(workflow, tasks.UserNodes(userDB)))
+}
@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/internal/middleware"
+	"github.com/Kry0z1/fancytasks/internal/middleware/auth"
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+const maxWorkspaceName = 128
+
+// Returns workspace selected by X-Workspace header or `workspace` parameter,
+// personal workspace of username if neither is given
+func currentWorkspace(ctx context.Context, r *http.Request, username string) (*tasks.Workspace, error) {
+	value := r.Header.Get("X-Workspace")
+	if value == "" {
+		value = r.FormValue("workspace")
+	}
+	if value == "" {
+		return database.GetPersonalWorkspace(ctx, username)
+	}
+
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, middleware.HTTPError{
+			Err:     err,
+			Message: "Invalid workspace",
+			Code:    http.StatusBadRequest,
+		}
+	}
+
+	return memberWorkspace(ctx, username, id)
+}
+
+func memberWorkspace(ctx context.Context, username string, id int) (*tasks.Workspace, error) {
+	ws, err := database.GetMemberWorkspace(ctx, username, id)
+	if err == sql.ErrNoRows {
+		return nil, middleware.HTTPError{
+			Err:     nil,
+			Message: "Workspace not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// Returns error if user has lower role in workspace than given one
+func requireRole(ws *tasks.Workspace, role string) error {
+	if tasks.RoleRank(ws.Role) < tasks.RoleRank(role) {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Requires " + role + " role in workspace",
+			Code:    http.StatusForbidden,
+		}
+	}
+	return nil
+}
+
+func parseWorkspaceName(r *http.Request) (string, error) {
+	name := strings.TrimSpace(r.Form.Get("name"))
+	if name == "" || len(name) > maxWorkspaceName {
+		return "", middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid name",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	return name, nil
+}
+
+// Owner role cannot be granted, admins can grant roles below their own
+func parseMemberRole(r *http.Request, ws *tasks.Workspace) (string, error) {
+	role := r.Form.Get("role")
+	if role == "" {
+		role = tasks.RoleMember
+	}
+
+	rank := tasks.RoleRank(role)
+	if rank == 0 || role == tasks.RoleOwner {
+		return "", middleware.HTTPError{
+			Err:     nil,
+			Message: "Invalid role",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	if ws.Role != tasks.RoleOwner && rank >= tasks.RoleRank(ws.Role) {
+		return "", middleware.HTTPError{
+			Err:     nil,
+			Message: "Cannot grant role " + role,
+			Code:    http.StatusForbidden,
+		}
+	}
+	return role, nil
+}
+
+// Loads workspace from path which user administers. Personal workspaces have no members to manage
+func adminWorkspace(ctx context.Context, r *http.Request, username string) (*tasks.Workspace, error) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	ws, err := memberWorkspace(ctx, username, id)
+	if err != nil {
+		return nil, err
+	}
+	if ws.Personal {
+		return nil, middleware.HTTPError{
+			Err:     nil,
+			Message: "Personal workspace cannot be managed",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	if err := requireRole(ws, tasks.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// Returns workspaces user is member of with their roles
+func GetWorkspaces(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	workspaces, err := database.GetUserWorkspaces(dctx, user.Username)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(workspaces)
+}
+
+func CreateWorkspace(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	name, err := parseWorkspaceName(r)
+	if err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws := tasks.Workspace{Name: name, Owner: user.Username}
+	if err := database.CreateWorkspace(dctx, &ws); err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(ws)
+}
+
+func RenameWorkspace(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	name, err := parseWorkspaceName(r)
+	if err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws, err := adminWorkspace(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	if err := database.RenameWorkspace(dctx, ws.ID, name); err != nil {
+		return err
+	}
+	ws.Name = name
+
+	return json.NewEncoder(w).Encode(ws)
+}
+
+func GetWorkspaceMembers(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	if _, err := memberWorkspace(dctx, user.Username, id); err != nil {
+		return err
+	}
+
+	members, err := database.GetWorkspaceMembers(dctx, id)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(members)
+}
+
+// Invites user from `username` with `role`, member by default
+func AddWorkspaceMember(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws, err := adminWorkspace(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	role, err := parseMemberRole(r, ws)
+	if err != nil {
+		return err
+	}
+
+	member := tasks.WorkspaceMember{WorkspaceID: ws.ID, Username: r.Form.Get("username"), Role: role}
+	err = database.AddWorkspaceMember(dctx, &member)
+	if err == database.ErrUserNotFound {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: "User not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err == database.ErrMemberExists {
+		return middleware.HTTPError{
+			Err:     err,
+			Message: err.Error(),
+			Code:    http.StatusConflict,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(member)
+}
+
+// Loads member from path whom user can manage: owner can manage everyone else,
+// admins only members with lower roles
+func managedMember(ctx context.Context, r *http.Request, ws *tasks.Workspace) (*tasks.WorkspaceMember, error) {
+	member, err := database.GetWorkspaceMember(ctx, ws.ID, r.PathValue("username"))
+	if err == sql.ErrNoRows {
+		return nil, middleware.HTTPError{
+			Err:     nil,
+			Message: "Member not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if member.Role == tasks.RoleOwner ||
+		ws.Role != tasks.RoleOwner && tasks.RoleRank(member.Role) >= tasks.RoleRank(ws.Role) {
+		return nil, middleware.HTTPError{
+			Err:     nil,
+			Message: "Cannot manage member with role " + member.Role,
+			Code:    http.StatusForbidden,
+		}
+	}
+	return member, nil
+}
+
+func UpdateWorkspaceMember(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws, err := adminWorkspace(dctx, r, user.Username)
+	if err != nil {
+		return err
+	}
+
+	member, err := managedMember(dctx, r, ws)
+	if err != nil {
+		return err
+	}
+
+	if member.Role, err = parseMemberRole(r, ws); err != nil {
+		return err
+	}
+
+	if err := database.UpdateWorkspaceMember(dctx, member); err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(member)
+}
+
+// Removes member from workspace. Members other than owner can also leave by removing themselves
+func RemoveWorkspaceMember(w http.ResponseWriter, r *http.Request) error {
+	user := auth.ContextUser(r.Context())
+	if user == nil {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Unauthorized",
+			Code:    http.StatusUnauthorized,
+		}
+	}
+
+	id, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	username := r.PathValue("username")
+
+	dctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(time.Second))
+	defer cancel()
+
+	ws, err := memberWorkspace(dctx, user.Username, id)
+	if err != nil {
+		return err
+	}
+
+	if username == user.Username {
+		if ws.Role == tasks.RoleOwner {
+			return middleware.HTTPError{
+				Err:     nil,
+				Message: "Owner cannot leave workspace",
+				Code:    http.StatusBadRequest,
+			}
+		}
+	} else {
+		if ws, err = adminWorkspace(dctx, r, user.Username); err != nil {
+			return err
+		}
+		if _, err := managedMember(dctx, r, ws); err != nil {
+			return err
+		}
+	}
+
+	err = database.RemoveWorkspaceMember(dctx, ws.ID, username)
+	if err == sql.ErrNoRows {
+		return middleware.HTTPError{
+			Err:     nil,
+			Message: "Member not found",
+			Code:    http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	w.Write([]byte("Successful"))
+	return nil
+}
@@ -11,10 +11,12 @@ import (
 	"github.com/Kry0z1/fancytasks/pkg/database"
 )
 
-var contextUser struct{}
+// Key of user in context, typed so that it can't equal keys of other packages
+type contextUserKey struct{}
 
+// Changes of tasks made with returned context are recorded in their history as made by user
 func getPopulatedContextWithUser(ctx context.Context, user *tasks.User) context.Context {
-	return context.WithValue(ctx, &contextUser, user)
+	return database.WithActor(context.WithValue(ctx, contextUserKey{}, user), user.Username)
 }
 
 func CheckUser(ctx context.Context, username, password string, hasher tasks.Hasher) (*tasks.User, error) {
@@ -34,8 +36,10 @@ func CheckUser(ctx context.Context, username, password string, hasher tasks.Hash
 	return user, nil
 }
 
+// Returns nil if ctx has no user
 func ContextUser(ctx context.Context) *tasks.User {
-	return ctx.Value(&contextUser).(*tasks.User)
+	user, _ := ctx.Value(contextUserKey{}).(*tasks.User)
+	return user
 }
 
 func CheckAuth(t Tokenizer) func(http.Handler) http.Handler {
@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"bufio"
 	"log"
+	"net"
 	"net/http"
 )
 
@@ -15,6 +17,16 @@ func (e *ResponseWriterWithStatusCode) WriteHeader(statusCode int) {
 	e.ResponseWriter.WriteHeader(statusCode)
 }
 
+// Allows http.ResponseController to reach Flush and other methods of wrapped writer
+func (e *ResponseWriterWithStatusCode) Unwrap() http.ResponseWriter {
+	return e.ResponseWriter
+}
+
+// Required by websocket upgraders that check for http.Hijacker directly
+func (e *ResponseWriterWithStatusCode) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(e.ResponseWriter).Hijack()
+}
+
 // If status code is not set yet, returns 200
 func (e ResponseWriterWithStatusCode) StatusCode() int {
 	if e.statusCode == 0 {
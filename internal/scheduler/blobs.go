@@ -0,0 +1,37 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/pkg/blob"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+// Removes blobs left without attachments for longer than grace every interval until ctx is done.
+// Safe to run on several instances at once
+func RunBlobCleanup(ctx context.Context, store blob.BlobStore, interval, grace time.Duration, batch int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Keep removing while full batches of orphans get removed
+		for {
+			removed, err := database.DeleteOrphanBlobs(ctx, time.Now().Add(-grace), batch, store.Delete)
+			if err != nil {
+				log.Printf("Couldn't clean up blobs: %s", err.Error())
+				break
+			}
+			if removed < batch {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+	"github.com/Kry0z1/fancytasks/pkg/notify"
+)
+
+// Fires due reminders every interval until ctx is done.
+// Safe to run on several instances at once: every reminder is fired by one of them
+func RunReminders(ctx context.Context, n notify.Notifier, cfg tasks.RemindersConfig) {
+	ticker := time.NewTicker(cfg.GetInterval())
+	defer ticker.Stop()
+
+	timeout := cfg.GetTimeout()
+	// Reminders of batch are sent one by one
+	lease := time.Duration(cfg.Batch)*timeout + time.Minute
+
+	fire := func(ctx context.Context, r tasks.Reminder, task *tasks.BaseTask) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return n.Notify(ctx, reminderNotification(r, task))
+	}
+
+	// Exponential backoff: base, 2*base, 4*base and so on, but not more than a day
+	retryAfter := func(attempts int) time.Duration {
+		delay := cfg.GetBackoff() << (attempts - 1)
+		if delay <= 0 || delay > 24*time.Hour {
+			return 24 * time.Hour
+		}
+		return delay
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Keep firing while there are full batches of due reminders
+		for {
+			claimed, err := database.FireDueReminders(ctx, time.Now(), cfg.Batch, lease, cfg.MaxAttempts, retryAfter, fire)
+			if err != nil {
+				log.Printf("Couldn't fire reminders: %s", err.Error())
+				break
+			}
+			if claimed < cfg.Batch {
+				break
+			}
+		}
+	}
+}
+
+func reminderNotification(r tasks.Reminder, task *tasks.BaseTask) notify.Notification {
+	body := fmt.Sprintf("Reminder about %q", task.Title)
+	if r.Occurrence != nil {
+		body += fmt.Sprintf(" (occurrence %d)", *r.Occurrence)
+	}
+	if task.Description != "" {
+		body += "\n\n" + task.Description
+	}
+
+	return notify.Notification{
+		Channel: r.Channel,
+		Target:  r.Target,
+		Subject: "Reminder: " + task.Title,
+		Body:    body,
+		Data: map[string]any{
+			"reminder": r,
+			"task":     task,
+		},
+	}
+}
@@ -0,0 +1,36 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/pkg/database"
+)
+
+// Permanently deletes tasks kept in trash for longer than retention every interval until ctx is done.
+// Safe to run on several instances at once
+func RunTrashPurge(ctx context.Context, interval, retention time.Duration, batch int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Keep purging while full batches of tasks get purged
+		for {
+			purged, err := database.PurgeTrash(ctx, time.Now().Add(-retention), batch)
+			if err != nil {
+				log.Printf("Couldn't purge trash: %s", err.Error())
+				break
+			}
+			if purged < batch {
+				break
+			}
+		}
+	}
+}
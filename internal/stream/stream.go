@@ -0,0 +1,103 @@
+package stream
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Kry0z1/fancytasks/pkg/database"
+	"github.com/lib/pq"
+)
+
+// Stores event so that every instance can send it to streams of owner
+func Publish(ctx context.Context, owner, event, payload string) {
+	if _, err := database.AppendTaskEvent(ctx, owner, event, payload); err != nil {
+		log.Printf("Couldn't publish %s: %s", event, err.Error())
+	}
+}
+
+// Wakes up local streams of user when events are appended on any instance
+type Hub struct {
+	mu   sync.Mutex
+	subs map[string]map[chan struct{}]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: map[string]map[chan struct{}]struct{}{}}
+}
+
+// Returned channel receives a value when owner may have new events.
+// Returned function should be called to unsubscribe
+func (h *Hub) Subscribe(owner string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	if h.subs[owner] == nil {
+		h.subs[owner] = map[chan struct{}]struct{}{}
+	}
+	h.subs[owner][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs[owner], ch)
+		if len(h.subs[owner]) == 0 {
+			delete(h.subs, owner)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// Wakes every subscriber of owner, or every subscriber at all if owner is empty
+func (h *Hub) wake(owner string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for o, subs := range h.subs {
+		if owner != "" && o != owner {
+			continue
+		}
+		for ch := range subs {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// Listens for appended events and prunes ones older than retention until ctx is done
+func (h *Hub) Run(ctx context.Context, retention time.Duration) {
+	listener := pq.NewListener(database.GetConnString(), time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Task events listener: %s", err.Error())
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(database.TaskEventsChannel); err != nil {
+		log.Printf("Couldn't listen for task events: %s", err.Error())
+	}
+
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.Notify:
+			// nil is sent after reconnect, some notifications may be lost
+			if n == nil {
+				h.wake("")
+			} else {
+				h.wake(n.Extra)
+			}
+		case <-prune.C:
+			if err := database.PruneTaskEvents(ctx, time.Now().Add(-retention)); err != nil {
+				log.Printf("Couldn't prune task events: %s", err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/Kry0z1/fancytasks/pkg/database"
+	"github.com/Kry0z1/fancytasks/pkg/notify"
+)
+
+// Headers of delivery requests
+const (
+	HeaderEvent     = "X-Fancytasks-Event"
+	HeaderDelivery  = "X-Fancytasks-Delivery"
+	HeaderSignature = "X-Fancytasks-Signature"
+)
+
+// Returns value of signature header for payload
+func Sign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Schedules delivery of event payload to webhooks of owner subscribed to it
+func Emit(ctx context.Context, owner, event, payload string) {
+	if err := database.EnqueueWebhookDeliveries(ctx, owner, event, payload); err != nil {
+		log.Printf("Couldn't emit %s: %s", event, err.Error())
+	}
+}
+
+type Dispatcher struct {
+	client   *http.Client
+	interval time.Duration
+	batch    int
+	// Deliveries of batch are made one by one, so claim has to outlast all of them
+	lease       time.Duration
+	maxAttempts int
+	backoff     time.Duration
+}
+
+func NewDispatcher(cfg tasks.WebhooksConfig) *Dispatcher {
+	return &Dispatcher{
+		client:      notify.NewPublicClient(cfg.GetTimeout()),
+		interval:    cfg.GetInterval(),
+		batch:       cfg.Batch,
+		lease:       time.Duration(cfg.Batch)*cfg.GetTimeout() + time.Minute,
+		maxAttempts: cfg.MaxAttempts,
+		backoff:     cfg.GetBackoff(),
+	}
+}
+
+// Delivers pending webhooks every interval until ctx is done.
+// Safe to run on several instances at once: every delivery attempt is made by one of them
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			processed, err := database.ProcessDueWebhookDeliveries(ctx, time.Now(), d.batch, d.lease, d.maxAttempts, d.retryAfter, d.deliver)
+			if err != nil {
+				log.Printf("Couldn't process webhook deliveries: %s", err.Error())
+				break
+			}
+			if processed < d.batch {
+				break
+			}
+		}
+	}
+}
+
+// Exponential backoff: base, 2*base, 4*base and so on, but not more than a day
+func (d *Dispatcher) retryAfter(attempts int) time.Duration {
+	delay := d.backoff << (attempts - 1)
+	if delay <= 0 || delay > 24*time.Hour {
+		return 24 * time.Hour
+	}
+	return delay
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, w tasks.Webhook, delivery tasks.WebhookDelivery) database.DeliveryResult {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		return database.DeliveryResult{Err: err}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(delivery.ID))
+	req.Header.Set(HeaderSignature, Sign(w.Secret, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return database.DeliveryResult{Err: err}
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return database.DeliveryResult{
+			ResponseCode: resp.StatusCode,
+			Err:          fmt.Errorf("Webhook responded with status %d", resp.StatusCode),
+		}
+	}
+
+	return database.DeliveryResult{ResponseCode: resp.StatusCode}
+}
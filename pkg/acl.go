@@ -0,0 +1,48 @@
+package tasks
+
+import "time"
+
+// Access levels, every next one includes previous ones
+const (
+	AccessViewer = "viewer"
+	AccessEditor = "editor"
+	// Can also delete tasks and manage shares
+	AccessAdmin = "admin"
+	// Level of owner, cannot be granted
+	AccessOwner = "owner"
+)
+
+// Indexed by rank, empty level means no access
+var accessLevels = []string{"", AccessViewer, AccessEditor, AccessAdmin, AccessOwner}
+
+func AccessRank(level string) int {
+	for i, l := range accessLevels {
+		if l == level {
+			return i
+		}
+	}
+	return 0
+}
+
+func AccessLevel(rank int) string {
+	if rank < 0 || rank >= len(accessLevels) {
+		return ""
+	}
+	return accessLevels[rank]
+}
+
+// Reports whether level can be granted to other users
+func Grantable(level string) bool {
+	return level == AccessViewer || level == AccessEditor || level == AccessAdmin
+}
+
+// Access of grantee to task or to topic with all its subtopics. Exactly one of Task and TopicID is set
+type Share struct {
+	ID        int       `json:"id"`
+	Owner     string    `json:"owner"`
+	Grantee   string    `json:"grantee"`
+	Task      *TaskRef  `json:"task,omitempty"`
+	TopicID   *int      `json:"topic_id,omitempty"`
+	Level     string    `json:"level"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,87 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+)
+
+var ErrNotFound = errors.New("Blob not found")
+var ErrTooLarge = errors.New("Blob is too large")
+
+// Stores content under keys derived from it by Spool, so equal content is stored once
+type BlobStore interface {
+	// Stores size bytes from r under key unless the key is already stored
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Returns ErrNotFound if there is no such blob
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Deleting missing blob is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// Content saved to temporary file along with its key and sniffed MIME type
+type Spooled struct {
+	// Hex-encoded SHA-256 of content
+	Key  string
+	Size int64
+	MIME string
+	file *os.File
+}
+
+// Copies at most maxSize bytes from r to temporary file.
+// Returns ErrTooLarge if r has more. Returned content should be closed
+func Spool(r io.Reader, maxSize int64) (*Spooled, error) {
+	file, err := os.CreateTemp("", "blob-*")
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	head := &sniffBuffer{}
+	size, err := io.Copy(io.MultiWriter(file, hash, head), io.LimitReader(r, maxSize+1))
+	if err == nil && size > maxSize {
+		err = ErrTooLarge
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	return &Spooled{
+		Key:  hex.EncodeToString(hash.Sum(nil)),
+		Size: size,
+		MIME: http.DetectContentType(head.data),
+		file: file,
+	}, nil
+}
+
+// Returns reader of content from its start
+func (s *Spooled) Reader() (io.Reader, error) {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return s.file, nil
+}
+
+// Removes temporary file
+func (s *Spooled) Close() error {
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
+
+// Keeps first bytes written to it, as many as content sniffing considers
+type sniffBuffer struct {
+	data []byte
+}
+
+func (b *sniffBuffer) Write(p []byte) (int, error) {
+	if rest := 512 - len(b.data); rest > 0 {
+		b.data = append(b.data, p[:min(rest, len(p))]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,75 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+type localStore struct {
+	root string
+}
+
+// Stores blobs as files under root, spread over directories by first two characters of key
+func NewLocalStore(root string) (BlobStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, err
+	}
+	return localStore{root: root}, nil
+}
+
+func (l localStore) path(key string) string {
+	if len(key) < 2 {
+		return filepath.Join(l.root, key)
+	}
+	return filepath.Join(l.root, key[:2], key)
+}
+
+func (l localStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	path := l.path(key)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	// Written to temporary file first so that readers never see partial blob
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+key+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.CopyN(tmp, r, size); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l localStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	file, err := os.Open(l.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (l localStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(l.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package tasks
+
+import (
+	"regexp"
+	"slices"
+	"time"
+)
+
+const MaxCommentBody = 4096
+
+type Comment struct {
+	ID       int    `json:"id"`
+	TaskType string `json:"tasktype"`
+	TaskID   int    `json:"task_id"`
+	Author   string `json:"author"`
+	Body     string `json:"body"`
+	// Mentioned users who could view the task, sorted alphabetically
+	Mentions  []string   `json:"mentions"`
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+}
+
+// Body of comment before it was edited at EditedAt
+type CommentEdit struct {
+	ID        int       `json:"id"`
+	CommentID int       `json:"comment_id"`
+	Body      string    `json:"body"`
+	EditedAt  time.Time `json:"edited_at"`
+}
+
+// @ must not follow a word character so that emails are not taken for mentions
+var mentionRegexp = regexp.MustCompile(`(?:^|[^\w@])@([\w.-]*\w)`)
+
+// Returns distinct usernames mentioned in body as @username, sorted alphabetically
+func ParseMentions(body string) []string {
+	result := []string{}
+	for _, m := range mentionRegexp.FindAllStringSubmatch(body, -1) {
+		result = append(result, m[1])
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// Returns usernames present in mentions but not in previous
+func NewMentions(previous, mentions []string) []string {
+	result := []string{}
+	for _, m := range mentions {
+		if !slices.Contains(previous, m) {
+			result = append(result, m)
+		}
+	}
+	return result
+}
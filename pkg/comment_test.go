@@ -0,0 +1,39 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{"No mentions here", []string{}},
+		{"@alice please look", []string{"alice"}},
+		{"cc @bob, @alice and @bob again", []string{"alice", "bob"}},
+		{"Ask @bob.smith.", []string{"bob.smith"}},
+		{"Thanks @carol-d!", []string{"carol-d"}},
+		{"(@dave) and @erin:@frank", []string{"dave", "erin", "frank"}},
+		{"Mail bob@example.com", []string{}},
+		{"@@bob and a lone @", []string{}},
+		{"line\n@gina", []string{"gina"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			if got := ParseMentions(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMentions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMentions(t *testing.T) {
+	got := NewMentions([]string{"alice", "bob"}, []string{"bob", "carol", "dave"})
+	want := []string{"carol", "dave"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NewMentions() = %v, want %v", got, want)
+	}
+}
@@ -3,13 +3,28 @@ package tasks
 import (
 	"log"
 	"os"
+	"slices"
+	"testing"
 	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	JWT JWTConfig `yaml:"jwt"`
+	JWT         JWTConfig         `yaml:"jwt"`
+	Conflicts   ConflictsConfig   `yaml:"conflicts"`
+	FreeBusy    FreeBusyConfig    `yaml:"freebusy"`
+	Reminders   RemindersConfig   `yaml:"reminders"`
+	SMTP        SMTPConfig        `yaml:"smtp"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks"`
+	Stream      StreamConfig      `yaml:"stream"`
+	Subtasks    SubtasksConfig    `yaml:"subtasks"`
+	Topics      TopicsConfig      `yaml:"topics"`
+	Next        NextConfig        `yaml:"next"`
+	Attachments AttachmentsConfig `yaml:"attachments"`
+	Trash       TrashConfig       `yaml:"trash"`
+	// Used for users and topics without own workflow
+	Workflow Workflow `yaml:"workflow"`
 }
 
 type JWTConfig struct {
@@ -20,11 +35,220 @@ func (j JWTConfig) GetExpiresDelta() time.Duration {
 	return time.Duration(j.ExpiresDelta) * time.Minute
 }
 
+type ConflictsConfig struct {
+	Horizon int `yaml:"horizon"`
+}
+
+// How far ahead occurrences of repeating tasks are checked for overlaps
+func (c ConflictsConfig) GetHorizon() time.Duration {
+	return time.Duration(c.Horizon) * 24 * time.Hour
+}
+
+type FreeBusyConfig struct {
+	Timezone     string             `yaml:"timezone"`
+	WorkingHours WorkingHoursConfig `yaml:"working_hours"`
+}
+
+type WorkingHoursConfig struct {
+	// In format 15:04
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+	// Days of week, 0 is Sunday
+	Days []time.Weekday `yaml:"days"`
+}
+
+// Returns UTC if timezone is not set or unknown
+func (f FreeBusyConfig) GetLocation() *time.Location {
+	loc, err := time.LoadLocation(f.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Returns working intervals intersecting [from, to) sorted by start
+func (f FreeBusyConfig) GetWorkingIntervals(from, to time.Time) []Interval {
+	result := []Interval{}
+	loc := f.GetLocation()
+
+	start, err := time.Parse("15:04", f.WorkingHours.Start)
+	if err != nil {
+		return result
+	}
+	end, err := time.Parse("15:04", f.WorkingHours.End)
+	if err != nil {
+		return result
+	}
+
+	from, to = from.In(loc), to.In(loc)
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !slices.Contains(f.WorkingHours.Days, day.Weekday()) {
+			continue
+		}
+
+		in := Interval{
+			StartsAt: time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, loc),
+			EndsAt:   time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, loc),
+		}
+		in.StartsAt, in.EndsAt = latest(in.StartsAt, from), earliest(in.EndsAt, to)
+		if in.StartsAt.Before(in.EndsAt) {
+			result = append(result, in)
+		}
+	}
+
+	return result
+}
+
+type RemindersConfig struct {
+	Interval    int `yaml:"interval"`
+	Batch       int `yaml:"batch"`
+	Timeout     int `yaml:"timeout"`
+	MaxAttempts int `yaml:"max_attempts"`
+	Backoff     int `yaml:"backoff"`
+}
+
+// How often scheduler looks for due reminders
+func (r RemindersConfig) GetInterval() time.Duration {
+	return time.Duration(r.Interval) * time.Second
+}
+
+// How long sending one reminder may take
+func (r RemindersConfig) GetTimeout() time.Duration {
+	return time.Duration(r.Timeout) * time.Second
+}
+
+// Delay before the first retry, doubled on every next one
+func (r RemindersConfig) GetBackoff() time.Duration {
+	return time.Duration(r.Backoff) * time.Second
+}
+
+type SMTPConfig struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+	From string `yaml:"from"`
+}
+
+type WebhooksConfig struct {
+	Interval    int `yaml:"interval"`
+	Batch       int `yaml:"batch"`
+	Timeout     int `yaml:"timeout"`
+	MaxAttempts int `yaml:"max_attempts"`
+	Backoff     int `yaml:"backoff"`
+}
+
+// How often dispatcher looks for pending deliveries
+func (w WebhooksConfig) GetInterval() time.Duration {
+	return time.Duration(w.Interval) * time.Second
+}
+
+func (w WebhooksConfig) GetTimeout() time.Duration {
+	return time.Duration(w.Timeout) * time.Second
+}
+
+// Delay before the first retry, doubled on every next one
+func (w WebhooksConfig) GetBackoff() time.Duration {
+	return time.Duration(w.Backoff) * time.Second
+}
+
+type StreamConfig struct {
+	Retention int `yaml:"retention"`
+	Heartbeat int `yaml:"heartbeat"`
+}
+
+// How long events are kept for resuming streams
+func (s StreamConfig) GetRetention() time.Duration {
+	return time.Duration(s.Retention) * time.Hour
+}
+
+func (s StreamConfig) GetHeartbeat() time.Duration {
+	return time.Duration(s.Heartbeat) * time.Second
+}
+
+// Policies applied to children of deleted or completed task, one of Children* constants
+type SubtasksConfig struct {
+	OnDelete   string `yaml:"on_delete"`
+	OnComplete string `yaml:"on_complete"`
+}
+
+type TopicsConfig struct {
+	// Unknown topic names given on task creation or update create new topics
+	AutoCreate bool `yaml:"auto_create"`
+}
+
+type NextConfig struct {
+	// Used for users who haven't set their own
+	Weights NextWeights `yaml:"weights"`
+	// Default amount of returned tasks
+	Limit int `yaml:"limit"`
+}
+
+// Access keys of S3 store are taken from S3_ACCESS_KEY and S3_SECRET_KEY environment variables
+type AttachmentsConfig struct {
+	// local or s3
+	Store string      `yaml:"store"`
+	Local LocalConfig `yaml:"local"`
+	S3    S3Config    `yaml:"s3"`
+	// In megabytes
+	MaxSize int `yaml:"max_size"`
+	Quota   int `yaml:"quota"`
+	// How often blobs of deleted attachments are removed
+	CleanupInterval int `yaml:"cleanup_interval"`
+	// How long unreferenced blob is kept so that uploads of the same content can reuse it
+	Grace int `yaml:"grace"`
+	Batch int `yaml:"batch"`
+}
+
+type LocalConfig struct {
+	Root string `yaml:"root"`
+}
+
+type S3Config struct {
+	Endpoint string `yaml:"endpoint"`
+	Region   string `yaml:"region"`
+	Bucket   string `yaml:"bucket"`
+}
+
+func (a AttachmentsConfig) GetMaxSize() int64 {
+	return int64(a.MaxSize) << 20
+}
+
+// Total size of attachments every user can upload
+func (a AttachmentsConfig) GetQuota() int64 {
+	return int64(a.Quota) << 20
+}
+
+func (a AttachmentsConfig) GetCleanupInterval() time.Duration {
+	return time.Duration(a.CleanupInterval) * time.Second
+}
+
+func (a AttachmentsConfig) GetGrace() time.Duration {
+	return time.Duration(a.Grace) * time.Minute
+}
+
+type TrashConfig struct {
+	// How long deleted tasks can be restored before they are purged
+	Retention     int `yaml:"retention"`
+	PurgeInterval int `yaml:"purge_interval"`
+	Batch         int `yaml:"batch"`
+}
+
+func (t TrashConfig) GetRetention() time.Duration {
+	return time.Duration(t.Retention) * 24 * time.Hour
+}
+
+func (t TrashConfig) GetPurgeInterval() time.Duration {
+	return time.Duration(t.PurgeInterval) * time.Second
+}
+
 var Cfg Config
 
 func init() {
 	file, err := os.Open("config.yaml")
 	if err != nil {
+		// Tests run in directories of their packages and set config they need themselves
+		if testing.Testing() {
+			return
+		}
 		log.Fatalf("Failed to find config file: %s", err.Error())
 	}
 
@@ -0,0 +1,133 @@
+package tasks
+
+import (
+	"slices"
+	"time"
+)
+
+// Time interval occupied by event or by occurrence of repeating task
+type Slot struct {
+	TaskType string `json:"tasktype"`
+	ID       int    `json:"id"`
+	Title    string `json:"title"`
+	// Present for repeating tasks only
+	Occurrence *int64    `json:"occurrence,omitempty"`
+	StartsAt   time.Time `json:"starts_at"`
+	EndsAt     time.Time `json:"ends_at"`
+}
+
+type ConflictPair struct {
+	First  Slot `json:"first"`
+	Second Slot `json:"second"`
+}
+
+func (s Slot) sameTask(o Slot) bool {
+	return s.TaskType == o.TaskType && s.ID == o.ID
+}
+
+// Zero-length slots never intersect anything
+func (s Slot) intersects(o Slot) bool {
+	return s.StartsAt.Before(o.EndsAt) && o.StartsAt.Before(s.EndsAt)
+}
+
+func (e Event) Slot() Slot {
+	return Slot{
+		TaskType: KindEvent,
+		ID:       e.ID,
+		Title:    e.Title,
+		StartsAt: e.StartsAt,
+		EndsAt:   e.EndsAt,
+	}
+}
+
+func (t RepeatingTask) Slots(from, to time.Time) []Slot {
+	occurrences := t.Occurrences(from, to)
+	result := make([]Slot, 0, len(occurrences))
+
+	for _, o := range occurrences {
+		result = append(result, Slot{
+			TaskType:   KindRepeatingTask,
+			ID:         t.ID,
+			Title:      t.Title,
+			Occurrence: &o.Index,
+			StartsAt:   o.StartsAt,
+			EndsAt:     o.EndsAt,
+		})
+	}
+
+	return result
+}
+
+// Returns slots of user's events and repeating tasks intersecting [from, to) sorted by start
+func Slots(user *User, from, to time.Time) []Slot {
+	var result []Slot
+
+	for _, e := range user.Events {
+		if Overlaps(e.StartsAt, e.EndsAt, from, to) {
+			result = append(result, e.Slot())
+		}
+	}
+
+	for _, rt := range user.RepeatingTasks {
+		result = append(result, rt.Slots(from, to)...)
+	}
+
+	slices.SortStableFunc(result, func(a, b Slot) int {
+		return a.StartsAt.Compare(b.StartsAt)
+	})
+
+	return result
+}
+
+// Returns slots of existing which intersect any of candidates and belong to other tasks
+func Conflicts(candidates, existing []Slot) []Slot {
+	var result []Slot
+
+	for _, e := range existing {
+		for _, c := range candidates {
+			if !c.sameTask(e) && c.intersects(e) {
+				result = append(result, e)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+// Returns every pair of intersecting slots of different tasks. Slots should be sorted by start
+func ConflictPairs(slots []Slot) []ConflictPair {
+	var result []ConflictPair
+
+	for i, s := range slots {
+		for _, o := range slots[i+1:] {
+			if !o.StartsAt.Before(s.EndsAt) {
+				break
+			}
+			if !s.sameTask(o) && s.intersects(o) {
+				result = append(result, ConflictPair{s, o})
+			}
+		}
+	}
+
+	return result
+}
+
+// Returns smallest interval containing every slot
+func SlotsWindow(slots []Slot) (time.Time, time.Time) {
+	if len(slots) == 0 {
+		return time.Time{}, time.Time{}
+	}
+
+	from, to := slots[0].StartsAt, slots[0].EndsAt
+	for _, s := range slots[1:] {
+		if s.StartsAt.Before(from) {
+			from = s.StartsAt
+		}
+		if s.EndsAt.After(to) {
+			to = s.EndsAt
+		}
+	}
+
+	return from, to
+}
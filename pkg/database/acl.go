@@ -0,0 +1,242 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/lib/pq"
+)
+
+const shareColumns = `id, owner, grantee, task_kind, task_id, topic_id, level, created_at`
+
+func scanShare(s scanner) (tasks.Share, error) {
+	var sh tasks.Share
+	var kind *string
+	var id *int
+	if err := s.Scan(&sh.ID, &sh.Owner, &sh.Grantee, &kind, &id, &sh.TopicID, &sh.Level, &sh.CreatedAt); err != nil {
+		return sh, err
+	}
+	if kind != nil && id != nil {
+		sh.Task = &tasks.TaskRef{Kind: *kind, ID: *id}
+	}
+	return sh, nil
+}
+
+func queryShares(ctx context.Context, query string, args ...any) ([]tasks.Share, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []tasks.Share{}
+	for rows.Next() {
+		sh, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, sh)
+	}
+
+	return result, rows.Err()
+}
+
+// Condition that task in columns kind and id is not in trash and user in placeholder can view it
+func visibleTaskCond(user, kind, id string) string {
+	return "EXISTS (SELECT 1 FROM all_tasks t WHERE t.kind = " + kind + " AND t.id = " + id +
+		" AND task_access(" + user + ", t.kind, t.id, t.owner, t.topic_id, t.assignee) > 0)"
+}
+
+// Returns access level of username to task, empty if there is none.
+// Returns sql.ErrNoRows if there is no such task
+func GetTaskAccess(ctx context.Context, username string, ref tasks.TaskRef) (string, error) {
+	var rank sql.NullInt16
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT
+			task_access($1, kind, id, owner, topic_id, assignee)
+		FROM
+			all_tasks
+		WHERE
+			kind = $2 AND id = $3`,
+		username, ref.Kind, ref.ID,
+	).Scan(&rank)
+	if err != nil {
+		return "", err
+	}
+	return tasks.AccessLevel(int(rank.Int16)), nil
+}
+
+// Returns those of usernames who exist and can view task, sorted alphabetically
+func GetTaskViewers(ctx context.Context, ref tasks.TaskRef, usernames []string) ([]string, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			u.username
+		FROM
+			users u, all_tasks t
+		WHERE
+			t.kind = $1 AND t.id = $2 AND u.username = ANY($3) AND
+			task_access(u.username, t.kind, t.id, t.owner, t.topic_id, t.assignee) > 0
+		ORDER BY
+			u.username`,
+		ref.Kind, ref.ID, pq.Array(usernames),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []string{}
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		result = append(result, username)
+	}
+
+	return result, rows.Err()
+}
+
+// Returns owner of topic and access level of username to it, empty if there is none.
+// Returns sql.ErrNoRows if there is no such topic
+func GetTopicAccess(ctx context.Context, username string, topicID int) (string, string, error) {
+	var owner string
+	var rank sql.NullInt16
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT owner, topic_access($1, id) FROM topics WHERE id = $2`,
+		username, topicID,
+	).Scan(&owner, &rank)
+	if err != nil {
+		return "", "", err
+	}
+	return owner, tasks.AccessLevel(int(rank.Int16)), nil
+}
+
+// Grants access or changes level of existing share of the same task or topic.
+// Returns ErrUserNotFound if there is no grantee
+func CreateShare(ctx context.Context, s *tasks.Share) error {
+	exists, err := userExists(ctx, s.Grantee)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	var kind *string
+	var id *int
+	conflict := `(grantee, topic_id) WHERE topic_id IS NOT NULL`
+	if s.Task != nil {
+		kind, id = &s.Task.Kind, &s.Task.ID
+		conflict = `(grantee, task_kind, task_id) WHERE task_id IS NOT NULL`
+	}
+
+	return db.QueryRowContext(
+		ctx,
+		`INSERT INTO
+			acl(owner, grantee, task_kind, task_id, topic_id, level, created_at)
+		VALUES
+			($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT `+conflict+` DO UPDATE SET
+			level = EXCLUDED.level
+		RETURNING
+			id, created_at`,
+		s.Owner, s.Grantee, kind, id, s.TopicID, s.Level, time.Now(),
+	).Scan(&s.ID, &s.CreatedAt)
+}
+
+// Returns sql.ErrNoRows if there is no such share
+func GetShare(ctx context.Context, id int) (*tasks.Share, error) {
+	sh, err := scanShare(db.QueryRowContext(
+		ctx,
+		`SELECT
+			`+shareColumns+`
+		FROM
+			acl
+		WHERE
+			id = $1`,
+		id,
+	))
+	if err != nil {
+		return nil, err
+	}
+	return &sh, nil
+}
+
+func UpdateShareLevel(ctx context.Context, id int, level string) error {
+	_, err := db.ExecContext(ctx, `UPDATE acl SET level = $2 WHERE id = $1`, id, level)
+	return err
+}
+
+func DeleteShare(ctx context.Context, id int) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM acl WHERE id = $1`, id)
+	return err
+}
+
+// Returns shares of tasks and topics of owner
+func GetOwnerShares(ctx context.Context, owner string) ([]tasks.Share, error) {
+	return queryShares(
+		ctx,
+		`SELECT
+			`+shareColumns+`
+		FROM
+			acl
+		WHERE
+			owner = $1 AND (task_id IS NULL OR (task_kind, task_id) IN (SELECT kind, id FROM all_tasks))
+		ORDER BY
+			id`,
+		owner,
+	)
+}
+
+// Returns shares of task itself, not ones of its topics
+func GetTaskShares(ctx context.Context, ref tasks.TaskRef) ([]tasks.Share, error) {
+	return queryShares(
+		ctx,
+		`SELECT
+			`+shareColumns+`
+		FROM
+			acl
+		WHERE
+			task_kind = $1 AND task_id = $2
+		ORDER BY
+			id`,
+		ref.Kind, ref.ID,
+	)
+}
+
+func GetTopicShares(ctx context.Context, topicID int) ([]tasks.Share, error) {
+	return queryShares(
+		ctx,
+		`SELECT
+			`+shareColumns+`
+		FROM
+			acl
+		WHERE
+			topic_id = $1
+		ORDER BY
+			id`,
+		topicID,
+	)
+}
+
+// Returns shares granted to grantee by other users
+func GetGranteeShares(ctx context.Context, grantee string) ([]tasks.Share, error) {
+	return queryShares(
+		ctx,
+		`SELECT
+			`+shareColumns+`
+		FROM
+			acl
+		WHERE
+			grantee = $1 AND (task_id IS NULL OR (task_kind, task_id) IN (SELECT kind, id FROM all_tasks))
+		ORDER BY
+			created_at DESC, id`,
+		grantee,
+	)
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+// Returns tasks that may appear in agenda for [from, to): events and deadlines inside
+// the window, repeating tasks started before its end and every base task
+func GetUserAgendaTasks(ctx context.Context, username string, from, to time.Time) (*tasks.User, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	filters := []TaskFilter{
+		{Kinds: []string{tasks.KindBaseTask}},
+		{Kinds: []string{tasks.KindEvent}, StartsTo: to, EndsFrom: from},
+		{Kinds: []string{tasks.KindTaskWithDeadline}, DeadlineFrom: from, DeadlineTo: to},
+		{Kinds: []string{tasks.KindRepeatingTask}, StartsTo: to},
+	}
+
+	user := tasks.User{Username: username}
+	for _, f := range filters {
+		part, _, err := ListUserTasksTx(ctx, tx, username, f)
+		if err != nil {
+			return nil, err
+		}
+
+		user.BaseTasks = append(user.BaseTasks, part.BaseTasks...)
+		user.Events = append(user.Events, part.Events...)
+		user.TasksWithDeadline = append(user.TasksWithDeadline, part.TasksWithDeadline...)
+		user.RepeatingTasks = append(user.RepeatingTasks, part.RepeatingTasks...)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
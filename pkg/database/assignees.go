@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+var ErrCannotAssign = errors.New("User shares no topic or workspace with task")
+
+// Sets assignee of task, nil unassigns it. Assignee must be owner of task or have access to its topic.
+// Returns ErrUserNotFound if there is no such user, ErrCannotAssign if user cannot be assigned
+// and sql.ErrNoRows if there is no such task
+func AssignTask(ctx context.Context, ref tasks.TaskRef, assignee *string) error {
+	spec, ok := kindSpecs[ref.Kind]
+	if !ok {
+		return ErrInvalidKind
+	}
+
+	if assignee != nil {
+		exists, err := userExists(ctx, *assignee)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrUserNotFound
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	nodes, err := queryNodesTx(
+		ctx, tx, ref.Kind,
+		`UPDATE
+			`+spec.table+`
+		SET
+			assignee = $2
+		WHERE
+			id = $1 AND deleted_at IS NULL AND ($2::VARCHAR IS NULL OR owner = $2 OR topic_access($2, topic_id) > 0)
+		RETURNING
+			`+spec.columns,
+		ref.ID, assignee,
+	)
+	if err != nil {
+		return err
+	}
+	if len(nodes) > 0 {
+		if err := recordHistoryTx(ctx, tx, tasks.EventTaskUpdated, nodes...); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	var id int
+	err = db.QueryRowContext(ctx, `SELECT id FROM `+spec.table+` WHERE id = $1 AND deleted_at IS NULL`, ref.ID).Scan(&id)
+	if err != nil {
+		return err
+	}
+	return ErrCannotAssign
+}
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+var ErrQuotaExceeded = errors.New("Attachments quota exceeded")
+
+const attachmentColumns = `id, task_kind, task_id, owner, blob_key, name, mime, size, created_at`
+
+func scanAttachment(s scanner) (tasks.Attachment, error) {
+	var a tasks.Attachment
+	err := s.Scan(&a.ID, &a.TaskType, &a.TaskID, &a.Owner, &a.BlobKey, &a.Name, &a.MIME, &a.Size, &a.CreatedAt)
+	return a, err
+}
+
+// Registers blob before its content is stored so that cleanup doesn't remove it meanwhile
+func TouchBlob(ctx context.Context, key string, size int64) error {
+	_, err := db.ExecContext(
+		ctx,
+		`INSERT INTO
+			blobs(key, size, touched_at)
+		VALUES
+			($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET
+			touched_at = EXCLUDED.touched_at`,
+		key, size, time.Now(),
+	)
+	return err
+}
+
+// Returns ErrQuotaExceeded if owner would have more than quota bytes of attachments
+func CreateAttachment(ctx context.Context, a *tasks.Attachment, quota int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Concurrent uploads of the same user could exceed quota together
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('attachments:' || $1))`, a.Owner); err != nil {
+		return err
+	}
+
+	var used int64
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(SUM(size), 0) FROM attachments WHERE owner = $1`, a.Owner).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used+a.Size > quota {
+		return ErrQuotaExceeded
+	}
+
+	a.CreatedAt = time.Now()
+	err = tx.QueryRowContext(
+		ctx,
+		`INSERT INTO
+			attachments(task_kind, task_id, owner, blob_key, name, mime, size, created_at)
+		VALUES
+			($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING
+			id`,
+		a.TaskType, a.TaskID, a.Owner, a.BlobKey, a.Name, a.MIME, a.Size, a.CreatedAt,
+	).Scan(&a.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Returns sql.ErrNoRows if there is no such attachment or its task is in trash
+func GetAttachment(ctx context.Context, id int) (*tasks.Attachment, error) {
+	a, err := scanAttachment(db.QueryRowContext(
+		ctx,
+		`SELECT
+			`+attachmentColumns+`
+		FROM
+			attachments
+		WHERE
+			id = $1 AND (task_kind, task_id) IN (SELECT kind, id FROM all_tasks)`,
+		id,
+	))
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func GetTaskAttachments(ctx context.Context, ref tasks.TaskRef) ([]tasks.Attachment, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			`+attachmentColumns+`
+		FROM
+			attachments
+		WHERE
+			task_kind = $1 AND task_id = $2 AND (task_kind, task_id) IN (SELECT kind, id FROM all_tasks)
+		ORDER BY
+			id`,
+		ref.Kind, ref.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []tasks.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, a)
+	}
+
+	return result, rows.Err()
+}
+
+// Returns total size of attachments uploaded by owner
+func GetAttachmentUsage(ctx context.Context, owner string) (int64, error) {
+	var used int64
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(SUM(size), 0) FROM attachments WHERE owner = $1`, owner).Scan(&used)
+	return used, err
+}
+
+// Blob of attachment stays until cleanup
+func DeleteAttachment(ctx context.Context, id int) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM attachments WHERE id = $1`, id)
+	return err
+}
+
+// Removes at most limit blobs not referenced by attachments and not touched since before.
+// Blob is forgotten only if remove succeeds. Returns amount of removed blobs.
+// Safe to run on several instances at once
+func DeleteOrphanBlobs(ctx context.Context, before time.Time, limit int, remove func(context.Context, string) error) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT
+			b.key
+		FROM
+			blobs b
+		WHERE
+			b.touched_at < $1 AND NOT EXISTS (SELECT 1 FROM attachments a WHERE a.blob_key = b.key)
+		LIMIT
+			$2
+		FOR UPDATE SKIP LOCKED`,
+		before, limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, key := range keys {
+		if err := remove(ctx, key); err != nil {
+			log.Printf("Couldn't remove blob %s: %s", key, err.Error())
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM blobs WHERE key = $1`, key); err != nil {
+			return 0, err
+		}
+		removed++
+	}
+
+	return removed, tx.Commit()
+}
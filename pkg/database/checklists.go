@@ -0,0 +1,352 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+const checklistItemColumns = `id, task_kind, task_id, title, checked, position, created_at, checked_at, checked_by`
+
+func scanChecklistItem(s scanner) (tasks.ChecklistItem, error) {
+	var i tasks.ChecklistItem
+	err := s.Scan(&i.ID, &i.TaskType, &i.TaskID, &i.Title, &i.Checked, &i.Position, &i.CreatedAt, &i.CheckedAt, &i.CheckedBy)
+	return i, err
+}
+
+// Serializes changes of positions in checklist of task
+func lockChecklistTx(ctx context.Context, tx *sql.Tx, ref tasks.TaskRef) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('checklist:' || $1 || ':' || $2))`, ref.Kind, ref.ID)
+	return err
+}
+
+func checklistSizeTx(ctx context.Context, tx *sql.Tx, ref tasks.TaskRef) (int, error) {
+	var size int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM checklist_items WHERE task_kind = $1 AND task_id = $2`, ref.Kind, ref.ID).Scan(&size)
+	return size, err
+}
+
+// Locks checklist of item and returns the item as it is after locking.
+// Returns sql.ErrNoRows if there is no such item
+func lockChecklistItemTx(ctx context.Context, tx *sql.Tx, id int) (tasks.ChecklistItem, error) {
+	var ref tasks.TaskRef
+	err := tx.QueryRowContext(ctx, `SELECT task_kind, task_id FROM checklist_items WHERE id = $1`, id).Scan(&ref.Kind, &ref.ID)
+	if err != nil {
+		return tasks.ChecklistItem{}, err
+	}
+
+	if err := lockChecklistTx(ctx, tx, ref); err != nil {
+		return tasks.ChecklistItem{}, err
+	}
+
+	return scanChecklistItem(tx.QueryRowContext(ctx, `SELECT `+checklistItemColumns+` FROM checklist_items WHERE id = $1`, id))
+}
+
+// Inserts item at its position moving following items down.
+// Negative position or one beyond the end puts item last
+func CreateChecklistItem(ctx context.Context, item *tasks.ChecklistItem) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ref := tasks.TaskRef{Kind: item.TaskType, ID: item.TaskID}
+	if err := lockChecklistTx(ctx, tx, ref); err != nil {
+		return err
+	}
+
+	size, err := checklistSizeTx(ctx, tx, ref)
+	if err != nil {
+		return err
+	}
+	if item.Position < 0 || item.Position > size {
+		item.Position = size
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE
+			checklist_items
+		SET
+			position = position + 1
+		WHERE
+			task_kind = $1 AND task_id = $2 AND position >= $3`,
+		ref.Kind, ref.ID, item.Position,
+	)
+	if err != nil {
+		return err
+	}
+
+	item.CreatedAt = time.Now()
+	err = tx.QueryRowContext(
+		ctx,
+		`INSERT INTO
+			checklist_items(task_kind, task_id, title, checked, position, created_at)
+		VALUES
+			($1, $2, $3, FALSE, $4, $5)
+		RETURNING
+			id`,
+		ref.Kind, ref.ID, item.Title, item.Position, item.CreatedAt,
+	).Scan(&item.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Returns sql.ErrNoRows if there is no such item
+func GetChecklistItem(ctx context.Context, id int) (*tasks.ChecklistItem, error) {
+	item, err := scanChecklistItem(db.QueryRowContext(
+		ctx,
+		`SELECT
+			`+checklistItemColumns+`
+		FROM
+			checklist_items
+		WHERE
+			id = $1`,
+		id,
+	))
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// Returns items of task checklist in order
+func GetChecklist(ctx context.Context, ref tasks.TaskRef) ([]tasks.ChecklistItem, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			`+checklistItemColumns+`
+		FROM
+			checklist_items
+		WHERE
+			task_kind = $1 AND task_id = $2
+		ORDER BY
+			position`,
+		ref.Kind, ref.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []tasks.ChecklistItem{}
+	for rows.Next() {
+		item, err := scanChecklistItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+
+	return result, rows.Err()
+}
+
+// Saves title of item and moves it to its position shifting items in between.
+// Position beyond the end puts item last. Returns sql.ErrNoRows if there is no such item
+func UpdateChecklistItem(ctx context.Context, item *tasks.ChecklistItem) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := lockChecklistItemTx(ctx, tx, item.ID)
+	if err != nil {
+		return err
+	}
+
+	ref := tasks.TaskRef{Kind: current.TaskType, ID: current.TaskID}
+	size, err := checklistSizeTx(ctx, tx, ref)
+	if err != nil {
+		return err
+	}
+	position := min(max(item.Position, 0), size-1)
+
+	// Items between old and new positions move by one towards the old one
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE
+			checklist_items
+		SET
+			position = position + CASE WHEN $3 < $4 THEN -1 ELSE 1 END
+		WHERE
+			task_kind = $1 AND task_id = $2 AND position BETWEEN LEAST($3, $4) AND GREATEST($3, $4)`,
+		ref.Kind, ref.ID, current.Position, position,
+	)
+	if err != nil {
+		return err
+	}
+
+	updated, err := scanChecklistItem(tx.QueryRowContext(
+		ctx,
+		`UPDATE
+			checklist_items
+		SET
+			title = $2, position = $3
+		WHERE
+			id = $1
+		RETURNING
+			`+checklistItemColumns,
+		item.ID, item.Title, position,
+	))
+	if err != nil {
+		return err
+	}
+
+	*item = updated
+	return tx.Commit()
+}
+
+// Checks item on behalf of username or unchecks it. Returns sql.ErrNoRows if there is no such item
+func SetChecklistItemChecked(ctx context.Context, id int, checked bool, username string) (*tasks.ChecklistItem, error) {
+	item, err := scanChecklistItem(db.QueryRowContext(
+		ctx,
+		`UPDATE
+			checklist_items
+		SET
+			checked = $2,
+			checked_at = CASE WHEN $2 THEN COALESCE(checked_at, $3) END,
+			checked_by = CASE WHEN $2 THEN COALESCE(checked_by, $4) END
+		WHERE
+			id = $1
+		RETURNING
+			`+checklistItemColumns,
+		id, checked, time.Now(), username,
+	))
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// Deletes item moving following items up
+func DeleteChecklistItem(ctx context.Context, id int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	item, err := lockChecklistItemTx(ctx, tx, id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM checklist_items WHERE id = $1`, id); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE
+			checklist_items
+		SET
+			position = position - 1
+		WHERE
+			task_kind = $1 AND task_id = $2 AND position > $3`,
+		item.TaskType, item.TaskID, item.Position,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Returns sql.ErrNoRows if there is no such task
+func SetChecklistAutoComplete(ctx context.Context, ref tasks.TaskRef, autoComplete bool) error {
+	spec, ok := kindSpecs[ref.Kind]
+	if !ok {
+		return ErrInvalidKind
+	}
+
+	res, err := db.ExecContext(ctx, `UPDATE `+spec.table+` SET checklist_autocomplete = $2 WHERE id = $1 AND deleted_at IS NULL`, ref.ID, autoComplete)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Moves task to done state of its workflow if it isn't done and its checklist completes it.
+// Change is saved on Commit of returned update, so that descendants can be completed along with task.
+// Returns nil node and update if task was left as is
+func AutoCompleteTask(ctx context.Context, ref tasks.TaskRef) (*tasks.TaskNode, *TaskUpdate, error) {
+	spec, ok := kindSpecs[ref.Kind]
+	if !ok {
+		return nil, nil, ErrInvalidKind
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	handed := false
+	defer func() {
+		if !handed {
+			tx.Rollback()
+		}
+	}()
+
+	nodes, err := queryNodesTx(ctx, tx, ref.Kind, `SELECT `+spec.columns+` FROM `+spec.table+` WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, ref.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, nil, nil
+	}
+
+	task := tasks.Base(nodes[0].Task)
+	if task.Done || !task.Checklist.Completes() {
+		return nil, nil, nil
+	}
+
+	workflows, err := userWorkflows(ctx, tx, task.Owner)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nodes, err = queryNodesTx(
+		ctx, tx, ref.Kind,
+		`UPDATE
+			`+spec.table+`
+		SET
+			done = TRUE, status = $2
+		WHERE
+			id = $1
+		RETURNING
+			`+spec.columns,
+		ref.ID, workflows.For(task.TopicID).DoneState(),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, nil, nil
+	}
+
+	handed = true
+	return &nodes[0], &TaskUpdate{
+		tx:     tx,
+		ref:    ref,
+		task:   nodes[0].Task,
+		action: tasks.EventTaskUpdated,
+		save:   func(context.Context) error { return nil },
+	}, nil
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/lib/pq"
+)
+
+const commentColumns = `id, task_kind, task_id, author, body, mentions, created_at, edited_at`
+
+func scanComment(s scanner) (tasks.Comment, error) {
+	var c tasks.Comment
+	err := s.Scan(&c.ID, &c.TaskType, &c.TaskID, &c.Author, &c.Body, pq.Array(&c.Mentions), &c.CreatedAt, &c.EditedAt)
+	return c, err
+}
+
+func CreateComment(ctx context.Context, c *tasks.Comment) error {
+	c.CreatedAt = time.Now()
+	return db.QueryRowContext(
+		ctx,
+		`INSERT INTO
+			comments(task_kind, task_id, author, body, mentions, created_at)
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+		RETURNING
+			id`,
+		c.TaskType, c.TaskID, c.Author, c.Body, pq.Array(c.Mentions), c.CreatedAt,
+	).Scan(&c.ID)
+}
+
+// Returns sql.ErrNoRows if there is no such comment
+func GetComment(ctx context.Context, id int) (*tasks.Comment, error) {
+	c, err := scanComment(db.QueryRowContext(
+		ctx,
+		`SELECT
+			`+commentColumns+`
+		FROM
+			comments
+		WHERE
+			id = $1`,
+		id,
+	))
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Returns at most limit comments of task with id greater than after, the oldest first
+func GetTaskComments(ctx context.Context, ref tasks.TaskRef, after, limit int) ([]tasks.Comment, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			`+commentColumns+`
+		FROM
+			comments
+		WHERE
+			task_kind = $1 AND task_id = $2 AND id > $3
+		ORDER BY
+			id
+		LIMIT
+			$4`,
+		ref.Kind, ref.ID, after, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []tasks.Comment{}
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+
+	return result, rows.Err()
+}
+
+// Saves body and mentions of comment keeping its previous body in edit history
+func UpdateComment(ctx context.Context, c *tasks.Comment) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO
+			comment_edits(comment_id, body, edited_at)
+		SELECT
+			id, body, $2
+		FROM
+			comments
+		WHERE
+			id = $1`,
+		c.ID, now,
+	)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		`UPDATE comments SET body = $2, mentions = $3, edited_at = $4 WHERE id = $1`,
+		c.ID, c.Body, pq.Array(c.Mentions), now,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	c.EditedAt = &now
+	return tx.Commit()
+}
+
+// Returns previous bodies of comment, the oldest first
+func GetCommentEdits(ctx context.Context, id int) ([]tasks.CommentEdit, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			id, comment_id, body, edited_at
+		FROM
+			comment_edits
+		WHERE
+			comment_id = $1
+		ORDER BY
+			id`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []tasks.CommentEdit{}
+	for rows.Next() {
+		var e tasks.CommentEdit
+		if err := rows.Scan(&e.ID, &e.CommentID, &e.Body, &e.EditedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+
+	return result, rows.Err()
+}
+
+// Deletes comment with its edit history
+func DeleteComment(ctx context.Context, id int) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM comments WHERE id = $1`, id)
+	return err
+}
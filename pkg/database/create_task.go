@@ -2,59 +2,122 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	tasks "github.com/Kry0z1/fancytasks/pkg"
 	"github.com/lib/pq"
 )
 
+// Common interface of *sql.DB and *sql.Tx
+type rowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Saves task with its tags and records it in history
 func CreateBaseTask(ctx context.Context, task *tasks.BaseTask) error {
-	return db.QueryRowContext(
+	return createTaskWithTags(ctx, tasks.KindBaseTask, task)
+}
+
+func CreateEvent(ctx context.Context, task *tasks.Event) error {
+	return createTaskWithTags(ctx, tasks.KindEvent, task)
+}
+
+func CreateTaskWithDeadline(ctx context.Context, task *tasks.TaskWithDeadline) error {
+	return createTaskWithTags(ctx, tasks.KindTaskWithDeadline, task)
+}
+
+func CreateRepeatingTask(ctx context.Context, task *tasks.RepeatingTask) error {
+	return createTaskWithTags(ctx, tasks.KindRepeatingTask, task)
+}
+
+func createTaskWithTags(ctx context.Context, kind string, task any) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createTask(ctx, tx, task); err != nil {
+		return err
+	}
+
+	base := tasks.Base(task)
+	if len(base.Tags) > 0 {
+		if err := setTaskTagsTx(ctx, tx, base.Owner, tasks.TaskRef{Kind: kind, ID: base.ID}, base.Tags); err != nil {
+			return err
+		}
+	}
+
+	if err := recordHistoryTx(ctx, tx, tasks.EventTaskCreated, tasks.TaskNode{TaskType: kind, Task: task}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Inserts task of any kind given as in TaskNode
+func createTask(ctx context.Context, q rowQueryer, task any) error {
+	switch t := task.(type) {
+	case *tasks.BaseTask:
+		return createBaseTask(ctx, q, t)
+	case *tasks.Event:
+		return createEvent(ctx, q, t)
+	case *tasks.TaskWithDeadline:
+		return createTaskWithDeadline(ctx, q, t)
+	case *tasks.RepeatingTask:
+		return createRepeatingTask(ctx, q, t)
+	}
+	return ErrInvalidKind
+}
+
+func createBaseTask(ctx context.Context, q rowQueryer, task *tasks.BaseTask) error {
+	return q.QueryRowContext(
 		ctx,
 		`INSERT INTO 
-			base_tasks(title, description, done, owner, topic)
+			base_tasks(title, description, done, owner, topic, parent_kind, parent_id, topic_id, priority, created_at, status, status_changed_at, workspace_id)
 		VALUES 
-			($1, $2, $3, $4, $5)
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 		RETURNING
 			id`,
-		task.Title, task.Description, task.Done, task.Owner, task.Topic,
+		task.Title, task.Description, task.Done, task.Owner, task.Topic, task.ParentType, task.ParentID, task.TopicID, task.Priority, task.CreatedAt, task.Status, task.StatusChangedAt, task.WorkspaceID,
 	).Scan(&task.ID)
 }
 
-func CreateEvent(ctx context.Context, task *tasks.Event) error {
-	return db.QueryRowContext(
+func createEvent(ctx context.Context, q rowQueryer, task *tasks.Event) error {
+	return q.QueryRowContext(
 		ctx,
 		`INSERT INTO 
-			events(title, description, done, owner, starts_at, ends_at, topic) 
+			events(title, description, done, owner, starts_at, ends_at, topic, parent_kind, parent_id, topic_id, priority, created_at, status, status_changed_at, workspace_id) 
 		VALUES 
-			($1, $2, $3, $4, $5, $6, $7)
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 		RETURNING
 			id`,
-		task.Title, task.Description, task.Done, task.Owner, task.StartsAt, task.EndsAt, task.Topic,
+		task.Title, task.Description, task.Done, task.Owner, task.StartsAt, task.EndsAt, task.Topic, task.ParentType, task.ParentID, task.TopicID, task.Priority, task.CreatedAt, task.Status, task.StatusChangedAt, task.WorkspaceID,
 	).Scan(&task.ID)
 }
 
-func CreateTaskWithDeadline(ctx context.Context, task *tasks.TaskWithDeadline) error {
-	return db.QueryRowContext(
+func createTaskWithDeadline(ctx context.Context, q rowQueryer, task *tasks.TaskWithDeadline) error {
+	return q.QueryRowContext(
 		ctx,
 		`INSERT INTO 
-			tasks_with_deadline(title, description, done, owner, deadline, topic) 
+			tasks_with_deadline(title, description, done, owner, deadline, topic, parent_kind, parent_id, topic_id, priority, created_at, status, status_changed_at, workspace_id) 
 		VALUES 
-			($1, $2, $3, $4, $5, $6)
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 		RETURNING
 			id`,
-		task.Title, task.Description, task.Done, task.Owner, task.Deadline, task.Topic,
+		task.Title, task.Description, task.Done, task.Owner, task.Deadline, task.Topic, task.ParentType, task.ParentID, task.TopicID, task.Priority, task.CreatedAt, task.Status, task.StatusChangedAt, task.WorkspaceID,
 	).Scan(&task.ID)
 }
 
-func CreateRepeatingTask(ctx context.Context, task *tasks.RepeatingTask) error {
-	return db.QueryRowContext(
+func createRepeatingTask(ctx context.Context, q rowQueryer, task *tasks.RepeatingTask) error {
+	return q.QueryRowContext(
 		ctx,
 		`INSERT INTO 
-			repeating_tasks(title, description, done, owner, starts_at, ends_at, period, loop, excepts, topic) 
+			repeating_tasks(title, description, done, owner, starts_at, ends_at, period, loop, excepts, topic, parent_kind, parent_id, topic_id, priority, created_at, status, status_changed_at, workspace_id, months) 
 		VALUES 
-			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
 		RETURNING
 			id`,
-		task.Title, task.Description, task.Done, task.Owner, task.StartsAt, task.EndsAt, task.Period, task.Loop, pq.Array(task.Except), task.Topic,
+		task.Title, task.Description, task.Done, task.Owner, task.StartsAt, task.EndsAt, task.Period, task.Loop, pq.Array(task.Except), task.Topic, task.ParentType, task.ParentID, task.TopicID, task.Priority, task.CreatedAt, task.Status, task.StatusChangedAt, task.WorkspaceID, task.Months,
 	).Scan(&task.ID)
 }
@@ -6,14 +6,16 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"unicode/utf8"
 
 	_ "github.com/lib/pq"
 )
 
 var db *sql.DB
+var connStr string
 
 func init() {
-	connStr := fmt.Sprintf(
+	connStr = fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 		"postgresql", 5432, os.Getenv("POSTGRES_USER"), os.Getenv("POSTGRES_PASS"), os.Getenv("POSTGRES_DB"),
 	)
@@ -32,6 +34,11 @@ func GetDB() *sql.DB {
 	return db
 }
 
+// Connection string for connections outside of pool, like LISTEN ones
+func GetConnString() string {
+	return connStr
+}
+
 func DecorateGetWithTx[T any, V any, E ~[]T | *T](
 	ctx context.Context,
 	f func(context.Context, *sql.Tx, V) (E, error),
@@ -54,3 +61,14 @@ func DecorateGetWithTx[T any, V any, E ~[]T | *T](
 
 	return result, nil
 }
+
+// Cuts s to at most n bytes without splitting utf-8 characters
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -2,61 +2,100 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	tasks "github.com/Kry0z1/fancytasks/pkg"
-	"github.com/lib/pq"
 )
 
-func DeleteBaseTask(ctx context.Context, task *tasks.BaseTask) error {
-	return db.QueryRowContext(
-		ctx,
-		`DELETE FROM
-			base_tasks
-		WHERE
-			id = $1
-		RETURNING
-			id, title, description, done, owner, topic`,
-		task.ID,
-	).Scan(&task.ID, &task.Title, &task.Description, &task.Done, &task.Owner, &task.Topic)
+// Moves task to trash at its DeletedAt. Returns sql.ErrNoRows if it is missing or already in trash.
+// Task is trashed on Commit of returned update, so that its descendants can be handled along with it
+func DeleteBaseTask(ctx context.Context, task *tasks.BaseTask) (*TaskUpdate, error) {
+	return deleteTask(ctx, tasks.KindBaseTask, task, func(tx *sql.Tx) error {
+		return scanBaseTask(tx.QueryRowContext(
+			ctx,
+			`UPDATE
+				base_tasks
+			SET
+				deleted_at = $2
+			WHERE
+				id = $1 AND deleted_at IS NULL
+			RETURNING
+				`+baseTaskColumns,
+			task.ID, task.DeletedAt,
+		), task)
+	})
 }
 
-func DeleteEvent(ctx context.Context, task *tasks.Event) error {
-	return db.QueryRowContext(
-		ctx,
-		`DELETE FROM
-			events
-		WHERE
-			id = $1
-		RETURNING
-			id, title, description, done, owner, starts_at, ends_at, topic`,
-		task.ID,
-	).Scan(&task.ID, &task.Title, &task.Description, &task.Done, &task.Owner,
-		&task.StartsAt, &task.EndsAt, &task.Topic)
+// Same as DeleteBaseTask for events
+func DeleteEvent(ctx context.Context, task *tasks.Event) (*TaskUpdate, error) {
+	return deleteTask(ctx, tasks.KindEvent, task, func(tx *sql.Tx) error {
+		return scanEvent(tx.QueryRowContext(
+			ctx,
+			`UPDATE
+				events
+			SET
+				deleted_at = $2
+			WHERE
+				id = $1 AND deleted_at IS NULL
+			RETURNING
+				`+eventColumns,
+			task.ID, task.DeletedAt,
+		), task)
+	})
 }
 
-func DeleteTaskWithDeadline(ctx context.Context, task *tasks.TaskWithDeadline) error {
-	return db.QueryRowContext(
-		ctx,
-		`DELETE FROM
-			tasks_with_deadline
-		WHERE
-			id = $1
-		RETURNING
-			id, title, description, done, owner, deadline, topic`,
-		task.ID,
-	).Scan(&task.ID, &task.Title, &task.Description, &task.Done, &task.Owner, &task.Deadline, &task.Topic)
+// Same as DeleteBaseTask for tasks with deadline
+func DeleteTaskWithDeadline(ctx context.Context, task *tasks.TaskWithDeadline) (*TaskUpdate, error) {
+	return deleteTask(ctx, tasks.KindTaskWithDeadline, task, func(tx *sql.Tx) error {
+		return scanTaskWithDeadline(tx.QueryRowContext(
+			ctx,
+			`UPDATE
+				tasks_with_deadline
+			SET
+				deleted_at = $2
+			WHERE
+				id = $1 AND deleted_at IS NULL
+			RETURNING
+				`+taskWithDeadlineColumns,
+			task.ID, task.DeletedAt,
+		), task)
+	})
 }
 
-func DeleteRepeatingTask(ctx context.Context, task *tasks.RepeatingTask) error {
-	return db.QueryRowContext(
-		ctx,
-		`DELETE FROM
-			repeating_tasks
-		WHERE
-			id = $1
-		RETURNING
-			id, title, description, done, owner, starts_at, ends_at, period, loop, excepts, topic`,
-		task.ID,
-	).Scan(&task.ID, &task.Title, &task.Description, &task.Done, &task.Owner,
-		&task.StartsAt, &task.EndsAt, &task.Period, &task.Loop, pq.Array(&task.Except), &task.Topic)
+// Same as DeleteBaseTask for repeating tasks
+func DeleteRepeatingTask(ctx context.Context, task *tasks.RepeatingTask) (*TaskUpdate, error) {
+	return deleteTask(ctx, tasks.KindRepeatingTask, task, func(tx *sql.Tx) error {
+		return scanRepeatingTask(tx.QueryRowContext(
+			ctx,
+			`UPDATE
+				repeating_tasks
+			SET
+				deleted_at = $2
+			WHERE
+				id = $1 AND deleted_at IS NULL
+			RETURNING
+				`+repeatingTaskColumns,
+			task.ID, task.DeletedAt,
+		), task)
+	})
+}
+
+func deleteTask(ctx context.Context, kind string, task any, trash func(*sql.Tx) error) (*TaskUpdate, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = trash(tx); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return &TaskUpdate{
+		tx:     tx,
+		ref:    tasks.TaskRef{Kind: kind, ID: tasks.Base(task).ID},
+		task:   task,
+		action: tasks.EventTaskDeleted,
+		save:   func(context.Context) error { return nil },
+	}, nil
 }
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+var ErrDependencyCycle = errors.New("Dependency would create a cycle")
+
+const dependencyColumns = `owner, blocker_kind, blocker_id, blocked_kind, blocked_id, created_at`
+
+func dependencyFields(d *tasks.Dependency) []any {
+	return []any{&d.Owner, &d.Blocker.Kind, &d.Blocker.ID, &d.Blocked.Kind, &d.Blocked.ID, &d.CreatedAt}
+}
+
+// Saves dependency unless it already exists.
+// Returns ErrDependencyCycle if blocked task already blocks blocker directly or transitively
+func CreateDependency(ctx context.Context, d *tasks.Dependency) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Concurrent insertions of owner's dependencies could create a cycle together
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, d.Owner); err != nil {
+		return err
+	}
+
+	var cycle bool
+	err = tx.QueryRowContext(
+		ctx,
+		`WITH RECURSIVE reachable(kind, id) AS (
+			SELECT $1::VARCHAR, $2::INTEGER
+			UNION
+			SELECT
+				d.blocked_kind, d.blocked_id
+			FROM
+				task_dependencies d
+				JOIN reachable r ON d.blocker_kind = r.kind AND d.blocker_id = r.id
+				JOIN all_tasks t ON t.kind = d.blocked_kind AND t.id = d.blocked_id
+		)
+		SELECT EXISTS(SELECT 1 FROM reachable WHERE kind = $3 AND id = $4)`,
+		d.Blocked.Kind, d.Blocked.ID, d.Blocker.Kind, d.Blocker.ID,
+	).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return ErrDependencyCycle
+	}
+
+	d.CreatedAt = time.Now()
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO
+			task_dependencies(`+dependencyColumns+`)
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+		ON CONFLICT DO NOTHING`,
+		d.Owner, d.Blocker.Kind, d.Blocker.ID, d.Blocked.Kind, d.Blocked.ID, d.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Returns sql.ErrNoRows if owner has no such dependency
+func DeleteDependency(ctx context.Context, owner string, blocker, blocked tasks.TaskRef) error {
+	var d tasks.Dependency
+	return db.QueryRowContext(
+		ctx,
+		`DELETE FROM
+			task_dependencies
+		WHERE
+			owner = $1 AND blocker_kind = $2 AND blocker_id = $3 AND blocked_kind = $4 AND blocked_id = $5
+		RETURNING
+			`+dependencyColumns,
+		owner, blocker.Kind, blocker.ID, blocked.Kind, blocked.ID,
+	).Scan(dependencyFields(&d)...)
+}
+
+func GetUserDependencies(ctx context.Context, owner string) ([]tasks.Dependency, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			`+dependencyColumns+`
+		FROM
+			task_dependencies
+		WHERE
+			owner = $1 AND
+			`+visibleTaskCond("$1", "blocker_kind", "blocker_id")+` AND
+			`+visibleTaskCond("$1", "blocked_kind", "blocked_id")+`
+		ORDER BY
+			created_at`,
+		owner,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []tasks.Dependency{}
+	for rows.Next() {
+		var d tasks.Dependency
+		if err := rows.Scan(dependencyFields(&d)...); err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+
+	return result, rows.Err()
+}
+
+// Returns blockers of task which are not done
+func GetOpenBlockers(ctx context.Context, ref tasks.TaskRef) ([]tasks.TaskRef, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			t.kind, t.id
+		FROM
+			task_dependencies d JOIN all_tasks t ON t.kind = d.blocker_kind AND t.id = d.blocker_id
+		WHERE
+			d.blocked_kind = $1 AND d.blocked_id = $2 AND NOT t.done`,
+		ref.Kind, ref.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []tasks.TaskRef
+	for rows.Next() {
+		var r tasks.TaskRef
+		if err := rows.Scan(&r.Kind, &r.ID); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var ErrUserNotFound = errors.New("User with such username not found")
+
+func userExists(ctx context.Context, username string) (bool, error) {
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT 
+			username 
+		FROM 
+			users 
+		WHERE 
+			username = $1`,
+		username,
+	).Scan(&username)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Allows grantee to see free/busy intervals of owner
+func GrantFreeBusy(ctx context.Context, owner, grantee string) error {
+	exists, err := userExists(ctx, grantee)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	_, err = db.ExecContext(
+		ctx,
+		`INSERT INTO
+			freebusy_grants(owner, grantee)
+		VALUES
+			($1, $2)
+		ON CONFLICT DO NOTHING`,
+		owner, grantee,
+	)
+	return err
+}
+
+// Returns sql.ErrNoRows if there was no such grant
+func RevokeFreeBusy(ctx context.Context, owner, grantee string) error {
+	return db.QueryRowContext(
+		ctx,
+		`DELETE FROM
+			freebusy_grants
+		WHERE
+			owner = $1 AND grantee = $2
+		RETURNING
+			grantee`,
+		owner, grantee,
+	).Scan(&grantee)
+}
+
+func GetFreeBusyGrantees(ctx context.Context, owner string) ([]string, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			grantee
+		FROM
+			freebusy_grants
+		WHERE
+			owner = $1
+		ORDER BY
+			grantee`,
+		owner,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []string{}
+	for rows.Next() {
+		var grantee string
+		if err := rows.Scan(&grantee); err != nil {
+			return nil, err
+		}
+		result = append(result, grantee)
+	}
+
+	return result, rows.Err()
+}
+
+func CanSeeFreeBusy(ctx context.Context, owner, viewer string) (bool, error) {
+	if owner == viewer {
+		return true, nil
+	}
+
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT
+			grantee
+		FROM
+			freebusy_grants
+		WHERE
+			owner = $1 AND grantee = $2`,
+		owner, viewer,
+	).Scan(&viewer)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
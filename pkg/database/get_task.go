@@ -3,9 +3,9 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 
 	tasks "github.com/Kry0z1/fancytasks/pkg"
-	"github.com/lib/pq"
 )
 
 func GetUserBaseTasks(ctx context.Context, username string) ([]tasks.BaseTask, error) {
@@ -14,149 +14,182 @@ func GetUserBaseTasks(ctx context.Context, username string) ([]tasks.BaseTask, e
 
 // User is responsible for creating and commiting/rollbacking transaction
 func GetUserBaseTasksTx(ctx context.Context, tx *sql.Tx, username string) ([]tasks.BaseTask, error) {
-	var result []tasks.BaseTask
+	result, _, err := listTx(ctx, tx, tasks.KindBaseTask, username, TaskFilter{}, nil, baseTaskFields, baseTaskSortValue)
+	return result, err
+}
+
+func GetUserEvents(ctx context.Context, username string) ([]tasks.Event, error) {
+	return DecorateGetWithTx[tasks.Event](ctx, GetUserEventsTx, username)
+}
 
-	rows, err := tx.QueryContext(
+// User is responsible for creating and commiting/rollbacking transaction
+func GetUserEventsTx(ctx context.Context, tx *sql.Tx, username string) ([]tasks.Event, error) {
+	result, _, err := listTx(ctx, tx, tasks.KindEvent, username, TaskFilter{}, nil, eventFields, eventSortValue)
+	return result, err
+}
+
+func GetUserTasksWithDeadline(ctx context.Context, username string) ([]tasks.TaskWithDeadline, error) {
+	return DecorateGetWithTx[tasks.TaskWithDeadline](ctx, GetUserTasksWithDeadlineTx, username)
+}
+
+// User is responsible for creating and commiting/rollbacking transaction
+func GetUserTasksWithDeadlineTx(ctx context.Context, tx *sql.Tx, username string) ([]tasks.TaskWithDeadline, error) {
+	result, _, err := listTx(ctx, tx, tasks.KindTaskWithDeadline, username, TaskFilter{}, nil, taskWithDeadlineFields, taskWithDeadlineSortValue)
+	return result, err
+}
+
+func GetUserRepeatingTasks(ctx context.Context, username string) ([]tasks.RepeatingTask, error) {
+	return DecorateGetWithTx[tasks.RepeatingTask](ctx, GetUserRepeatingTasksTx, username)
+}
+
+// User is responsible for creating and commiting/rollbacking transaction
+func GetUserRepeatingTasksTx(ctx context.Context, tx *sql.Tx, username string) ([]tasks.RepeatingTask, error) {
+	result, _, err := listTx(ctx, tx, tasks.KindRepeatingTask, username, TaskFilter{}, nil, repeatingTaskFields, repeatingTaskSortValue)
+	return result, err
+}
+
+func GetBaseTask(ctx context.Context, id int) (*tasks.BaseTask, error) {
+	return DecorateGetWithTx[tasks.BaseTask](ctx, GetBaseTaskTx, id)
+}
+
+// User is responsible for creating and commiting/rollbacking transaction
+func GetBaseTaskTx(ctx context.Context, tx *sql.Tx, id int) (*tasks.BaseTask, error) {
+	var task tasks.BaseTask
+	err := scanBaseTask(tx.QueryRowContext(
 		ctx,
 		`SELECT 
-			id, title, description, done, owner, topic
+			`+baseTaskColumns+`
 		FROM 
 			base_tasks 
 		WHERE 
-			owner = $1`,
-		username,
-	)
+			id = $1 AND deleted_at IS NULL`,
+		id,
+	), &task)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var nt tasks.BaseTask
-		if err := rows.Scan(&nt.ID, &nt.Title, &nt.Description, &nt.Done, &nt.Owner, &nt.Topic); err != nil {
-			return nil, err
-		}
-		result = append(result, nt)
-	}
-
-	if err := rows.Err(); err != nil {
-		return result, err
-	}
-
-	return result, nil
+	return &task, nil
 }
 
-func GetUserEvents(ctx context.Context, username string) ([]tasks.Event, error) {
-	return DecorateGetWithTx[tasks.Event](ctx, GetUserEventsTx, username)
+func GetEvent(ctx context.Context, id int) (*tasks.Event, error) {
+	return DecorateGetWithTx[tasks.Event](ctx, GetEventTx, id)
 }
 
 // User is responsible for creating and commiting/rollbacking transaction
-func GetUserEventsTx(ctx context.Context, tx *sql.Tx, username string) ([]tasks.Event, error) {
-	var result []tasks.Event
-
-	rows, err := tx.QueryContext(
+func GetEventTx(ctx context.Context, tx *sql.Tx, id int) (*tasks.Event, error) {
+	var task tasks.Event
+	err := scanEvent(tx.QueryRowContext(
 		ctx,
 		`SELECT 
-			id, title, description, done, owner, starts_at, ends_at, topic
+			`+eventColumns+`
 		FROM 
 			events 
 		WHERE 
-			owner = $1`,
-		username,
-	)
+			id = $1 AND deleted_at IS NULL`,
+		id,
+	), &task)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var nt tasks.Event
-		if err := rows.Scan(&nt.ID, &nt.Title, &nt.Description, &nt.Done, &nt.Owner, &nt.StartsAt, &nt.EndsAt, &nt.Topic); err != nil {
-			return nil, err
-		}
-		result = append(result, nt)
-	}
-
-	if err := rows.Err(); err != nil {
-		return result, err
-	}
-
-	return result, nil
+	return &task, nil
 }
 
-func GetUserTasksWithDeadline(ctx context.Context, username string) ([]tasks.TaskWithDeadline, error) {
-	return DecorateGetWithTx[tasks.TaskWithDeadline](ctx, GetUserTasksWithDeadlineTx, username)
+func GetTaskWithDeadline(ctx context.Context, id int) (*tasks.TaskWithDeadline, error) {
+	return DecorateGetWithTx[tasks.TaskWithDeadline](ctx, GetTaskWithDeadlineTx, id)
 }
 
 // User is responsible for creating and commiting/rollbacking transaction
-func GetUserTasksWithDeadlineTx(ctx context.Context, tx *sql.Tx, username string) ([]tasks.TaskWithDeadline, error) {
-	var result []tasks.TaskWithDeadline
-
-	rows, err := tx.QueryContext(
+func GetTaskWithDeadlineTx(ctx context.Context, tx *sql.Tx, id int) (*tasks.TaskWithDeadline, error) {
+	var task tasks.TaskWithDeadline
+	err := scanTaskWithDeadline(tx.QueryRowContext(
 		ctx,
 		`SELECT 
-			id, title, description, done, owner, deadline, topic
+			`+taskWithDeadlineColumns+`
 		FROM 
 			tasks_with_deadline 
 		WHERE 
-			owner = $1`,
-		username,
-	)
+			id = $1 AND deleted_at IS NULL`,
+		id,
+	), &task)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var nt tasks.TaskWithDeadline
-		if err := rows.Scan(&nt.ID, &nt.Title, &nt.Description, &nt.Done, &nt.Owner, &nt.Deadline, &nt.Topic); err != nil {
-			return nil, err
-		}
-		result = append(result, nt)
-	}
-
-	if err := rows.Err(); err != nil {
-		return result, err
-	}
-
-	return result, nil
+	return &task, nil
 }
 
-func GetUserRepeatingTasks(ctx context.Context, username string) ([]tasks.RepeatingTask, error) {
-	return DecorateGetWithTx[tasks.RepeatingTask](ctx, GetUserRepeatingTasksTx, username)
+func GetRepeatingTask(ctx context.Context, id int) (*tasks.RepeatingTask, error) {
+	return DecorateGetWithTx[tasks.RepeatingTask](ctx, GetRepeatingTaskTx, id)
 }
 
 // User is responsible for creating and commiting/rollbacking transaction
-func GetUserRepeatingTasksTx(ctx context.Context, tx *sql.Tx, username string) ([]tasks.RepeatingTask, error) {
-	var result []tasks.RepeatingTask
-
-	rows, err := tx.QueryContext(
+func GetRepeatingTaskTx(ctx context.Context, tx *sql.Tx, id int) (*tasks.RepeatingTask, error) {
+	var task tasks.RepeatingTask
+	err := scanRepeatingTask(tx.QueryRowContext(
 		ctx,
 		`SELECT 
-			id, title, description, done, owner,
-			starts_at, ends_at, period, loop, excepts, topic
+			`+repeatingTaskColumns+`
 		FROM 
 			repeating_tasks 
 		WHERE 
-			owner = $1`,
-		username,
-	)
+			id = $1 AND deleted_at IS NULL`,
+		id,
+	), &task)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return &task, nil
+}
 
-	for rows.Next() {
-		var nt tasks.RepeatingTask
-		if err := rows.Scan(&nt.ID, &nt.Title, &nt.Description, &nt.Done, &nt.Owner, &nt.StartsAt, &nt.EndsAt, &nt.Period, &nt.Loop, pq.Array(&nt.Except), &nt.Topic); err != nil {
-			return nil, err
-		}
-		result = append(result, nt)
+var ErrInvalidKind = errors.New("Invalid task type")
+
+// Returns task of given kind and its base part.
+// Task is one of *tasks.BaseTask, *tasks.Event, *tasks.TaskWithDeadline and *tasks.RepeatingTask
+func GetTask(ctx context.Context, kind string, id int) (any, *tasks.BaseTask, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	task, base, err := GetTaskTx(ctx, tx, kind, id)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if err := rows.Err(); err != nil {
-		return result, err
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
 	}
 
-	return result, nil
+	return task, base, nil
+}
+
+// User is responsible for creating and commiting/rollbacking transaction
+func GetTaskTx(ctx context.Context, tx *sql.Tx, kind string, id int) (any, *tasks.BaseTask, error) {
+	switch kind {
+	case tasks.KindBaseTask:
+		t, err := GetBaseTaskTx(ctx, tx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		return t, t, nil
+	case tasks.KindEvent:
+		t, err := GetEventTx(ctx, tx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		return t, &t.BaseTask, nil
+	case tasks.KindTaskWithDeadline:
+		t, err := GetTaskWithDeadlineTx(ctx, tx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		return t, &t.BaseTask, nil
+	case tasks.KindRepeatingTask:
+		t, err := GetRepeatingTaskTx(ctx, tx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		return t, &t.BaseTask, nil
+	}
+	return nil, nil, ErrInvalidKind
 }
@@ -0,0 +1,226 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+const historyColumns = `id, task_kind, task_id, workspace_id, actor, action, changes, snapshot, at`
+
+func scanHistoryEntry(s scanner) (tasks.HistoryEntry, error) {
+	var e tasks.HistoryEntry
+	var changes, snapshot []byte
+	if err := s.Scan(&e.ID, &e.TaskType, &e.TaskID, &e.WorkspaceID, &e.Actor, &e.Action, &changes, &snapshot, &e.At); err != nil {
+		return e, err
+	}
+	if err := json.Unmarshal(changes, &e.Changes); err != nil {
+		return e, err
+	}
+	return e, json.Unmarshal(snapshot, &e.Snapshot)
+}
+
+func scanHistory(rows *sql.Rows) ([]tasks.HistoryEntry, error) {
+	defer rows.Close()
+
+	result := []tasks.HistoryEntry{}
+	for rows.Next() {
+		e, err := scanHistoryEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+
+	return result, rows.Err()
+}
+
+type contextActorKey struct{}
+
+// Returns ctx whose changes of tasks are recorded in history as made by username
+func WithActor(ctx context.Context, username string) context.Context {
+	return context.WithValue(ctx, contextActorKey{}, username)
+}
+
+// Appends versions of tasks changed by tx to their history along with their tags.
+// Tasks must be locked by tx, so that versions of each task are appended in the order their changes are committed
+func recordHistoryTx(ctx context.Context, tx *sql.Tx, action string, nodes ...tasks.TaskNode) error {
+	for _, n := range nodes {
+		tags, err := taskTags(ctx, tx, n.Ref())
+		if err != nil {
+			return err
+		}
+		tasks.Base(n.Task).Tags = tags
+
+		entry, err := tasks.NewHistoryEntry(action, n.TaskType, n.Task)
+		if err != nil {
+			return err
+		}
+		if username, ok := ctx.Value(contextActorKey{}).(string); ok {
+			entry.Actor = &username
+		}
+		if err := appendHistoryTx(ctx, tx, &entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Appends entry with changes since the previous version of its task.
+// Updates which change nothing are not recorded
+func appendHistoryTx(ctx context.Context, tx *sql.Tx, e *tasks.HistoryEntry) error {
+	var previous map[string]any
+	var raw []byte
+	err := tx.QueryRowContext(
+		ctx,
+		`SELECT
+			snapshot
+		FROM
+			task_history
+		WHERE
+			task_kind = $1 AND task_id = $2
+		ORDER BY
+			id DESC
+		LIMIT
+			1`,
+		e.TaskType, e.TaskID,
+	).Scan(&raw)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if raw != nil {
+		if err := json.Unmarshal(raw, &previous); err != nil {
+			return err
+		}
+	}
+
+	e.Changes = tasks.DiffSnapshots(previous, e.Snapshot)
+	if e.Action == tasks.EventTaskUpdated && len(e.Changes) == 0 {
+		return nil
+	}
+
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return err
+	}
+	snapshot, err := json.Marshal(e.Snapshot)
+	if err != nil {
+		return err
+	}
+
+	return tx.QueryRowContext(
+		ctx,
+		`INSERT INTO
+			task_history(task_kind, task_id, workspace_id, actor, action, changes, snapshot, at)
+		VALUES
+			($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING
+			id`,
+		e.TaskType, e.TaskID, e.WorkspaceID, e.Actor, e.Action, changes, snapshot, e.At,
+	).Scan(&e.ID)
+}
+
+// Returns sql.ErrNoRows if task has no such entry
+func GetHistoryEntry(ctx context.Context, ref tasks.TaskRef, id int64) (*tasks.HistoryEntry, error) {
+	e, err := scanHistoryEntry(db.QueryRowContext(
+		ctx,
+		`SELECT
+			`+historyColumns+`
+		FROM
+			task_history
+		WHERE
+			id = $1 AND task_kind = $2 AND task_id = $3`,
+		id, ref.Kind, ref.ID,
+	))
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Returns at most limit versions of task with id greater than after, the oldest first
+func GetTaskHistory(ctx context.Context, ref tasks.TaskRef, after int64, limit int) ([]tasks.HistoryEntry, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			`+historyColumns+`
+		FROM
+			task_history
+		WHERE
+			task_kind = $1 AND task_id = $2 AND id > $3
+		ORDER BY
+			id
+		LIMIT
+			$4`,
+		ref.Kind, ref.ID, after, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanHistory(rows)
+}
+
+// Filter of workspace audit log, zero fields match everything
+type AuditFilter struct {
+	Actor    string
+	TaskType string
+	TaskID   int
+	Action   string
+	Since    *time.Time
+	Until    *time.Time
+	// Only entries with smaller id are returned
+	Before int64
+	Limit  int
+}
+
+// Returns history entries of tasks in workspace matching filter, the newest first
+func GetWorkspaceHistory(ctx context.Context, workspaceID int, f AuditFilter) ([]tasks.HistoryEntry, error) {
+	var w whereBuilder
+	w.add("workspace_id = " + w.arg(workspaceID))
+	if f.Actor != "" {
+		w.add("actor = " + w.arg(f.Actor))
+	}
+	if f.TaskType != "" {
+		w.add("task_kind = " + w.arg(f.TaskType))
+	}
+	if f.TaskID != 0 {
+		w.add("task_id = " + w.arg(f.TaskID))
+	}
+	if f.Action != "" {
+		w.add("action = " + w.arg(f.Action))
+	}
+	if f.Since != nil {
+		w.add("at >= " + w.arg(*f.Since))
+	}
+	if f.Until != nil {
+		w.add("at < " + w.arg(*f.Until))
+	}
+	if f.Before != 0 {
+		w.add("id < " + w.arg(f.Before))
+	}
+
+	where := w.String()
+	limit := w.arg(f.Limit)
+
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			`+historyColumns+`
+		FROM
+			task_history
+		WHERE
+			`+where+`
+		ORDER BY
+			id DESC
+		LIMIT
+			`+limit,
+		w.args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanHistory(rows)
+}
@@ -0,0 +1,603 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/lib/pq"
+)
+
+var ErrInvalidCursor = errors.New("Invalid cursor")
+var ErrInvalidSort = errors.New("Invalid sort field")
+
+// Fields tasks can be sorted by.
+// Kinds that don't have the field are sorted by id
+const (
+	SortID       = "id"
+	SortDeadline = "deadline"
+	SortStartsAt = "starts_at"
+	SortTitle    = "title"
+)
+
+// Which tasks are listed relative to the user
+const (
+	// Tasks owned by user, default
+	ScopeOwn = ""
+	// Tasks of other users shared with user
+	ScopeShared = "shared"
+	// Both own and shared tasks
+	ScopeVisible = "visible"
+)
+
+type TaskFilter struct {
+	// Kinds to list, every kind if empty
+	Kinds []string
+	// One of Scope* constants
+	Scope string
+	// Lists only tasks of the workspace if set. In personal workspace shared tasks
+	// are those of workspaces user is not a member of
+	Workspace *tasks.Workspace
+	// Max amount of tasks of all kinds together, 0 means no limit
+	Limit int
+	// Cursor returned by previous call, overrides Sort and Desc
+	Cursor string
+	Sort   string
+	Desc   bool
+
+	Done     *bool
+	Status   string
+	Assignee string
+	Topic    string
+	TopicID  int
+	// Topic filters also match tasks of descendant topics
+	Subtopics bool
+	// Lists only direct children of the task if set
+	Parent *tasks.TaskRef
+
+	// Tasks must have every tag of AllTags, at least one of AnyTags and none of NoTags
+	AllTags []string
+	AnyTags []string
+	NoTags  []string
+
+	// Zero values mean unbounded. Kinds without the field are filtered out if bound is set
+	DeadlineFrom time.Time
+	DeadlineTo   time.Time
+	StartsFrom   time.Time
+	StartsTo     time.Time
+	EndsFrom     time.Time
+	EndsTo       time.Time
+}
+
+type kindSpec struct {
+	table   string
+	columns string
+	// Time columns the kind can be filtered by. All of them except ends_at can be sorted by
+	times []string
+}
+
+var kindSpecs = map[string]kindSpec{
+	tasks.KindBaseTask:         {"base_tasks", baseTaskColumns, nil},
+	tasks.KindEvent:            {"events", eventColumns, []string{SortStartsAt, "ends_at"}},
+	tasks.KindTaskWithDeadline: {"tasks_with_deadline", taskWithDeadlineColumns, []string{SortDeadline}},
+	tasks.KindRepeatingTask:    {"repeating_tasks", repeatingTaskColumns, []string{SortStartsAt, "ends_at"}},
+}
+
+func (k kindSpec) sortColumn(sort string) string {
+	if sort == SortTitle || slices.Contains(k.times, sort) {
+		return sort
+	}
+	return SortID
+}
+
+// Tasks of all kinds are listed in one order: by sort field, then by kind, then by id.
+// Tasks of kinds without sort field go after the ones with it
+type cursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d"`
+	// Last returned task. Value is absent for kinds without sort field and for sorting by id
+	Kind  string  `json:"k"`
+	Value *string `json:"v,omitempty"`
+	ID    int     `json:"i"`
+}
+
+// Position of task in listing
+type listKey struct {
+	// Nil if kind doesn't have sort field
+	value any
+	kind  int
+	id    int
+}
+
+func compareKeys(a, b listKey) int {
+	switch {
+	case a.value == nil && b.value != nil:
+		return 1
+	case a.value != nil && b.value == nil:
+		return -1
+	case a.value != nil:
+		if c := compareSortValues(a.value, b.value); c != 0 {
+			return c
+		}
+	}
+
+	if c := cmp.Compare(a.kind, b.kind); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.id, b.id)
+}
+
+// Titles are compared bytewise, as they are sorted with "C" collation
+func compareSortValues(a, b any) int {
+	switch a := a.(type) {
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(a, b.(string))
+	case int:
+		return cmp.Compare(a, b.(int))
+	}
+	return 0
+}
+
+func encodeCursor(c cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if !validSort(c.Sort) || !slices.Contains(tasks.Kinds, c.Kind) {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+func validSort(sort string) bool {
+	return sort == SortID || sort == SortDeadline || sort == SortStartsAt || sort == SortTitle
+}
+
+// Builds WHERE clause with positional arguments
+type whereBuilder struct {
+	conds []string
+	args  []any
+}
+
+// Adds value to arguments and returns its placeholder
+func (w *whereBuilder) arg(v any) string {
+	w.args = append(w.args, v)
+	return "$" + strconv.Itoa(len(w.args))
+}
+
+func (w *whereBuilder) add(cond string) {
+	w.conds = append(w.conds, cond)
+}
+
+func (w *whereBuilder) String() string {
+	return strings.Join(w.conds, " AND ")
+}
+
+// Returns empty query if no task of such kind can satisfy filter
+func listQuery(kind, username string, f TaskFilter, after *cursor) (string, []any, error) {
+	spec := kindSpecs[kind]
+	var w whereBuilder
+	w.add("deleted_at IS NULL")
+
+	user := w.arg(username)
+	own, shared := "owner = "+user, "owner <> "+user+" AND task_access("+user+", "+w.arg(kind)+", id, owner, topic_id, assignee) > 0"
+	if ws := f.Workspace; ws != nil {
+		own += " AND workspace_id = " + w.arg(ws.ID)
+		if ws.Personal {
+			shared += " AND workspace_id NOT IN (SELECT workspace_id FROM workspace_members WHERE username = " + user + ")"
+		} else {
+			shared += " AND workspace_id = " + w.arg(ws.ID)
+		}
+	} else {
+		// Own tasks of workspaces owner has left are not listed
+		own += " AND workspace_id IN (SELECT workspace_id FROM workspace_members WHERE username = " + user + ")"
+	}
+	switch f.Scope {
+	case ScopeShared:
+		w.add(shared)
+	case ScopeVisible:
+		w.add("(" + own + " OR " + shared + ")")
+	default:
+		w.add(own)
+	}
+
+	if f.Done != nil {
+		w.add("done = " + w.arg(*f.Done))
+	}
+	if f.Status != "" {
+		w.add("status = " + w.arg(f.Status))
+	}
+	if f.Assignee != "" {
+		w.add("assignee = " + w.arg(f.Assignee))
+	}
+	switch {
+	case f.Subtopics && f.Topic != "" && f.Workspace != nil:
+		w.add("topic_id IN (" + topicSubtreeQuery("SELECT id FROM topics WHERE workspace_id = "+w.arg(f.Workspace.ID)+" AND "+topicPathCond(&w, "name", "id", f.Topic)) + ")")
+	case f.Subtopics && f.Topic != "":
+		w.add("topic_id IN (" + topicSubtreeQuery("SELECT t.id FROM topics t JOIN workspace_members m ON m.workspace_id = t.workspace_id WHERE m.username = $1 AND "+topicPathCond(&w, "t.name", "t.id", f.Topic)) + ")")
+	case f.Topic != "":
+		w.add(topicPathCond(&w, "topic", "topic_id", f.Topic))
+	}
+	switch {
+	case f.Subtopics && f.TopicID != 0:
+		w.add("topic_id IN (" + topicSubtreeQuery("SELECT "+w.arg(f.TopicID)+"::INTEGER") + ")")
+	case f.TopicID != 0:
+		w.add("topic_id = " + w.arg(f.TopicID))
+	}
+	if f.Parent != nil {
+		w.add("parent_kind = " + w.arg(f.Parent.Kind))
+		w.add("parent_id = " + w.arg(f.Parent.ID))
+	}
+
+	for _, tag := range f.AllTags {
+		w.add("EXISTS (" + taggedQuery(&w, kind, spec.table) + " AND g.name = " + w.arg(tag) + ")")
+	}
+	if len(f.AnyTags) > 0 {
+		w.add("EXISTS (" + taggedQuery(&w, kind, spec.table) + " AND g.name = ANY(" + w.arg(pq.Array(f.AnyTags)) + "))")
+	}
+	if len(f.NoTags) > 0 {
+		w.add("NOT EXISTS (" + taggedQuery(&w, kind, spec.table) + " AND g.name = ANY(" + w.arg(pq.Array(f.NoTags)) + "))")
+	}
+
+	bounds := []struct {
+		column   string
+		from, to time.Time
+	}{
+		{SortDeadline, f.DeadlineFrom, f.DeadlineTo},
+		{SortStartsAt, f.StartsFrom, f.StartsTo},
+		{"ends_at", f.EndsFrom, f.EndsTo},
+	}
+	for _, b := range bounds {
+		if b.from.IsZero() && b.to.IsZero() {
+			continue
+		}
+		if !slices.Contains(spec.times, b.column) {
+			return "", nil, nil
+		}
+		if !b.from.IsZero() {
+			w.add(b.column + " >= " + w.arg(b.from))
+		}
+		if !b.to.IsZero() {
+			w.add(b.column + " < " + w.arg(b.to))
+		}
+	}
+
+	column := spec.sortColumn(f.Sort)
+	order := "ASC"
+	if f.Desc {
+		order = "DESC"
+	}
+
+	if after != nil {
+		cond, ok, err := afterCond(&w, kind, column == f.Sort, f.Desc, after)
+		if err != nil || !ok {
+			return "", nil, err
+		}
+		if cond != "" {
+			w.add(cond)
+		}
+	}
+
+	query := fmt.Sprintf(
+		`SELECT
+			%s
+		FROM
+			%s
+		WHERE
+			%s
+		ORDER BY
+			%s`,
+		spec.columns, spec.table, w.String(), orderBy(column, order),
+	)
+
+	if f.Limit > 0 {
+		query += " LIMIT " + w.arg(f.Limit+1)
+	}
+
+	return query, w.args, nil
+}
+
+// Returns condition selecting tasks of kind listed after cursor, empty if every task is.
+// Returns false if none of them is. hasSort tells whether kind has the sort field
+func afterCond(w *whereBuilder, kind string, hasSort, desc bool, c *cursor) (string, bool, error) {
+	// Kinds are listed in reverse order too when sorting descending
+	rel := cmp.Compare(slices.Index(tasks.Kinds, kind), slices.Index(tasks.Kinds, c.Kind))
+	op, opEq := ">", ">="
+	if desc {
+		rel, op, opEq = -rel, "<", "<="
+	}
+
+	if c.Sort == SortID {
+		if rel > 0 {
+			return "id " + opEq + " " + w.arg(c.ID), true, nil
+		}
+		return "id " + op + " " + w.arg(c.ID), true, nil
+	}
+
+	switch {
+	case hasSort && c.Value != nil:
+		value, err := decodeSortValue(c.Sort, *c.Value)
+		if err != nil {
+			return "", false, err
+		}
+		column, placeholder := sortExpr(c.Sort), w.arg(value)
+		switch {
+		case rel > 0:
+			return column + " " + opEq + " " + placeholder, true, nil
+		case rel == 0:
+			return fmt.Sprintf("(%s, id) %s (%s, %s)", column, op, placeholder, w.arg(c.ID)), true, nil
+		default:
+			return column + " " + op + " " + placeholder, true, nil
+		}
+	case !hasSort && c.Value == nil:
+		switch {
+		case rel > 0:
+			return "", true, nil
+		case rel == 0:
+			return "id " + op + " " + w.arg(c.ID), true, nil
+		default:
+			return "", false, nil
+		}
+	case hasSort:
+		// Cursor is past every task with sort field, unless the order is reversed
+		return "", desc, nil
+	default:
+		return "", !desc, nil
+	}
+}
+
+// Titles are sorted bytewise, so that tasks of different kinds can be merged in the same order
+func sortExpr(column string) string {
+	if column == SortTitle {
+		return `title COLLATE "C"`
+	}
+	return column
+}
+
+// Returns query selecting tags of current row of table
+func taggedQuery(w *whereBuilder, kind, table string) string {
+	return `SELECT 1 FROM task_tags tt JOIN tags g ON g.id = tt.tag_id
+		WHERE g.owner = ` + table + `.owner AND tt.task_kind = ` + w.arg(kind) + ` AND tt.task_id = ` + table + `.id`
+}
+
+// Returns condition of topic with given name and id columns being at path from root topic.
+// Topic name is compared first as it is cheaper than building path
+func topicPathCond(w *whereBuilder, name, id, path string) string {
+	names := splitTopicPath(path)
+	return name + " = " + w.arg(names[len(names)-1]) + " AND topic_path(" + id + ") = " + w.arg(path)
+}
+
+// Returns query selecting ids of topics returned by anchor and all their descendants
+func topicSubtreeQuery(anchor string) string {
+	return `WITH RECURSIVE subtree(id) AS (
+		` + anchor + `
+		UNION
+		SELECT t.id FROM topics t JOIN subtree s ON t.parent_id = s.id
+	)
+	SELECT id FROM subtree`
+}
+
+func orderBy(column, order string) string {
+	if column == SortID {
+		return "id " + order
+	}
+	return sortExpr(column) + " " + order + ", id " + order
+}
+
+func encodeSortValue(v any) string {
+	switch v := v.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case string:
+		return v
+	}
+	return ""
+}
+
+func decodeSortValue(column, s string) (any, error) {
+	if column == SortTitle {
+		return s, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return t, nil
+}
+
+// Lists tasks of one kind together with their positions, at most f.Limit+1 of them
+func listTx[T any](
+	ctx context.Context,
+	tx *sql.Tx,
+	kind, username string,
+	f TaskFilter,
+	after *cursor,
+	fields func(*T) []any,
+	sortValue func(*T, string) any,
+) ([]T, []listKey, error) {
+	query, args, err := listQuery(kind, username, f, after)
+	if err != nil || query == "" {
+		return nil, nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	column := kindSpecs[kind].sortColumn(f.Sort)
+	kindIdx := slices.Index(tasks.Kinds, kind)
+
+	var result []T
+	var keys []listKey
+	for rows.Next() {
+		var nt T
+		if err := rows.Scan(fields(&nt)...); err != nil {
+			return nil, nil, err
+		}
+		result = append(result, nt)
+
+		key := listKey{kind: kindIdx, id: *fields(&nt)[0].(*int)}
+		switch {
+		case f.Sort == SortID:
+			key.value = key.id
+		case column == f.Sort:
+			key.value = sortValue(&nt, column)
+		}
+		keys = append(keys, key)
+	}
+
+	return result, keys, rows.Err()
+}
+
+func baseTaskSortValue(t *tasks.BaseTask, column string) any {
+	return t.Title
+}
+
+func eventSortValue(t *tasks.Event, column string) any {
+	if column == SortStartsAt {
+		return t.StartsAt
+	}
+	return t.Title
+}
+
+func taskWithDeadlineSortValue(t *tasks.TaskWithDeadline, column string) any {
+	if column == SortDeadline {
+		return t.Deadline
+	}
+	return t.Title
+}
+
+func repeatingTaskSortValue(t *tasks.RepeatingTask, column string) any {
+	return eventSortValue(&t.Event, column)
+}
+
+// Lists tasks of every kind in f.Kinds.
+// Returned cursor is empty if there are no more tasks
+func ListUserTasks(ctx context.Context, username string, f TaskFilter) (*tasks.User, string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	user, next, err := ListUserTasksTx(ctx, tx, username, f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+
+	return user, next, nil
+}
+
+// User is responsible for creating and commiting/rollbacking transaction
+func ListUserTasksTx(ctx context.Context, tx *sql.Tx, username string, f TaskFilter) (*tasks.User, string, error) {
+	var prev *cursor
+	if f.Cursor != "" {
+		var err error
+		if prev, err = decodeCursor(f.Cursor); err != nil {
+			return nil, "", err
+		}
+		f.Sort, f.Desc = prev.Sort, prev.Desc
+	}
+
+	if f.Sort == "" {
+		f.Sort = SortID
+	}
+	if !validSort(f.Sort) {
+		return nil, "", ErrInvalidSort
+	}
+
+	kinds := f.Kinds
+	if len(kinds) == 0 {
+		kinds = tasks.Kinds
+	}
+
+	user := tasks.User{Username: username}
+	var listed []listKey
+
+	for _, kind := range kinds {
+		var keys []listKey
+		var err error
+		switch kind {
+		case tasks.KindBaseTask:
+			user.BaseTasks, keys, err = listTx(ctx, tx, kind, username, f, prev, baseTaskFields, baseTaskSortValue)
+		case tasks.KindEvent:
+			user.Events, keys, err = listTx(ctx, tx, kind, username, f, prev, eventFields, eventSortValue)
+		case tasks.KindTaskWithDeadline:
+			user.TasksWithDeadline, keys, err = listTx(ctx, tx, kind, username, f, prev, taskWithDeadlineFields, taskWithDeadlineSortValue)
+		case tasks.KindRepeatingTask:
+			user.RepeatingTasks, keys, err = listTx(ctx, tx, kind, username, f, prev, repeatingTaskFields, repeatingTaskSortValue)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		listed = append(listed, keys...)
+	}
+
+	var last *listKey
+	if f.Limit > 0 && len(listed) > f.Limit {
+		slices.SortFunc(listed, func(a, b listKey) int {
+			if f.Desc {
+				return compareKeys(b, a)
+			}
+			return compareKeys(a, b)
+		})
+		last = &listed[f.Limit-1]
+
+		// Tasks of every kind come in listing order, so the ones up to last are their prefix
+		taken := map[string]int{}
+		for _, key := range listed[:f.Limit] {
+			taken[tasks.Kinds[key.kind]]++
+		}
+		user.BaseTasks = user.BaseTasks[:taken[tasks.KindBaseTask]]
+		user.Events = user.Events[:taken[tasks.KindEvent]]
+		user.TasksWithDeadline = user.TasksWithDeadline[:taken[tasks.KindTaskWithDeadline]]
+		user.RepeatingTasks = user.RepeatingTasks[:taken[tasks.KindRepeatingTask]]
+	}
+
+	if err := attachTagsTx(ctx, tx, &user); err != nil {
+		return nil, "", err
+	}
+
+	if last == nil {
+		return &user, "", nil
+	}
+
+	next := cursor{Sort: f.Sort, Desc: f.Desc, Kind: tasks.Kinds[last.kind], ID: last.id}
+	if f.Sort != SortID && last.value != nil {
+		value := encodeSortValue(last.value)
+		next.Value = &value
+	}
+
+	return &user, encodeCursor(next), nil
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCompareKeys(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	// Listing order of tasks sorted by deadline: kinds with deadline first, then the rest by kind and id
+	want := []listKey{
+		{value: day, kind: 2, id: 7},
+		{value: day.Add(time.Hour), kind: 1, id: 9},
+		{value: day.Add(time.Hour), kind: 2, id: 3},
+		{value: day.Add(time.Hour), kind: 2, id: 5},
+		{value: nil, kind: 0, id: 4},
+		{value: nil, kind: 0, id: 8},
+		{value: nil, kind: 3, id: 1},
+	}
+
+	got := slices.Clone(want)
+	slices.Reverse(got)
+	slices.SortFunc(got, compareKeys)
+	if !slices.Equal(got, want) {
+		t.Errorf("sorted keys = %v, want %v", got, want)
+	}
+
+	titles := []listKey{{value: "Zoo"}, {value: "apple"}, {value: "Ärger"}}
+	slices.SortFunc(titles, compareKeys)
+	if titles[0].value != "Zoo" || titles[1].value != "apple" || titles[2].value != "Ärger" {
+		t.Errorf("titles are not sorted bytewise: %v", titles)
+	}
+}
@@ -0,0 +1,371 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"slices"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+const reminderColumns = `id, owner, task_kind, task_id, occurrence, remind_at, offset_seconds,
+	recurring, channel, target, status, fired_at, attempts, next_attempt_at, error`
+
+func reminderFields(r *tasks.Reminder) []any {
+	return []any{&r.ID, &r.Owner, &r.TaskType, &r.TaskID, &r.Occurrence, &r.RemindAt, &r.Offset,
+		&r.Recurring, &r.Channel, &r.Target, &r.Status, &r.FiredAt, &r.Attempts, &r.NextAttemptAt, &r.Error}
+}
+
+func CreateReminder(ctx context.Context, r *tasks.Reminder) error {
+	r.Status = tasks.ReminderPending
+
+	return db.QueryRowContext(
+		ctx,
+		`INSERT INTO
+			reminders(owner, task_kind, task_id, occurrence, remind_at, offset_seconds,
+				recurring, channel, target, status)
+		VALUES
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING
+			id`,
+		r.Owner, r.TaskType, r.TaskID, r.Occurrence, r.RemindAt, r.Offset,
+		r.Recurring, r.Channel, r.Target, r.Status,
+	).Scan(&r.ID)
+}
+
+// Returns reminders of every status if status is empty
+func GetUserReminders(ctx context.Context, owner, status string) ([]tasks.Reminder, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			`+reminderColumns+`
+		FROM
+			reminders
+		WHERE
+			owner = $1 AND ($2 = '' OR status = $2) AND `+visibleTaskCond("$1", "task_kind", "task_id")+`
+		ORDER BY
+			remind_at, id`,
+		owner, status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []tasks.Reminder{}
+	for rows.Next() {
+		var r tasks.Reminder
+		if err := rows.Scan(reminderFields(&r)...); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+
+	return result, rows.Err()
+}
+
+// Moves reminder to given time and makes it pending again.
+// Returns sql.ErrNoRows if owner has no such reminder
+func SnoozeReminder(ctx context.Context, owner string, id int, until time.Time) (*tasks.Reminder, error) {
+	var r tasks.Reminder
+	err := db.QueryRowContext(
+		ctx,
+		`UPDATE
+			reminders
+		SET
+			remind_at = $3, status = $4, attempts = 0, next_attempt_at = NULL, error = NULL
+		WHERE
+			owner = $1 AND id = $2
+		RETURNING
+			`+reminderColumns,
+		owner, id, until, tasks.ReminderPending,
+	).Scan(reminderFields(&r)...)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Returns sql.ErrNoRows if owner has no such reminder
+func DismissReminder(ctx context.Context, owner string, id int) (*tasks.Reminder, error) {
+	var r tasks.Reminder
+	err := db.QueryRowContext(
+		ctx,
+		`UPDATE
+			reminders
+		SET
+			status = $3
+		WHERE
+			owner = $1 AND id = $2
+		RETURNING
+			`+reminderColumns,
+		owner, id, tasks.ReminderDismissed,
+	).Scan(reminderFields(&r)...)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Calls fire for at most limit reminders due at now together with their tasks and returns how many were claimed.
+// Reminders are claimed as sending until lease passes, so concurrent callers never get the same ones
+// and fire is called outside of transaction. Reminder left sending by caller that died is claimed again once lease passes.
+// Failed reminder is retried after backoff(attempts) until maxAttempts is reached.
+// Due reminders of tasks which are deleted, in trash or no longer visible to their owner are dismissed
+func FireDueReminders(
+	ctx context.Context,
+	now time.Time,
+	limit int,
+	lease time.Duration,
+	maxAttempts int,
+	backoff func(attempts int) time.Duration,
+	fire func(context.Context, tasks.Reminder, *tasks.BaseTask) error,
+) (int, error) {
+	if err := dismissHiddenReminders(ctx, now); err != nil {
+		return 0, err
+	}
+
+	due, err := claimDueReminders(ctx, now, limit, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, r := range due {
+		task, base, err := GetTask(ctx, r.TaskType, r.TaskID)
+		if err == sql.ErrNoRows {
+			r.Status = tasks.ReminderDismissed
+			if err := saveSentReminder(ctx, &r); err != nil {
+				return len(due), err
+			}
+			continue
+		}
+		if err != nil {
+			return len(due), err
+		}
+
+		if err := fire(ctx, r, base); err != nil {
+			msg := truncate(err.Error(), 512)
+			log.Printf("Couldn't fire reminder %d: %s", r.ID, msg)
+
+			r.Attempts++
+			r.Error = &msg
+			r.Status = tasks.ReminderPending
+			nextAttempt := time.Now().Add(backoff(r.Attempts))
+			r.NextAttemptAt = &nextAttempt
+
+			if r.Attempts >= maxAttempts {
+				// Recurring reminder gives up only on current occurrence
+				r.Status = tasks.ReminderFailed
+				r.NextAttemptAt = nil
+				if advanceReminder(task, &r) {
+					r.Attempts = 0
+				}
+			}
+		} else {
+			firedAt := now
+			r.FiredAt = &firedAt
+			r.Status = tasks.ReminderFired
+			r.Attempts = 0
+			r.NextAttemptAt = nil
+			r.Error = nil
+			advanceReminder(task, &r)
+		}
+
+		if err := saveSentReminder(ctx, &r); err != nil {
+			return len(due), err
+		}
+	}
+
+	return len(due), nil
+}
+
+// Dismisses reminders due at now which owner can't see tasks of, they would never be claimed otherwise
+func dismissHiddenReminders(ctx context.Context, now time.Time) error {
+	_, err := db.ExecContext(
+		ctx,
+		`UPDATE
+			reminders
+		SET
+			status = $1, next_attempt_at = NULL
+		WHERE
+			status = $2 AND remind_at <= $3 AND NOT `+visibleTaskCond("owner", "task_kind", "task_id"),
+		tasks.ReminderDismissed, tasks.ReminderPending, now,
+	)
+	return err
+}
+
+// Marks at most limit reminders due at now as sending until now+lease and returns them
+func claimDueReminders(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]tasks.Reminder, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`UPDATE
+			reminders
+		SET
+			status = $1, claimed_until = $2
+		WHERE
+			id IN (
+				SELECT
+					id
+				FROM
+					reminders
+				WHERE
+					(
+						status = $3 AND remind_at <= $4 AND (next_attempt_at IS NULL OR next_attempt_at <= $4)
+						OR status = $1 AND claimed_until <= $4
+					) AND `+visibleTaskCond("owner", "task_kind", "task_id")+`
+				ORDER BY
+					remind_at
+				LIMIT
+					$5
+				FOR UPDATE SKIP LOCKED
+			)
+		RETURNING
+			`+reminderColumns,
+		tasks.ReminderSending, now.Add(lease), tasks.ReminderPending, now, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []tasks.Reminder
+	for rows.Next() {
+		var r tasks.Reminder
+		if err := rows.Scan(reminderFields(&r)...); err != nil {
+			return nil, err
+		}
+		due = append(due, r)
+	}
+
+	return due, rows.Err()
+}
+
+// Moves recurring reminder of repeating task to the next occurrence and makes it pending again.
+// Returns false if there is no next occurrence
+func advanceReminder(task any, r *tasks.Reminder) bool {
+	rt, ok := task.(*tasks.RepeatingTask)
+	if !ok || !r.Recurring || r.Occurrence == nil || r.Offset == nil {
+		return false
+	}
+
+	next, ok := rt.NextOccurrence(*r.Occurrence)
+	if !ok {
+		return false
+	}
+
+	r.Occurrence = &next.Index
+	r.RemindAt = next.StartsAt.Add(-time.Duration(*r.Offset) * time.Second)
+	r.Status = tasks.ReminderPending
+	return true
+}
+
+// Does nothing if reminder was snoozed or dismissed while being sent
+func saveSentReminder(ctx context.Context, r *tasks.Reminder) error {
+	_, err := db.ExecContext(
+		ctx,
+		`UPDATE
+			reminders
+		SET
+			occurrence = $2, remind_at = $3, status = $4, fired_at = $5,
+			attempts = $6, next_attempt_at = $7, error = $8, claimed_until = NULL
+		WHERE
+			id = $1 AND status = $9`,
+		r.ID, r.Occurrence, r.RemindAt, r.Status, r.FiredAt,
+		r.Attempts, r.NextAttemptAt, r.Error, tasks.ReminderSending,
+	)
+	return err
+}
+
+// Reports whether deadline, start or occurrences of task differ between its versions a and b
+func reminderTimeChanged(a, b any) bool {
+	switch a := a.(type) {
+	case *tasks.Event:
+		return !a.StartsAt.Equal(b.(*tasks.Event).StartsAt)
+	case *tasks.TaskWithDeadline:
+		return !a.Deadline.Equal(b.(*tasks.TaskWithDeadline).Deadline)
+	case *tasks.RepeatingTask:
+		b := b.(*tasks.RepeatingTask)
+		return !a.StartsAt.Equal(b.StartsAt) || a.Period != b.Period || a.Months != b.Months ||
+			a.Loop != b.Loop || !slices.Equal(a.Except, b.Except)
+	}
+	return false
+}
+
+// Moves pending reminders with offset to the new deadline or start of task.
+// Recurring reminder moves to the first occurrence after now it is still due for,
+// reminders of occurrences which are skipped or don't come anymore are dismissed
+func rescheduleRemindersTx(ctx context.Context, tx *sql.Tx, ref tasks.TaskRef, task any, now time.Time) error {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT
+			`+reminderColumns+`
+		FROM
+			reminders
+		WHERE
+			task_kind = $1 AND task_id = $2 AND status = $3 AND offset_seconds IS NOT NULL
+		FOR UPDATE`,
+		ref.Kind, ref.ID, tasks.ReminderPending,
+	)
+	if err != nil {
+		return err
+	}
+
+	var pending []tasks.Reminder
+	for rows.Next() {
+		var r tasks.Reminder
+		if err := rows.Scan(reminderFields(&r)...); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range pending {
+		before := time.Duration(*r.Offset) * time.Second
+
+		switch t := task.(type) {
+		case *tasks.TaskWithDeadline:
+			r.RemindAt = t.Deadline.Add(-before)
+		case *tasks.Event:
+			r.RemindAt = t.StartsAt.Add(-before)
+		case *tasks.RepeatingTask:
+			if r.Occurrence != nil && !r.Recurring {
+				r.RemindAt = t.Occurrence(*r.Occurrence).StartsAt.Add(-before)
+				if t.Skipped(*r.Occurrence) {
+					r.Status = tasks.ReminderDismissed
+				}
+				break
+			}
+
+			o, ok := t.OccurrenceAfter(now.Add(before))
+			if !ok {
+				r.Status = tasks.ReminderDismissed
+				break
+			}
+			r.Occurrence = &o.Index
+			r.RemindAt = o.StartsAt.Add(-before)
+		}
+
+		_, err := tx.ExecContext(
+			ctx,
+			`UPDATE
+				reminders
+			SET
+				occurrence = $2, remind_at = $3, status = $4, attempts = 0, next_attempt_at = NULL, error = NULL
+			WHERE
+				id = $1`,
+			r.ID, r.Occurrence, r.RemindAt, r.Status,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
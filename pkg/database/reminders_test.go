@@ -0,0 +1,43 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+func TestReminderTimeChanged(t *testing.T) {
+	day := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	repeating := func(edit func(*tasks.RepeatingTask)) *tasks.RepeatingTask {
+		rt := &tasks.RepeatingTask{Event: tasks.Event{StartsAt: day, EndsAt: day.Add(time.Hour)}, Period: 86400, Loop: 7, Except: []int64{5, 6}}
+		edit(rt)
+		return rt
+	}
+	same := func(*tasks.RepeatingTask) {}
+
+	tests := []struct {
+		name string
+		a, b any
+		want bool
+	}{
+		{"title of deadline", &tasks.TaskWithDeadline{Deadline: day}, &tasks.TaskWithDeadline{BaseTask: tasks.BaseTask{Title: "x"}, Deadline: day}, false},
+		{"deadline", &tasks.TaskWithDeadline{Deadline: day}, &tasks.TaskWithDeadline{Deadline: day.Add(time.Hour)}, true},
+		{"end of event", &tasks.Event{StartsAt: day, EndsAt: day}, &tasks.Event{StartsAt: day, EndsAt: day.Add(time.Hour)}, false},
+		{"start of event", &tasks.Event{StartsAt: day}, &tasks.Event{StartsAt: day.Add(time.Hour)}, true},
+		{"same repeating", repeating(same), repeating(same), false},
+		{"end of repeating", repeating(same), repeating(func(rt *tasks.RepeatingTask) { rt.EndsAt = day }), false},
+		{"period", repeating(same), repeating(func(rt *tasks.RepeatingTask) { rt.Period = 3600 }), true},
+		{"months", repeating(same), repeating(func(rt *tasks.RepeatingTask) { rt.Months = 1 }), true},
+		{"except", repeating(same), repeating(func(rt *tasks.RepeatingTask) { rt.Except = []int64{6} }), true},
+		{"base task", &tasks.BaseTask{}, &tasks.BaseTask{Title: "x"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reminderTimeChanged(tt.a, tt.b); got != tt.want {
+				t.Errorf("reminderTimeChanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/lib/pq"
+)
+
+// Common interface of *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// Column lists are kept in the same order as fields returned by *Fields functions
+const (
+	baseTaskColumns         = `id, title, description, done, owner, topic, parent_kind, parent_id, topic_id, priority, created_at, status, status_changed_at, workspace_id, assignee, comment_count, checklist_total, checklist_done, checklist_autocomplete, deleted_at`
+	eventColumns            = baseTaskColumns + `, starts_at, ends_at`
+	taskWithDeadlineColumns = baseTaskColumns + `, deadline`
+	repeatingTaskColumns    = eventColumns + `, period, loop, excepts, months`
+)
+
+func baseTaskFields(t *tasks.BaseTask) []any {
+	return []any{&t.ID, &t.Title, &t.Description, &t.Done, &t.Owner, &t.Topic, &t.ParentType, &t.ParentID, &t.TopicID, &t.Priority, &t.CreatedAt, &t.Status, &t.StatusChangedAt, &t.WorkspaceID, &t.Assignee, &t.CommentCount, &t.Checklist.Total, &t.Checklist.Done, &t.Checklist.AutoComplete, &t.DeletedAt}
+}
+
+func eventFields(t *tasks.Event) []any {
+	return append(baseTaskFields(&t.BaseTask), &t.StartsAt, &t.EndsAt)
+}
+
+func taskWithDeadlineFields(t *tasks.TaskWithDeadline) []any {
+	return append(baseTaskFields(&t.BaseTask), &t.Deadline)
+}
+
+func repeatingTaskFields(t *tasks.RepeatingTask) []any {
+	return append(eventFields(&t.Event), &t.Period, &t.Loop, pq.Array(&t.Except), &t.Months)
+}
+
+func scanBaseTask(s scanner, t *tasks.BaseTask) error {
+	return s.Scan(baseTaskFields(t)...)
+}
+
+func scanEvent(s scanner, t *tasks.Event) error {
+	return s.Scan(eventFields(t)...)
+}
+
+func scanTaskWithDeadline(s scanner, t *tasks.TaskWithDeadline) error {
+	return s.Scan(taskWithDeadlineFields(t)...)
+}
+
+func scanRepeatingTask(s scanner, t *tasks.RepeatingTask) error {
+	return s.Scan(repeatingTaskFields(t)...)
+}
+
+// Allocates task of given kind and returns it along with fields to scan into.
+// Returns nil task for unknown kind
+func newTask(kind string) (any, []any) {
+	switch kind {
+	case tasks.KindBaseTask:
+		t := &tasks.BaseTask{}
+		return t, baseTaskFields(t)
+	case tasks.KindEvent:
+		t := &tasks.Event{}
+		return t, eventFields(t)
+	case tasks.KindTaskWithDeadline:
+		t := &tasks.TaskWithDeadline{}
+		return t, taskWithDeadlineFields(t)
+	case tasks.KindRepeatingTask:
+		t := &tasks.RepeatingTask{}
+		return t, repeatingTaskFields(t)
+	}
+	return nil, nil
+}
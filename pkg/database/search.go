@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+var ErrEmptyQuery = errors.New("Search query is empty")
+
+// Wrap matches in snippets of search results instead of html tags,
+// so that snippet can be escaped before matches are highlighted
+const (
+	SnippetMatchStart = "\x01"
+	SnippetMatchStop  = "\x02"
+)
+
+// Converts user input to tsquery where every word should be present as prefix
+func searchQuery(q string) string {
+	words := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for i, word := range words {
+		words[i] = strings.ToLower(word) + ":*"
+	}
+
+	return strings.Join(words, " & ")
+}
+
+// Searches title, description and topic of tasks of every kind visible to username in workspace, best matches first
+func SearchUserTasks(ctx context.Context, username string, workspaceID int, q string, limit int) ([]tasks.SearchResult, error) {
+	tsquery := searchQuery(q)
+	if tsquery == "" {
+		return nil, ErrEmptyQuery
+	}
+
+	selects := make([]string, 0, len(tasks.Kinds))
+	for _, kind := range tasks.Kinds {
+		selects = append(selects, fmt.Sprintf(
+			`SELECT
+				'%s' AS kind, id, title, description, topic, done, ts_rank(search_vector, query.q) AS rank
+			FROM
+				%s, query
+			WHERE
+				workspace_id = $4 AND deleted_at IS NULL AND task_access($1, '%s', id, owner, topic_id, assignee) > 0 AND
+				search_vector @@ query.q`,
+			kind, kindSpecs[kind].table, kind,
+		))
+	}
+
+	rows, err := db.QueryContext(
+		ctx,
+		`WITH query AS (
+			SELECT to_tsquery('simple', $2) AS q
+		)
+		SELECT
+			kind, id, title, topic, done, rank,
+			ts_headline('simple', title || ' ' || description, query.q, $5)
+		FROM
+			(`+strings.Join(selects, " UNION ALL ")+`) found, query
+		ORDER BY
+			rank DESC, id
+		LIMIT
+			$3`,
+		username, tsquery, limit, workspaceID,
+		`MaxFragments=2, StartSel="`+SnippetMatchStart+`", StopSel="`+SnippetMatchStop+`"`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []tasks.SearchResult
+	for rows.Next() {
+		var sr tasks.SearchResult
+		if err := rows.Scan(&sr.TaskType, &sr.ID, &sr.Title, &sr.Topic, &sr.Done, &sr.Rank, &sr.Snippet); err != nil {
+			return nil, err
+		}
+		result = append(result, sr)
+	}
+
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+// Returns empty settings if user hasn't saved any
+func GetUserSettings(ctx context.Context, username string) (*tasks.UserSettings, error) {
+	var raw []byte
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT
+			settings
+		FROM
+			user_settings
+		WHERE
+			username = $1`,
+		username,
+	).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return &tasks.UserSettings{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s tasks.UserSettings
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func SaveUserSettings(ctx context.Context, username string, s *tasks.UserSettings) error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(
+		ctx,
+		`INSERT INTO
+			user_settings(username, settings)
+		VALUES
+			($1, $2)
+		ON CONFLICT (username) DO UPDATE SET
+			settings = EXCLUDED.settings`,
+		username, raw,
+	)
+	return err
+}
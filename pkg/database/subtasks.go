@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/lib/pq"
+)
+
+// Reports whether making parent the parent of task being updated would create a cycle,
+// that is whether task is parent itself or one of its ancestors. Hierarchy of workspace stays locked
+// until the update ends, so that concurrent updates cannot make a cycle together
+func (u *TaskUpdate) WouldCreateCycle(ctx context.Context, parent tasks.TaskRef, workspaceID int) (bool, error) {
+	_, err := u.tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('hierarchy:' || $1))`, workspaceID)
+	if err != nil {
+		return false, err
+	}
+
+	var cycle bool
+	err = u.tx.QueryRowContext(
+		ctx,
+		`WITH RECURSIVE ancestors(kind, id) AS (
+			SELECT $1::VARCHAR, $2::INTEGER
+			UNION
+			SELECT
+				t.parent_kind, t.parent_id
+			FROM
+				all_tasks t JOIN ancestors a ON t.kind = a.kind AND t.id = a.id
+			WHERE
+				t.parent_id IS NOT NULL
+		)
+		SELECT EXISTS(SELECT 1 FROM ancestors WHERE kind = $3 AND id = $4)`,
+		parent.Kind, parent.ID, u.ref.Kind, u.ref.ID,
+	).Scan(&cycle)
+	return cycle, err
+}
+
+func descendantsTx(ctx context.Context, tx *sql.Tx, ref tasks.TaskRef) ([]tasks.TaskRef, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		`WITH RECURSIVE descendants(kind, id) AS (
+			SELECT
+				kind, id
+			FROM
+				all_tasks
+			WHERE
+				parent_kind = $1 AND parent_id = $2
+			UNION
+			SELECT
+				t.kind, t.id
+			FROM
+				all_tasks t JOIN descendants d ON t.parent_kind = d.kind AND t.parent_id = d.id
+		)
+		SELECT kind, id FROM descendants`,
+		ref.Kind, ref.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []tasks.TaskRef
+	for rows.Next() {
+		var r tasks.TaskRef
+		if err := rows.Scan(&r.Kind, &r.ID); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+
+	return result, rows.Err()
+}
+
+// Groups ids of refs by kind
+func idsByKind(refs []tasks.TaskRef) map[string][]int {
+	result := map[string][]int{}
+	for _, r := range refs {
+		result[r.Kind] = append(result[r.Kind], r.ID)
+	}
+	return result
+}
+
+// Runs query returning columns of kind and wraps every returned row into node
+func queryNodesTx(ctx context.Context, tx *sql.Tx, kind, query string, args ...any) ([]tasks.TaskNode, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []tasks.TaskNode
+	for rows.Next() {
+		task, fields := newTask(kind)
+		if err := rows.Scan(fields...); err != nil {
+			return nil, err
+		}
+		result = append(result, tasks.TaskNode{TaskType: kind, Task: task})
+	}
+
+	return result, rows.Err()
+}
+
+// Applies query to tasks of every kind in refs. Query is formatted with table and columns of kind,
+// $1 is array of ids
+func forKindsTx(ctx context.Context, tx *sql.Tx, refs []tasks.TaskRef, query string, args ...any) ([]tasks.TaskNode, error) {
+	byKind := idsByKind(refs)
+
+	var result []tasks.TaskNode
+	for _, kind := range tasks.Kinds {
+		ids := byKind[kind]
+		if len(ids) == 0 {
+			continue
+		}
+
+		spec := kindSpecs[kind]
+		nodes, err := queryNodesTx(ctx, tx, kind, fmt.Sprintf(query, spec.table, spec.columns), append([]any{pq.Array(ids)}, args...)...)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, nodes...)
+	}
+	return result, nil
+}
+
+// Returns every descendant of task, direct children included, in no particular order
+func GetTaskDescendants(ctx context.Context, ref tasks.TaskRef) ([]tasks.TaskNode, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	refs, err := descendantsTx(ctx, tx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := forKindsTx(ctx, tx, refs, `SELECT %[2]s FROM %[1]s WHERE id = ANY($1) AND deleted_at IS NULL`)
+	if err != nil {
+		return nil, err
+	}
+
+	return nodes, tx.Commit()
+}
+
+// Moves every descendant of task being deleted to trash at given time and returns them.
+// Restoring task restores them too as long as they are trashed at the same time
+func (u *TaskUpdate) DeleteDescendants(ctx context.Context, at time.Time) ([]tasks.TaskNode, error) {
+	refs, err := descendantsTx(ctx, u.tx, u.ref)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := forKindsTx(ctx, u.tx, refs, `UPDATE %[1]s SET deleted_at = $2 WHERE id = ANY($1) AND deleted_at IS NULL RETURNING %[2]s`, at)
+	if err != nil {
+		return nil, err
+	}
+
+	return nodes, recordHistoryTx(ctx, u.tx, tasks.EventTaskDeleted, nodes...)
+}
+
+// Moves every not done descendant of task being updated to done state of its workflow and returns changed ones
+func (u *TaskUpdate) CompleteDescendants(ctx context.Context, owner string) ([]tasks.TaskNode, error) {
+	refs, err := descendantsTx(ctx, u.tx, u.ref)
+	if err != nil {
+		return nil, err
+	}
+
+	open, err := forKindsTx(ctx, u.tx, refs, `SELECT %[2]s FROM %[1]s WHERE id = ANY($1) AND deleted_at IS NULL AND NOT done FOR UPDATE`)
+	if err != nil {
+		return nil, err
+	}
+
+	workflows, err := userWorkflows(ctx, u.tx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	byStatus := map[string][]tasks.TaskRef{}
+	for _, n := range open {
+		status := workflows.For(tasks.Base(n.Task).TopicID).DoneState()
+		byStatus[status] = append(byStatus[status], n.Ref())
+	}
+
+	var result []tasks.TaskNode
+	for status, refs := range byStatus {
+		nodes, err := forKindsTx(ctx, u.tx, refs, `UPDATE %[1]s SET done = TRUE, status = $2 WHERE id = ANY($1) RETURNING %[2]s`, status)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, nodes...)
+	}
+
+	return result, recordHistoryTx(ctx, u.tx, tasks.EventTaskUpdated, result...)
+}
+
+// Makes direct children of task being updated or deleted top-level and returns them
+func (u *TaskUpdate) DetachChildren(ctx context.Context) ([]tasks.TaskNode, error) {
+	var result []tasks.TaskNode
+	for _, kind := range tasks.Kinds {
+		spec := kindSpecs[kind]
+		nodes, err := queryNodesTx(
+			ctx, u.tx, kind,
+			`UPDATE
+				`+spec.table+`
+			SET
+				parent_kind = NULL, parent_id = NULL
+			WHERE
+				parent_kind = $1 AND parent_id = $2 AND deleted_at IS NULL
+			RETURNING
+				`+spec.columns,
+			u.ref.Kind, u.ref.ID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, nodes...)
+	}
+
+	return result, recordHistoryTx(ctx, u.tx, tasks.EventTaskUpdated, result...)
+}
+
+// Reports whether task has descendants. If openOnly is set, only not done ones are counted
+func HasTaskDescendants(ctx context.Context, ref tasks.TaskRef, openOnly bool) (bool, error) {
+	nodes, err := GetTaskDescendants(ctx, ref)
+	if err != nil {
+		return false, err
+	}
+
+	return slices.ContainsFunc(nodes, func(n tasks.TaskNode) bool {
+		return !openOnly || !tasks.Base(n.Task).Done
+	}), nil
+}
@@ -0,0 +1,277 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrTagExists     = errors.New("Tag with such name already exists")
+	ErrMergeIntoSelf = errors.New("Tag cannot be merged into itself")
+)
+
+const tagColumns = `id, owner, name, created_at`
+
+func tagFields(t *tasks.Tag) []any {
+	return []any{&t.ID, &t.Owner, &t.Name, &t.CreatedAt}
+}
+
+// Replaces tags of task with given names creating missing tags
+func setTaskTagsTx(ctx context.Context, tx *sql.Tx, owner string, ref tasks.TaskRef, names []string) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM task_tags WHERE task_kind = $1 AND task_id = $2`,
+		ref.Kind, ref.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		var id int
+		err := tx.QueryRowContext(
+			ctx,
+			`INSERT INTO
+				tags(owner, name, created_at)
+			VALUES
+				($1, $2, $3)
+			ON CONFLICT (owner, name) DO UPDATE SET
+				name = EXCLUDED.name
+			RETURNING
+				id`,
+			owner, name, time.Now(),
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO task_tags(tag_id, task_kind, task_id) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+			id, ref.Kind, ref.ID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Fills tags of every task of user
+func attachTagsTx(ctx context.Context, tx *sql.Tx, user *tasks.User) error {
+	nodes := tasks.UserNodes(user)
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	bases := make(map[tasks.TaskRef]*tasks.BaseTask, len(nodes))
+	kinds := make([]string, 0, len(nodes))
+	ids := make([]int, 0, len(nodes))
+	for _, n := range nodes {
+		ref := n.Ref()
+		bases[ref] = tasks.Base(n.Task)
+		kinds = append(kinds, ref.Kind)
+		ids = append(ids, ref.ID)
+	}
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT
+			tt.task_kind, tt.task_id, g.name
+		FROM
+			task_tags tt JOIN tags g ON g.id = tt.tag_id
+		WHERE
+			(tt.task_kind, tt.task_id) IN (SELECT * FROM unnest($1::VARCHAR[], $2::INTEGER[]))
+		ORDER BY
+			g.name`,
+		pq.Array(kinds), pq.Array(ids),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ref tasks.TaskRef
+		var name string
+		if err := rows.Scan(&ref.Kind, &ref.ID, &name); err != nil {
+			return err
+		}
+		if base, ok := bases[ref]; ok {
+			base.Tags = append(base.Tags, name)
+		}
+	}
+
+	return rows.Err()
+}
+
+// Returns tags of owner starting with prefix case-insensitively, most used first.
+// Limit 0 means no limit
+func GetUserTags(ctx context.Context, owner, prefix string, limit int) ([]tasks.Tag, error) {
+	query := `SELECT
+			g.id, g.owner, g.name, g.created_at, COUNT(tt.tag_id)
+		FROM
+			tags g LEFT JOIN task_tags tt ON tt.tag_id = g.id AND ` + visibleTaskCond("$1", "tt.task_kind", "tt.task_id") + `
+		WHERE
+			g.owner = $1 AND starts_with(lower(g.name), lower($2))
+		GROUP BY
+			g.id
+		ORDER BY
+			COUNT(tt.tag_id) DESC, g.name`
+	args := []any{owner, prefix}
+	if limit > 0 {
+		query += ` LIMIT $3`
+		args = append(args, limit)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []tasks.Tag{}
+	for rows.Next() {
+		var t tasks.Tag
+		if err := rows.Scan(append(tagFields(&t), &t.Usage)...); err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+
+	return result, rows.Err()
+}
+
+// Returns sql.ErrNoRows if owner has no such tag
+func GetTag(ctx context.Context, owner string, id int) (*tasks.Tag, error) {
+	var t tasks.Tag
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT
+			`+tagColumns+`
+		FROM
+			tags
+		WHERE
+			owner = $1 AND id = $2`,
+		owner, id,
+	).Scan(tagFields(&t)...)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Returns ErrTagExists if owner has another tag with such name and sql.ErrNoRows if owner has no such tag
+func RenameTag(ctx context.Context, owner string, id int, name string) (*tasks.Tag, error) {
+	var t tasks.Tag
+	err := db.QueryRowContext(
+		ctx,
+		`UPDATE
+			tags
+		SET
+			name = $3
+		WHERE
+			owner = $1 AND id = $2
+		RETURNING
+			`+tagColumns,
+		owner, id, name,
+	).Scan(tagFields(&t)...)
+	if isUniqueViolation(err) {
+		return nil, ErrTagExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Moves tasks of tag from to tag into and deletes tag from.
+// Returns ErrMergeIntoSelf if they are the same tag and sql.ErrNoRows if owner has no such tags
+func MergeTags(ctx context.Context, owner string, from, into int) error {
+	if from == into {
+		return ErrMergeIntoSelf
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*) FROM tags WHERE owner = $1 AND id IN ($2, $3)`,
+		owner, from, into,
+	).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count != 2 {
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO
+			task_tags(tag_id, task_kind, task_id)
+		SELECT
+			$2, task_kind, task_id
+		FROM
+			task_tags
+		WHERE
+			tag_id = $1
+		ON CONFLICT DO NOTHING`,
+		from, into,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE id = $1`, from); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Returns names of tags of task sorted alphabetically
+func GetTaskTags(ctx context.Context, ref tasks.TaskRef) ([]string, error) {
+	return taskTags(ctx, db, ref)
+}
+
+func taskTags(ctx context.Context, q queryer, ref tasks.TaskRef) ([]string, error) {
+	rows, err := q.QueryContext(
+		ctx,
+		`SELECT
+			g.name
+		FROM
+			task_tags tt JOIN tags g ON g.id = tt.tag_id
+		WHERE
+			tt.task_kind = $1 AND tt.task_id = $2
+		ORDER BY
+			g.name`,
+		ref.Kind, ref.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		result = append(result, name)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// Channel notified with owner of every appended task event
+const TaskEventsChannel = "task_events"
+
+type StoredTaskEvent struct {
+	ID      int64
+	Owner   string
+	Event   string
+	Payload string
+}
+
+// Stores event and notifies listeners of TaskEventsChannel.
+// Events of one owner are appended one at a time, so they commit in order of their ids
+// and reader never skips event which committed after the ones it has already seen
+func AppendTaskEvent(ctx context.Context, owner, event, payload string) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('task_events:' || $1))`, owner); err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err = tx.QueryRowContext(
+		ctx,
+		`WITH inserted AS (
+			INSERT INTO
+				task_events(owner, event, payload, created_at)
+			VALUES
+				($1, $2, $3, $4)
+			RETURNING
+				id, owner
+		)
+		SELECT
+			id
+		FROM
+			inserted, pg_notify($5, owner)`,
+		owner, event, payload, time.Now(), TaskEventsChannel,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// Returns at most limit events of owner with id greater than after, oldest first
+func GetTaskEventsAfter(ctx context.Context, owner string, after int64, limit int) ([]StoredTaskEvent, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			id, owner, event, payload
+		FROM
+			task_events
+		WHERE
+			owner = $1 AND id > $2
+		ORDER BY
+			id
+		LIMIT
+			$3`,
+		owner, after, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []StoredTaskEvent
+	for rows.Next() {
+		var e StoredTaskEvent
+		if err := rows.Scan(&e.ID, &e.Owner, &e.Event, &e.Payload); err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+
+	return result, rows.Err()
+}
+
+// Returns 0 if owner has no events
+func GetLastTaskEventID(ctx context.Context, owner string) (int64, error) {
+	var id int64
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT
+			COALESCE(MAX(id), 0)
+		FROM
+			task_events
+		WHERE
+			owner = $1`,
+		owner,
+	).Scan(&id)
+	return id, err
+}
+
+// Deletes events created before given time
+func PruneTaskEvents(ctx context.Context, before time.Time) error {
+	_, err := db.ExecContext(
+		ctx,
+		`DELETE FROM
+			task_events
+		WHERE
+			created_at < $1`,
+		before,
+	)
+	return err
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+const templateColumns = `id, owner, workspace_id, name, task, created_at, updated_at`
+
+func scanTemplate(s scanner) (tasks.Template, error) {
+	var t tasks.Template
+	var task []byte
+	if err := s.Scan(&t.ID, &t.Owner, &t.WorkspaceID, &t.Name, &task, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return t, err
+	}
+	return t, json.Unmarshal(task, &t.Task)
+}
+
+func CreateTemplate(ctx context.Context, t *tasks.Template) error {
+	task, err := json.Marshal(t.Task)
+	if err != nil {
+		return err
+	}
+
+	t.CreatedAt = time.Now()
+	t.UpdatedAt = t.CreatedAt
+	return db.QueryRowContext(
+		ctx,
+		`INSERT INTO
+			templates(owner, workspace_id, name, task, created_at, updated_at)
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+		RETURNING
+			id`,
+		t.Owner, t.WorkspaceID, t.Name, task, t.CreatedAt, t.UpdatedAt,
+	).Scan(&t.ID)
+}
+
+// Returns sql.ErrNoRows if workspace has no such template
+func GetTemplate(ctx context.Context, workspaceID, id int) (*tasks.Template, error) {
+	t, err := scanTemplate(db.QueryRowContext(
+		ctx,
+		`SELECT
+			`+templateColumns+`
+		FROM
+			templates
+		WHERE
+			id = $1 AND workspace_id = $2`,
+		id, workspaceID,
+	))
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func GetWorkspaceTemplates(ctx context.Context, workspaceID int) ([]tasks.Template, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			`+templateColumns+`
+		FROM
+			templates
+		WHERE
+			workspace_id = $1
+		ORDER BY
+			name, id`,
+		workspaceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []tasks.Template{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+
+	return result, rows.Err()
+}
+
+func UpdateTemplate(ctx context.Context, t *tasks.Template) error {
+	task, err := json.Marshal(t.Task)
+	if err != nil {
+		return err
+	}
+
+	t.UpdatedAt = time.Now()
+	_, err = db.ExecContext(
+		ctx,
+		`UPDATE
+			templates
+		SET
+			name = $2, task = $3, updated_at = $4
+		WHERE
+			id = $1`,
+		t.ID, t.Name, task, t.UpdatedAt,
+	)
+	return err
+}
+
+func DeleteTemplate(ctx context.Context, id int) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM templates WHERE id = $1`, id)
+	return err
+}
+
+// Creates tasks of instance and its descendants with their tags and checklists at once.
+// Children get their parents set, ids of created tasks are filled in.
+// Topics of tasks are found by name in workspace, default one if name is empty. Missing topics are created
+// for owner if autoCreate is set, default one always is. prepare is called for every task with its topic before it is saved
+func CreateTaskTree(
+	ctx context.Context,
+	root *tasks.TemplateInstance,
+	owner string,
+	workspaceID int,
+	autoCreate bool,
+	prepare func(*tasks.TemplateInstance, *tasks.Topic) error,
+) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	topics := map[string]*tasks.Topic{}
+	root.Walk(func(instance, parent *tasks.TemplateInstance) {
+		if err != nil {
+			return
+		}
+
+		base := tasks.Base(instance.Task)
+		name := base.Topic
+		if name == "" {
+			name = tasks.DefaultTopic
+		}
+		topic, ok := topics[name]
+		if !ok {
+			topic, err = getTopicByName(ctx, tx, workspaceID, name)
+			if err == sql.ErrNoRows && (autoCreate || name == tasks.DefaultTopic) {
+				topic, err = ensureTopic(ctx, tx, owner, workspaceID, name)
+			}
+			if err == sql.ErrNoRows {
+				err = fmt.Errorf("%w: %s", ErrTopicNotFound, name)
+			}
+			if err != nil {
+				return
+			}
+			topics[name] = topic
+		}
+		if err = prepare(instance, topic); err != nil {
+			return
+		}
+
+		if parent != nil {
+			base.SetParent(&tasks.TaskRef{Kind: parent.TaskType, ID: tasks.Base(parent.Task).ID})
+		}
+		if err = createTask(ctx, tx, instance.Task); err != nil {
+			return
+		}
+
+		ref := tasks.TaskRef{Kind: instance.TaskType, ID: base.ID}
+		if len(base.Tags) > 0 {
+			if err = setTaskTagsTx(ctx, tx, base.Owner, ref, base.Tags); err != nil {
+				return
+			}
+		}
+
+		for i, title := range instance.Checklist {
+			_, err = tx.ExecContext(
+				ctx,
+				`INSERT INTO
+					checklist_items(task_kind, task_id, title, checked, position, created_at)
+				VALUES
+					($1, $2, $3, FALSE, $4, $5)`,
+				ref.Kind, ref.ID, title, i, base.CreatedAt,
+			)
+			if err != nil {
+				return
+			}
+		}
+		base.Checklist.Total = len(instance.Checklist)
+
+		if base.Checklist.AutoComplete {
+			_, err = tx.ExecContext(ctx, `UPDATE `+kindSpecs[ref.Kind].table+` SET checklist_autocomplete = TRUE WHERE id = $1`, ref.ID)
+			if err != nil {
+				return
+			}
+		}
+
+		err = recordHistoryTx(ctx, tx, tasks.EventTaskCreated, tasks.TaskNode{TaskType: instance.TaskType, Task: instance.Task})
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
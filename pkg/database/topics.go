@@ -0,0 +1,253 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/lib/pq"
+)
+
+var ErrTopicExists = errors.New("Topic with such name already exists")
+var ErrTopicCycle = errors.New("Topic cannot be moved into itself or its descendants")
+var ErrTopicNotFound = errors.New("Topic not found")
+
+const topicColumns = `id, owner, name, color, icon, sort_order, archived, created_at, parent_id, workspace_id`
+
+func topicFields(t *tasks.Topic) []any {
+	return []any{&t.ID, &t.Owner, &t.Name, &t.Color, &t.Icon, &t.SortOrder, &t.Archived, &t.CreatedAt, &t.ParentID, &t.WorkspaceID}
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func CreateTopic(ctx context.Context, t *tasks.Topic) error {
+	t.CreatedAt = time.Now()
+	err := db.QueryRowContext(
+		ctx,
+		`INSERT INTO
+			topics(owner, name, color, icon, sort_order, archived, created_at, parent_id, workspace_id)
+		VALUES
+			($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING
+			id`,
+		t.Owner, t.Name, t.Color, t.Icon, t.SortOrder, t.Archived, t.CreatedAt, t.ParentID, t.WorkspaceID,
+	).Scan(&t.ID)
+	if isUniqueViolation(err) {
+		return ErrTopicExists
+	}
+	return err
+}
+
+// Names of topics on path from root topic like "work/auth"
+func splitTopicPath(path string) []string {
+	return strings.Split(path, tasks.TopicPathSeparator)
+}
+
+// Returns topic of workspace at given path creating it and its missing ancestors for owner
+func EnsureTopic(ctx context.Context, owner string, workspaceID int, path string) (*tasks.Topic, error) {
+	return ensureTopic(ctx, db, owner, workspaceID, path)
+}
+
+func ensureTopic(ctx context.Context, q rowQueryer, owner string, workspaceID int, path string) (*tasks.Topic, error) {
+	var t tasks.Topic
+	var parentID *int
+	for _, name := range splitTopicPath(path) {
+		err := q.QueryRowContext(
+			ctx,
+			`INSERT INTO
+				topics(owner, name, created_at, workspace_id, parent_id)
+			VALUES
+				($1, $2, $3, $4, $5)
+			ON CONFLICT (workspace_id, COALESCE(parent_id, 0), name) DO UPDATE SET
+				name = EXCLUDED.name
+			RETURNING
+				`+topicColumns,
+			owner, name, time.Now(), workspaceID, parentID,
+		).Scan(topicFields(&t)...)
+		if err != nil {
+			return nil, err
+		}
+		id := t.ID
+		parentID = &id
+	}
+	return &t, nil
+}
+
+// Returns sql.ErrNoRows if workspace has no such topic
+func GetTopic(ctx context.Context, workspaceID, id int) (*tasks.Topic, error) {
+	var t tasks.Topic
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT
+			`+topicColumns+`
+		FROM
+			topics
+		WHERE
+			workspace_id = $1 AND id = $2`,
+		workspaceID, id,
+	).Scan(topicFields(&t)...)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Returns topic of workspace at given path from root topic.
+// Returns sql.ErrNoRows if workspace has no such topic
+func GetTopicByName(ctx context.Context, workspaceID int, path string) (*tasks.Topic, error) {
+	return getTopicByName(ctx, db, workspaceID, path)
+}
+
+func getTopicByName(ctx context.Context, q rowQueryer, workspaceID int, path string) (*tasks.Topic, error) {
+	var t tasks.Topic
+	parentID := 0
+	for _, name := range splitTopicPath(path) {
+		err := q.QueryRowContext(
+			ctx,
+			`SELECT
+				`+topicColumns+`
+			FROM
+				topics
+			WHERE
+				workspace_id = $1 AND name = $2 AND COALESCE(parent_id, 0) = $3`,
+			workspaceID, name, parentID,
+		).Scan(topicFields(&t)...)
+		if err != nil {
+			return nil, err
+		}
+		parentID = t.ID
+	}
+	return &t, nil
+}
+
+// Returns path of topic from root topic like "work/auth".
+// Returns sql.ErrNoRows if there is no such topic
+func GetTopicPath(ctx context.Context, id int) (string, error) {
+	var path sql.NullString
+	err := db.QueryRowContext(ctx, `SELECT topic_path($1)`, id).Scan(&path)
+	if err != nil {
+		return "", err
+	}
+	if !path.Valid {
+		return "", sql.ErrNoRows
+	}
+	return path.String, nil
+}
+
+// Returns every topic of workspace with counts of tasks of the topic itself
+func GetWorkspaceTopics(ctx context.Context, workspaceID int, now time.Time) ([]tasks.TopicStats, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			t.id, t.owner, t.name, t.color, t.icon, t.sort_order, t.archived, t.created_at, t.parent_id, t.workspace_id,
+			COUNT(a.id) FILTER (WHERE NOT a.done),
+			COUNT(a.id) FILTER (WHERE a.done),
+			COUNT(a.id) FILTER (WHERE NOT a.done AND a.due_at < $2)
+		FROM
+			topics t LEFT JOIN all_tasks a ON a.topic_id = t.id
+		WHERE
+			t.workspace_id = $1
+		GROUP BY
+			t.id
+		ORDER BY
+			t.sort_order, t.name`,
+		workspaceID, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []tasks.TopicStats{}
+	for rows.Next() {
+		var s tasks.TopicStats
+		if err := rows.Scan(append(topicFields(&s.Topic), &s.Open, &s.Done, &s.Overdue)...); err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+
+	return result, rows.Err()
+}
+
+// Saves every field of topic except owner and workspace. Tasks of topic get its new name.
+// Returns sql.ErrNoRows if workspace has no such topic and ErrTopicCycle if new parent is inside topic subtree
+func UpdateTopic(ctx context.Context, t *tasks.Topic) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if t.ParentID != nil {
+		// Concurrent moves of workspace topics could create a cycle together
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('topics:' || $1))`, t.WorkspaceID); err != nil {
+			return err
+		}
+
+		var cycle bool
+		err = tx.QueryRowContext(
+			ctx,
+			`WITH RECURSIVE ancestors(id) AS (
+				SELECT $1::INTEGER
+				UNION
+				SELECT
+					t.parent_id
+				FROM
+					topics t JOIN ancestors a ON t.id = a.id
+				WHERE
+					t.parent_id IS NOT NULL
+			)
+			SELECT EXISTS(SELECT 1 FROM ancestors WHERE id = $2)`,
+			*t.ParentID, t.ID,
+		).Scan(&cycle)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrTopicCycle
+		}
+	}
+
+	err = tx.QueryRowContext(
+		ctx,
+		`UPDATE
+			topics
+		SET
+			name = $3, color = $4, icon = $5, sort_order = $6, archived = $7, parent_id = $8
+		WHERE
+			workspace_id = $1 AND id = $2
+		RETURNING
+			created_at`,
+		t.WorkspaceID, t.ID, t.Name, t.Color, t.Icon, t.SortOrder, t.Archived, t.ParentID,
+	).Scan(&t.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrTopicExists
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, kind := range tasks.Kinds {
+		spec := kindSpecs[kind]
+		nodes, err := queryNodesTx(
+			ctx, tx, kind,
+			`UPDATE `+spec.table+` SET topic = $2 WHERE topic_id = $1 AND topic <> $2 RETURNING `+spec.columns,
+			t.ID, t.Name,
+		)
+		if err != nil {
+			return err
+		}
+		if err := recordHistoryTx(ctx, tx, tasks.EventTaskUpdated, nodes...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
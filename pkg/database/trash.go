@@ -0,0 +1,254 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+// Returns tasks in trash of workspace which username has admin access to, the most recently deleted first
+func GetTrashedTasks(ctx context.Context, username string, workspaceID int) ([]tasks.TaskNode, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := []tasks.TaskNode{}
+	for _, kind := range tasks.Kinds {
+		spec := kindSpecs[kind]
+		nodes, err := queryNodesTx(
+			ctx, tx, kind,
+			`SELECT
+				`+spec.columns+`
+			FROM
+				`+spec.table+`
+			WHERE
+				deleted_at IS NOT NULL AND workspace_id = $2 AND
+				task_access($1, $3, id, owner, topic_id, assignee) >= acl_rank('admin')`,
+			username, workspaceID, kind,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, nodes...)
+	}
+
+	slices.SortFunc(result, func(a, b tasks.TaskNode) int {
+		return tasks.Base(b.Task).DeletedAt.Compare(*tasks.Base(a.Task).DeletedAt)
+	})
+
+	return result, tx.Commit()
+}
+
+// Returns access level of username to task in trash, empty if there is none.
+// Returns sql.ErrNoRows if there is no such task in trash
+func GetTrashedTaskAccess(ctx context.Context, username string, ref tasks.TaskRef) (string, error) {
+	var rank sql.NullInt16
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT
+			task_access($1, kind, id, owner, topic_id, assignee)
+		FROM
+			trashed_tasks
+		WHERE
+			kind = $2 AND id = $3`,
+		username, ref.Kind, ref.ID,
+	).Scan(&rank)
+	if err != nil {
+		return "", err
+	}
+	return tasks.AccessLevel(int(rank.Int16)), nil
+}
+
+// Returns task in trash and its descendants moved to trash along with it
+func trashBatchTx(ctx context.Context, tx *sql.Tx, ref tasks.TaskRef) ([]tasks.TaskRef, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		`WITH RECURSIVE batch(kind, id, deleted_at) AS (
+			SELECT
+				kind, id, deleted_at
+			FROM
+				trashed_tasks
+			WHERE
+				kind = $1 AND id = $2
+			UNION
+			SELECT
+				t.kind, t.id, t.deleted_at
+			FROM
+				trashed_tasks t JOIN batch b ON t.parent_kind = b.kind AND t.parent_id = b.id AND t.deleted_at = b.deleted_at
+		)
+		SELECT kind, id FROM batch`,
+		ref.Kind, ref.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []tasks.TaskRef
+	for rows.Next() {
+		var r tasks.TaskRef
+		if err := rows.Scan(&r.Kind, &r.ID); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return result, nil
+}
+
+// Moves task of ref to the front of nodes
+func rootFirst(nodes []tasks.TaskNode, ref tasks.TaskRef) {
+	i := slices.IndexFunc(nodes, func(n tasks.TaskNode) bool { return n.Ref() == ref })
+	if i > 0 {
+		nodes[0], nodes[i] = nodes[i], nodes[0]
+	}
+}
+
+// Takes task out of trash along with descendants deleted with it and returns them, task first.
+// Task becomes top-level if its parent is purged or still in trash.
+// Returns sql.ErrNoRows if task is not in trash
+func RestoreTask(ctx context.Context, ref tasks.TaskRef) ([]tasks.TaskNode, error) {
+	spec, ok := kindSpecs[ref.Kind]
+	if !ok {
+		return nil, ErrInvalidKind
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	refs, err := trashBatchTx(ctx, tx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE
+			`+spec.table+`
+		SET
+			parent_kind = NULL, parent_id = NULL
+		WHERE
+			id = $1 AND parent_id IS NOT NULL AND (parent_kind, parent_id) NOT IN (SELECT kind, id FROM all_tasks)`,
+		ref.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := forKindsTx(ctx, tx, refs, `UPDATE %[1]s SET deleted_at = NULL WHERE id = ANY($1) AND deleted_at IS NOT NULL RETURNING %[2]s`)
+	if err != nil {
+		return nil, err
+	}
+	rootFirst(nodes, ref)
+
+	if err := recordHistoryTx(ctx, tx, tasks.EventTaskRestored, nodes...); err != nil {
+		return nil, err
+	}
+
+	return nodes, tx.Commit()
+}
+
+// Permanently deletes task in trash along with descendants deleted with it and returns them, task first.
+// Returns sql.ErrNoRows if task is not in trash
+func PurgeTask(ctx context.Context, ref tasks.TaskRef) ([]tasks.TaskNode, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	refs, err := trashBatchTx(ctx, tx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := purgeTx(ctx, tx, refs)
+	if err != nil {
+		return nil, err
+	}
+	rootFirst(nodes, ref)
+
+	return nodes, tx.Commit()
+}
+
+// Permanently deletes tasks in trash and returns them. Their last versions are recorded in history with their tags
+func purgeTx(ctx context.Context, tx *sql.Tx, refs []tasks.TaskRef) ([]tasks.TaskNode, error) {
+	nodes, err := forKindsTx(ctx, tx, refs, `SELECT %[2]s FROM %[1]s WHERE id = ANY($1) AND deleted_at IS NOT NULL FOR UPDATE`)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordHistoryTx(ctx, tx, tasks.EventTaskPurged, nodes...); err != nil {
+		return nil, err
+	}
+
+	if _, err := forKindsTx(ctx, tx, taskRefs(nodes), `DELETE FROM %[1]s WHERE id = ANY($1) RETURNING %[2]s`); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+func taskRefs(nodes []tasks.TaskNode) []tasks.TaskRef {
+	refs := make([]tasks.TaskRef, 0, len(nodes))
+	for _, n := range nodes {
+		refs = append(refs, n.Ref())
+	}
+	return refs
+}
+
+// Permanently deletes at most limit tasks moved to trash before given time and returns how many were deleted.
+// Safe to run on several instances at once
+func PurgeTrash(ctx context.Context, before time.Time, limit int) (int, error) {
+	purged := 0
+	for _, kind := range tasks.Kinds {
+		if purged >= limit {
+			break
+		}
+
+		n, err := purgeExpired(ctx, kind, before, limit-purged)
+		if err != nil {
+			return purged, err
+		}
+		purged += n
+	}
+
+	return purged, nil
+}
+
+func purgeExpired(ctx context.Context, kind string, before time.Time, limit int) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	spec := kindSpecs[kind]
+	expired, err := queryNodesTx(
+		ctx, tx, kind,
+		`SELECT `+spec.columns+` FROM `+spec.table+` WHERE deleted_at < $1 ORDER BY deleted_at LIMIT $2 FOR UPDATE SKIP LOCKED`,
+		before, limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	nodes, err := purgeTx(ctx, tx, taskRefs(expired))
+	if err != nil {
+		return 0, err
+	}
+
+	return len(nodes), tx.Commit()
+}
@@ -2,214 +2,216 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"slices"
+	"time"
 
 	tasks "github.com/Kry0z1/fancytasks/pkg"
 	"github.com/lib/pq"
 )
 
-// Inserts values from db to task.
-// When returned func is called, task values update in db
-//
-// If error is nil, then returned function should be called, otherwise connection to db will hang
-func UpdateBaseTask(ctx context.Context, task *tasks.BaseTask) (func(context.Context) error, error) {
-	tx, err := db.Begin()
+// Update of task started by Update* or Delete* functions. Task stays locked until Commit or Rollback
+type TaskUpdate struct {
+	tx  *sql.Tx
+	ref tasks.TaskRef
+	// Task as it is saved and recorded in history as action
+	task   any
+	action string
+	save   func(context.Context) error
+	// Copy of task as it was loaded, reminders are moved on Commit if its time changes
+	loaded any
+}
+
+// Replaces tags of task with given names creating missing tags. Saved along with task on Commit
+func (u *TaskUpdate) SetTags(ctx context.Context, owner string, names []string) error {
+	return setTaskTagsTx(ctx, u.tx, owner, u.ref, names)
+}
+
+// Saves changes of task and records its new version in history
+func (u *TaskUpdate) Commit(ctx context.Context) error {
+	defer u.tx.Rollback()
+
+	if err := u.save(ctx); err != nil {
+		return err
+	}
+	if u.loaded != nil && reminderTimeChanged(u.loaded, u.task) {
+		if err := rescheduleRemindersTx(ctx, u.tx, u.ref, u.task, time.Now()); err != nil {
+			return err
+		}
+	}
+	if err := recordHistoryTx(ctx, u.tx, u.action, tasks.TaskNode{TaskType: u.ref.Kind, Task: u.task}); err != nil {
+		return err
+	}
+	return u.tx.Commit()
+}
+
+// Releases task without saving changes. Does nothing after Commit
+func (u *TaskUpdate) Rollback() {
+	u.tx.Rollback()
+}
+
+// Loads task and locks it for update
+func UpdateBaseTask(ctx context.Context, task *tasks.BaseTask) (*TaskUpdate, error) {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	err = tx.QueryRowContext(
+	err = scanBaseTask(tx.QueryRowContext(
 		ctx,
 		`SELECT 
-			title, description, done, owner, topic
+			`+baseTaskColumns+`
 		FROM 
 			base_tasks 
 		WHERE 
-			id = $1 
+			id = $1 AND deleted_at IS NULL
 		FOR UPDATE`,
 		task.ID,
-	).Scan(&task.Title, &task.Description, &task.Done, &task.Owner, &task.Topic)
+	), task)
 
 	if err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
-	return func(ctx context.Context) error {
-		defer tx.Rollback()
-
+	return &TaskUpdate{tx: tx, ref: tasks.TaskRef{Kind: tasks.KindBaseTask, ID: task.ID}, task: task, action: tasks.EventTaskUpdated, save: func(ctx context.Context) error {
 		_, err := tx.ExecContext(
 			ctx,
 			`UPDATE 
 				base_tasks 
 			SET 
-				title=$1,description=$2,done=$3,owner=$4,topic=$6
+				title=$1,description=$2,done=$3,owner=$4,topic=$6,parent_kind=$7,parent_id=$8,topic_id=$9,priority=$10,status=$11,status_changed_at=$12
 			WHERE 
 				id=$5`,
-			task.Title, task.Description, task.Done, task.Owner, task.ID, task.Topic,
+			task.Title, task.Description, task.Done, task.Owner, task.ID, task.Topic, task.ParentType, task.ParentID, task.TopicID, task.Priority, task.Status, task.StatusChangedAt,
 		)
 
-		if err != nil {
-			return err
-		}
-
-		tx.Commit()
-
-		return nil
-	}, nil
+		return err
+	}}, nil
 }
 
-// Inserts values from db to task.
-// When returned func is called, task values update in db
-//
-// If error is nil, then returned function should be called, otherwise connection to db will hang
-func UpdateEvent(ctx context.Context, task *tasks.Event) (func(context.Context) error, error) {
-	tx, err := db.Begin()
+// Loads task and locks it for update
+func UpdateEvent(ctx context.Context, task *tasks.Event) (*TaskUpdate, error) {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	err = tx.QueryRowContext(
+	err = scanEvent(tx.QueryRowContext(
 		ctx,
 		`SELECT 
-			title, description, done, owner, starts_at, ends_at, topic
+			`+eventColumns+`
 		FROM 
 			events 
 		WHERE 
-			id = $1 
+			id = $1 AND deleted_at IS NULL
 		FOR UPDATE`,
 		task.ID,
-	).Scan(&task.Title, &task.Description, &task.Done, &task.Owner, &task.StartsAt, &task.EndsAt, &task.Topic)
+	), task)
 
 	if err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
-	return func(ctx context.Context) error {
-		defer tx.Rollback()
-
+	loaded := *task
+	return &TaskUpdate{tx: tx, ref: tasks.TaskRef{Kind: tasks.KindEvent, ID: task.ID}, task: task, action: tasks.EventTaskUpdated, loaded: &loaded, save: func(ctx context.Context) error {
 		_, err := tx.ExecContext(
 			ctx,
 			`UPDATE 
 				events 
 			SET 
-				title=$1,description=$2,done=$3,owner=$4,starts_at=$5,ends_at=$6,topic=$8
+				title=$1,description=$2,done=$3,owner=$4,starts_at=$5,ends_at=$6,topic=$8,parent_kind=$9,parent_id=$10,topic_id=$11,priority=$12,status=$13,status_changed_at=$14
 			WHERE 
 				id=$7`,
-			task.Title, task.Description, task.Done, task.Owner, task.StartsAt, task.EndsAt, task.ID, task.Topic,
+			task.Title, task.Description, task.Done, task.Owner, task.StartsAt, task.EndsAt, task.ID, task.Topic, task.ParentType, task.ParentID, task.TopicID, task.Priority, task.Status, task.StatusChangedAt,
 		)
 
-		if err != nil {
-			return err
-		}
-
-		tx.Commit()
-
-		return nil
-	}, nil
+		return err
+	}}, nil
 }
 
-// Inserts values from db to task.
-// When returned func is called, task values update in db
-//
-// If error is nil, then returned function should be called, otherwise connection to db will hang
-func UpdateTaskWithDeadline(ctx context.Context, task *tasks.TaskWithDeadline) (func(context.Context) error, error) {
-	tx, err := db.Begin()
+// Loads task and locks it for update
+func UpdateTaskWithDeadline(ctx context.Context, task *tasks.TaskWithDeadline) (*TaskUpdate, error) {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	err = tx.QueryRowContext(
+	err = scanTaskWithDeadline(tx.QueryRowContext(
 		ctx,
 		`SELECT 
-			title, description, done, owner, deadline, topic 
+			`+taskWithDeadlineColumns+`
 		FROM 
 			tasks_with_deadline 
 		WHERE 
-			id = $1 
+			id = $1 AND deleted_at IS NULL
 		FOR UPDATE`,
 		task.ID,
-	).Scan(&task.Title, &task.Description, &task.Done, &task.Owner, &task.Deadline, &task.Topic)
+	), task)
 
 	if err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
-	return func(ctx context.Context) error {
-		defer tx.Rollback()
-
+	loaded := *task
+	return &TaskUpdate{tx: tx, ref: tasks.TaskRef{Kind: tasks.KindTaskWithDeadline, ID: task.ID}, task: task, action: tasks.EventTaskUpdated, loaded: &loaded, save: func(ctx context.Context) error {
 		_, err := tx.ExecContext(
 			ctx,
 			`UPDATE 
 				tasks_with_deadline 
 			SET 
-				title=$1,description=$2,done=$3,owner=$4,deadline=$5,topic=$7
+				title=$1,description=$2,done=$3,owner=$4,deadline=$5,topic=$7,parent_kind=$8,parent_id=$9,topic_id=$10,priority=$11,status=$12,status_changed_at=$13
 			WHERE 
 				id=$6`,
-			task.Title, task.Description, task.Done, task.Owner, task.Deadline, task.ID, task.Topic,
+			task.Title, task.Description, task.Done, task.Owner, task.Deadline, task.ID, task.Topic, task.ParentType, task.ParentID, task.TopicID, task.Priority, task.Status, task.StatusChangedAt,
 		)
 
-		if err != nil {
-			return err
-		}
-
-		tx.Commit()
-
-		return nil
-	}, nil
+		return err
+	}}, nil
 }
 
-// Inserts values from db to task.
-// When returned func is called, task values update in db
-//
-// If error is nil, then returned function should be called, otherwise connection to db will hang
-func UpdateRepeatingTask(ctx context.Context, task *tasks.RepeatingTask) (func(context.Context) error, error) {
-	tx, err := db.Begin()
+// Loads task and locks it for update
+func UpdateRepeatingTask(ctx context.Context, task *tasks.RepeatingTask) (*TaskUpdate, error) {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	err = tx.QueryRowContext(
+	err = scanRepeatingTask(tx.QueryRowContext(
 		ctx,
 		`SELECT 
-			title, description, done, owner, starts_at, ends_at, period, loop, excepts, topic 
+			`+repeatingTaskColumns+`
 		FROM 
 			repeating_tasks 
 		WHERE 
-			id = $1 
+			id = $1 AND deleted_at IS NULL
 		FOR UPDATE`,
 		task.ID,
-	).Scan(&task.Title, &task.Description, &task.Done, &task.Owner,
-		&task.StartsAt, &task.EndsAt, &task.Period, &task.Loop, pq.Array(&task.Except), &task.Topic)
+	), task)
 
 	if err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
-	return func(ctx context.Context) error {
-		defer tx.Rollback()
-
+	loaded := *task
+	loaded.Except = slices.Clone(task.Except)
+	return &TaskUpdate{tx: tx, ref: tasks.TaskRef{Kind: tasks.KindRepeatingTask, ID: task.ID}, task: task, action: tasks.EventTaskUpdated, loaded: &loaded, save: func(ctx context.Context) error {
 		_, err := tx.ExecContext(
 			ctx,
 			`UPDATE 
 				repeating_tasks
 			SET 
 				title=$1,description=$2,done=$3,owner=$4,starts_at=$5,
-				ends_at=$6,period=$7,loop=$8,excepts=$9,topic=$11
+				ends_at=$6,period=$7,loop=$8,excepts=$9,topic=$11,parent_kind=$12,parent_id=$13,topic_id=$14,priority=$15,status=$16,status_changed_at=$17,months=$18
 			WHERE 
 				id=$10`,
 			task.Title, task.Description, task.Done, task.Owner, task.StartsAt,
-			task.EndsAt, task.Period, task.Loop, pq.Array(task.Except), task.ID, task.Topic,
+			task.EndsAt, task.Period, task.Loop, pq.Array(task.Except), task.ID, task.Topic, task.ParentType, task.ParentID, task.TopicID, task.Priority, task.Status, task.StatusChangedAt, task.Months,
 		)
 
-		if err != nil {
-			return err
-		}
-
-		tx.Commit()
-
-		return nil
-	}, nil
+		return err
+	}}, nil
 }
@@ -0,0 +1,301 @@
+package database
+
+import (
+	"context"
+	"log"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+	"github.com/lib/pq"
+)
+
+const webhookColumns = `id, owner, url, secret, events, active, created_at`
+
+func webhookFields(w *tasks.Webhook) []any {
+	return []any{&w.ID, &w.Owner, &w.URL, &w.Secret, pq.Array(&w.Events), &w.Active, &w.CreatedAt}
+}
+
+const deliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at,
+	response_code, error, created_at, delivered_at`
+
+func deliveryFields(d *tasks.WebhookDelivery) []any {
+	return []any{&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&d.ResponseCode, &d.Error, &d.CreatedAt, &d.DeliveredAt}
+}
+
+func CreateWebhook(ctx context.Context, w *tasks.Webhook) error {
+	w.Active = true
+	w.CreatedAt = time.Now()
+
+	return db.QueryRowContext(
+		ctx,
+		`INSERT INTO
+			webhooks(owner, url, secret, events, active, created_at)
+		VALUES
+			($1, $2, $3, $4, $5, $6)
+		RETURNING
+			id`,
+		w.Owner, w.URL, w.Secret, pq.Array(w.Events), w.Active, w.CreatedAt,
+	).Scan(&w.ID)
+}
+
+func GetUserWebhooks(ctx context.Context, owner string) ([]tasks.Webhook, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			`+webhookColumns+`
+		FROM
+			webhooks
+		WHERE
+			owner = $1
+		ORDER BY
+			id`,
+		owner,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []tasks.Webhook{}
+	for rows.Next() {
+		var w tasks.Webhook
+		if err := rows.Scan(webhookFields(&w)...); err != nil {
+			return nil, err
+		}
+		result = append(result, w)
+	}
+
+	return result, rows.Err()
+}
+
+// Returns sql.ErrNoRows if owner has no such webhook
+func GetWebhook(ctx context.Context, owner string, id int) (*tasks.Webhook, error) {
+	var w tasks.Webhook
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT
+			`+webhookColumns+`
+		FROM
+			webhooks
+		WHERE
+			owner = $1 AND id = $2`,
+		owner, id,
+	).Scan(webhookFields(&w)...)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// Deletes webhook with its deliveries. Returns sql.ErrNoRows if owner has no such webhook
+func DeleteWebhook(ctx context.Context, owner string, id int) error {
+	return db.QueryRowContext(
+		ctx,
+		`DELETE FROM
+			webhooks
+		WHERE
+			owner = $1 AND id = $2
+		RETURNING
+			id`,
+		owner, id,
+	).Scan(&id)
+}
+
+// Schedules delivery of payload to every active webhook of owner subscribed to event
+func EnqueueWebhookDeliveries(ctx context.Context, owner, event, payload string) error {
+	now := time.Now()
+	_, err := db.ExecContext(
+		ctx,
+		`INSERT INTO
+			webhook_deliveries(webhook_id, event, payload, status, attempts, next_attempt_at, created_at)
+		SELECT
+			id, $2, $3, $4, 0, $5, $5
+		FROM
+			webhooks
+		WHERE
+			owner = $1 AND active AND $2 = ANY(events)`,
+		owner, event, payload, tasks.DeliveryPending, now,
+	)
+	return err
+}
+
+// Schedules delivery of payload to one webhook regardless of its subscriptions
+func EnqueueWebhookDelivery(ctx context.Context, webhookID int, event, payload string) (*tasks.WebhookDelivery, error) {
+	now := time.Now()
+	d := tasks.WebhookDelivery{
+		WebhookID:     webhookID,
+		Event:         event,
+		Payload:       payload,
+		Status:        tasks.DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+
+	err := db.QueryRowContext(
+		ctx,
+		`INSERT INTO
+			webhook_deliveries(webhook_id, event, payload, status, attempts, next_attempt_at, created_at)
+		VALUES
+			($1, $2, $3, $4, 0, $5, $6)
+		RETURNING
+			id`,
+		d.WebhookID, d.Event, d.Payload, d.Status, d.NextAttemptAt, d.CreatedAt,
+	).Scan(&d.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Returns latest deliveries of webhook first
+func GetWebhookDeliveries(ctx context.Context, webhookID int, limit int) ([]tasks.WebhookDelivery, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			`+deliveryColumns+`
+		FROM
+			webhook_deliveries
+		WHERE
+			webhook_id = $1
+		ORDER BY
+			id DESC
+		LIMIT
+			$2`,
+		webhookID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []tasks.WebhookDelivery{}
+	for rows.Next() {
+		var d tasks.WebhookDelivery
+		if err := rows.Scan(deliveryFields(&d)...); err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+
+	return result, rows.Err()
+}
+
+// Result of one delivery attempt
+type DeliveryResult struct {
+	// 0 if no response was received
+	ResponseCode int
+	Err          error
+}
+
+// Calls deliver for at most limit pending deliveries to active webhooks due at now and returns how many were claimed.
+// Deliveries are claimed by moving their next attempt to now+lease, so concurrent callers never get the same ones
+// and deliver is called outside of transaction. Delivery left by caller that died is claimed again once lease passes.
+// Failed delivery is retried after backoff(attempts) until maxAttempts is reached
+func ProcessDueWebhookDeliveries(
+	ctx context.Context,
+	now time.Time,
+	limit int,
+	lease time.Duration,
+	maxAttempts int,
+	backoff func(attempts int) time.Duration,
+	deliver func(context.Context, tasks.Webhook, tasks.WebhookDelivery) DeliveryResult,
+) (int, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`UPDATE
+			webhook_deliveries d
+		SET
+			next_attempt_at = $1
+		FROM
+			webhooks w
+		WHERE
+			w.id = d.webhook_id AND d.id IN (
+				SELECT
+					cd.id
+				FROM
+					webhook_deliveries cd JOIN webhooks cw ON cw.id = cd.webhook_id
+				WHERE
+					cd.status = $2 AND cd.next_attempt_at <= $3 AND cw.active
+				ORDER BY
+					cd.next_attempt_at
+				LIMIT
+					$4
+				FOR UPDATE OF cd SKIP LOCKED
+			)
+		RETURNING
+			d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+			d.response_code, d.error, d.created_at, d.delivered_at,
+			w.id, w.owner, w.url, w.secret, w.events, w.active, w.created_at`,
+		now.Add(lease), tasks.DeliveryPending, now, limit,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	type due struct {
+		d tasks.WebhookDelivery
+		w tasks.Webhook
+	}
+	var batch []due
+	for rows.Next() {
+		var it due
+		if err := rows.Scan(append(deliveryFields(&it.d), webhookFields(&it.w)...)...); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, it)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, it := range batch {
+		d := it.d
+		res := deliver(ctx, it.w, d)
+
+		d.Attempts++
+		if res.ResponseCode != 0 {
+			d.ResponseCode = &res.ResponseCode
+		}
+
+		if res.Err == nil {
+			deliveredAt := time.Now()
+			d.Status = tasks.DeliveryDelivered
+			d.DeliveredAt = &deliveredAt
+			d.Error = nil
+		} else {
+			msg := truncate(res.Err.Error(), 512)
+			d.Error = &msg
+
+			if d.Attempts >= maxAttempts {
+				d.Status = tasks.DeliveryFailed
+			} else {
+				d.NextAttemptAt = time.Now().Add(backoff(d.Attempts))
+			}
+			log.Printf("Couldn't deliver webhook %d: %s", d.ID, msg)
+		}
+
+		if err := saveDelivery(ctx, &d); err != nil {
+			return len(batch), err
+		}
+	}
+
+	return len(batch), nil
+}
+
+func saveDelivery(ctx context.Context, d *tasks.WebhookDelivery) error {
+	_, err := db.ExecContext(
+		ctx,
+		`UPDATE
+			webhook_deliveries
+		SET
+			status = $2, attempts = $3, next_attempt_at = $4,
+			response_code = $5, error = $6, delivered_at = $7
+		WHERE
+			id = $1`,
+		d.ID, d.Status, d.Attempts, d.NextAttemptAt, d.ResponseCode, d.Error, d.DeliveredAt,
+	)
+	return err
+}
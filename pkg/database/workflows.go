@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+// Common interface of *sql.DB and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func userWorkflows(ctx context.Context, q queryer, owner string) (tasks.Workflows, error) {
+	defaultWorkflow := tasks.Cfg.Workflow
+	result := tasks.Workflows{Default: &defaultWorkflow, ByTopic: map[int]*tasks.Workflow{}}
+
+	rows, err := q.QueryContext(
+		ctx,
+		`SELECT
+			id, owner, topic_id, states, transitions
+		FROM
+			workflows
+		WHERE
+			topic_id IS NULL AND owner = $1 OR
+			topic_id IN (
+				SELECT t.id FROM topics t JOIN workspace_members m ON m.workspace_id = t.workspace_id
+				WHERE m.username = $1
+			)`,
+		owner,
+	)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var w tasks.Workflow
+		var states, transitions []byte
+		if err := rows.Scan(&w.ID, &w.Owner, &w.TopicID, &states, &transitions); err != nil {
+			return result, err
+		}
+		if err := json.Unmarshal(states, &w.States); err != nil {
+			return result, err
+		}
+		if err := json.Unmarshal(transitions, &w.Transitions); err != nil {
+			return result, err
+		}
+
+		if w.TopicID == nil {
+			result.Default = &w
+		} else {
+			result.ByTopic[*w.TopicID] = &w
+		}
+	}
+
+	return result, rows.Err()
+}
+
+// Returns workflows of owner. Default workflow comes from config if owner hasn't set one
+func GetUserWorkflows(ctx context.Context, owner string) (tasks.Workflows, error) {
+	return userWorkflows(ctx, db, owner)
+}
+
+// Creates or replaces default workflow of owner or workflow of topic shared by its workspace
+func SaveWorkflow(ctx context.Context, w *tasks.Workflow) error {
+	states, err := json.Marshal(w.States)
+	if err != nil {
+		return err
+	}
+	transitions, err := json.Marshal(w.Transitions)
+	if err != nil {
+		return err
+	}
+
+	conflict := `(owner, COALESCE(topic_id, 0))`
+	if w.TopicID != nil {
+		conflict = `(topic_id) WHERE topic_id IS NOT NULL`
+	}
+
+	return db.QueryRowContext(
+		ctx,
+		`INSERT INTO
+			workflows(owner, topic_id, states, transitions)
+		VALUES
+			($1, $2, $3, $4)
+		ON CONFLICT `+conflict+` DO UPDATE SET
+			states = EXCLUDED.states, transitions = EXCLUDED.transitions
+		RETURNING
+			id`,
+		w.Owner, w.TopicID, states, transitions,
+	).Scan(&w.ID)
+}
+
+// Deletes workflow of topic or default workflow of owner if topicID is nil so that default one is used instead.
+// Returns sql.ErrNoRows if there is no such workflow
+func DeleteWorkflow(ctx context.Context, owner string, topicID *int) error {
+	query, args := `DELETE FROM workflows WHERE owner = $1 AND topic_id IS NULL`, []any{owner}
+	if topicID != nil {
+		query, args = `DELETE FROM workflows WHERE topic_id = $1`, []any{*topicID}
+	}
+
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Returns status changes of task from the oldest
+func GetTaskTransitions(ctx context.Context, ref tasks.TaskRef) ([]tasks.Transition, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			from_status, to_status, at
+		FROM
+			task_transitions
+		WHERE
+			task_kind = $1 AND task_id = $2
+		ORDER BY
+			at, id`,
+		ref.Kind, ref.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []tasks.Transition{}
+	for rows.Next() {
+		var t tasks.Transition
+		if err := rows.Scan(&t.From, &t.To, &t.At); err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,253 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+var ErrMemberExists = errors.New("User is already a member of workspace")
+
+const workspaceColumns = `w.id, w.name, w.owner, w.personal, w.created_at, m.role`
+
+func workspaceFields(w *tasks.Workspace) []any {
+	return []any{&w.ID, &w.Name, &w.Owner, &w.Personal, &w.CreatedAt, &w.Role}
+}
+
+// Returns personal workspace of username
+func GetPersonalWorkspace(ctx context.Context, username string) (*tasks.Workspace, error) {
+	var w tasks.Workspace
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT
+			`+workspaceColumns+`
+		FROM
+			workspaces w JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE
+			w.personal AND w.owner = $1 AND m.username = $1`,
+		username,
+	).Scan(workspaceFields(&w)...)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// Returns workspace with role of username in it.
+// Returns sql.ErrNoRows if there is no such workspace or username is not its member
+func GetMemberWorkspace(ctx context.Context, username string, id int) (*tasks.Workspace, error) {
+	var w tasks.Workspace
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT
+			`+workspaceColumns+`
+		FROM
+			workspaces w JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE
+			w.id = $2 AND m.username = $1`,
+		username, id,
+	).Scan(workspaceFields(&w)...)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// Returns workspaces username is member of, personal one first
+func GetUserWorkspaces(ctx context.Context, username string) ([]tasks.Workspace, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			`+workspaceColumns+`
+		FROM
+			workspaces w JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE
+			m.username = $1
+		ORDER BY
+			w.personal DESC, w.name, w.id`,
+		username,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []tasks.Workspace{}
+	for rows.Next() {
+		var w tasks.Workspace
+		if err := rows.Scan(workspaceFields(&w)...); err != nil {
+			return nil, err
+		}
+		result = append(result, w)
+	}
+
+	return result, rows.Err()
+}
+
+// Creates team workspace with its owner as the only member
+func CreateWorkspace(ctx context.Context, w *tasks.Workspace) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	w.Personal = false
+	w.CreatedAt = time.Now()
+	w.Role = tasks.RoleOwner
+	err = tx.QueryRowContext(
+		ctx,
+		`INSERT INTO
+			workspaces(name, owner, personal, created_at)
+		VALUES
+			($1, $2, FALSE, $3)
+		RETURNING
+			id`,
+		w.Name, w.Owner, w.CreatedAt,
+	).Scan(&w.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO workspace_members(workspace_id, username, role, created_at) VALUES ($1, $2, $3, $4)`,
+		w.ID, w.Owner, tasks.RoleOwner, w.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func RenameWorkspace(ctx context.Context, id int, name string) error {
+	_, err := db.ExecContext(ctx, `UPDATE workspaces SET name = $2 WHERE id = $1`, id, name)
+	return err
+}
+
+func GetWorkspaceMembers(ctx context.Context, id int) ([]tasks.WorkspaceMember, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT
+			workspace_id, username, role, created_at
+		FROM
+			workspace_members
+		WHERE
+			workspace_id = $1
+		ORDER BY
+			created_at, username`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []tasks.WorkspaceMember{}
+	for rows.Next() {
+		var m tasks.WorkspaceMember
+		if err := rows.Scan(&m.WorkspaceID, &m.Username, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+
+	return result, rows.Err()
+}
+
+// Returns ErrUserNotFound if there is no such user and ErrMemberExists if user is already a member
+func AddWorkspaceMember(ctx context.Context, m *tasks.WorkspaceMember) error {
+	exists, err := userExists(ctx, m.Username)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	m.CreatedAt = time.Now()
+	_, err = db.ExecContext(
+		ctx,
+		`INSERT INTO workspace_members(workspace_id, username, role, created_at) VALUES ($1, $2, $3, $4)`,
+		m.WorkspaceID, m.Username, m.Role, m.CreatedAt,
+	)
+	if isUniqueViolation(err) {
+		return ErrMemberExists
+	}
+	return err
+}
+
+// Returns sql.ErrNoRows if username is not a member of workspace
+func GetWorkspaceMember(ctx context.Context, id int, username string) (*tasks.WorkspaceMember, error) {
+	var m tasks.WorkspaceMember
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT
+			workspace_id, username, role, created_at
+		FROM
+			workspace_members
+		WHERE
+			workspace_id = $1 AND username = $2`,
+		id, username,
+	).Scan(&m.WorkspaceID, &m.Username, &m.Role, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func UpdateWorkspaceMember(ctx context.Context, m *tasks.WorkspaceMember) error {
+	_, err := db.ExecContext(
+		ctx,
+		`UPDATE workspace_members SET role = $3 WHERE workspace_id = $1 AND username = $2`,
+		m.WorkspaceID, m.Username, m.Role,
+	)
+	return err
+}
+
+// Tasks and topics of removed member stay in workspace, tasks of others assigned to them get unassigned
+func RemoveWorkspaceMember(ctx context.Context, id int, username string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM workspace_members WHERE workspace_id = $1 AND username = $2`,
+		id, username,
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	for _, kind := range tasks.Kinds {
+		spec := kindSpecs[kind]
+		nodes, err := queryNodesTx(
+			ctx, tx, kind,
+			`UPDATE `+spec.table+` SET assignee = NULL WHERE workspace_id = $1 AND assignee = $2 AND owner <> $2 RETURNING `+spec.columns,
+			id, username,
+		)
+		if err != nil {
+			return err
+		}
+		if err := recordHistoryTx(ctx, tx, tasks.EventTaskUpdated, nodes...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
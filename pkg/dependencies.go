@@ -0,0 +1,238 @@
+package tasks
+
+import (
+	"container/heap"
+	"slices"
+	"time"
+)
+
+// Blocked task cannot be completed until blocker is done
+type Dependency struct {
+	Owner     string    `json:"owner"`
+	Blocker   TaskRef   `json:"blocker"`
+	Blocked   TaskRef   `json:"blocked"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PlanItem struct {
+	TaskNode
+	// Open tasks blocking this one
+	BlockedBy []TaskRef `json:"blocked_by,omitempty"`
+	// Earliest due date of the task and every task depending on it
+	EffectiveDeadline *time.Time `json:"effective_deadline,omitempty"`
+}
+
+// Returns deadline of tasks with deadline and start of events.
+// Base and repeating tasks are not due at any particular time
+func DueDate(n TaskNode) (time.Time, bool) {
+	switch t := n.Task.(type) {
+	case *TaskWithDeadline:
+		return t.Deadline, true
+	case *Event:
+		return t.StartsAt, true
+	}
+	return time.Time{}, false
+}
+
+// Dependencies between open tasks. Done tasks block nothing and are left out
+type depGraph struct {
+	nodes []TaskNode
+	// Indices of blocked tasks by blocker
+	out [][]int
+	// Indices of blockers by blocked task
+	in [][]int
+}
+
+func newDepGraph(nodes []TaskNode, deps []Dependency) *depGraph {
+	g := &depGraph{}
+	index := map[TaskRef]int{}
+	for _, n := range nodes {
+		if Base(n.Task).Done {
+			continue
+		}
+		index[n.Ref()] = len(g.nodes)
+		g.nodes = append(g.nodes, n)
+	}
+
+	g.out = make([][]int, len(g.nodes))
+	g.in = make([][]int, len(g.nodes))
+	for _, d := range deps {
+		from, ok1 := index[d.Blocker]
+		to, ok2 := index[d.Blocked]
+		if !ok1 || !ok2 {
+			continue
+		}
+		g.out[from] = append(g.out[from], to)
+		g.in[to] = append(g.in[to], from)
+	}
+
+	return g
+}
+
+// Min-heap of node indices ordered by effective deadline, then kind and id
+type readyQueue struct {
+	g   *depGraph
+	eff []*time.Time
+	idx []int
+}
+
+func (q *readyQueue) Len() int { return len(q.idx) }
+
+func (q *readyQueue) Less(i, j int) bool {
+	a, b := q.idx[i], q.idx[j]
+	ea, eb := q.eff[a], q.eff[b]
+	if ea != nil && eb != nil && !ea.Equal(*eb) {
+		return ea.Before(*eb)
+	}
+	if (ea == nil) != (eb == nil) {
+		return ea != nil
+	}
+
+	ra, rb := q.g.nodes[a].Ref(), q.g.nodes[b].Ref()
+	if ra.Kind != rb.Kind {
+		return slices.Index(Kinds, ra.Kind) < slices.Index(Kinds, rb.Kind)
+	}
+	return ra.ID < rb.ID
+}
+
+func (q *readyQueue) Swap(i, j int) { q.idx[i], q.idx[j] = q.idx[j], q.idx[i] }
+
+func (q *readyQueue) Push(x any) { q.idx = append(q.idx, x.(int)) }
+
+func (q *readyQueue) Pop() any {
+	x := q.idx[len(q.idx)-1]
+	q.idx = q.idx[:len(q.idx)-1]
+	return x
+}
+
+// Returns indices of nodes in topological order preferring more urgent ones
+// along with effective deadline of every node
+func (g *depGraph) order() ([]int, []*time.Time) {
+	n := len(g.nodes)
+
+	// Effective deadlines are computed in reverse order of plain topological sort
+	plain := g.topological()
+	eff := make([]*time.Time, n)
+	for i := len(plain) - 1; i >= 0; i-- {
+		v := plain[i]
+		if due, ok := DueDate(g.nodes[v]); ok {
+			eff[v] = &due
+		}
+		for _, w := range g.out[v] {
+			if eff[w] != nil && (eff[v] == nil || eff[w].Before(*eff[v])) {
+				eff[v] = eff[w]
+			}
+		}
+	}
+
+	q := &readyQueue{g: g, eff: eff}
+	indegree := make([]int, n)
+	for v := range n {
+		indegree[v] = len(g.in[v])
+		if indegree[v] == 0 {
+			q.idx = append(q.idx, v)
+		}
+	}
+	heap.Init(q)
+
+	result := make([]int, 0, n)
+	for q.Len() > 0 {
+		v := heap.Pop(q).(int)
+		result = append(result, v)
+		for _, w := range g.out[v] {
+			if indegree[w]--; indegree[w] == 0 {
+				heap.Push(q, w)
+			}
+		}
+	}
+
+	return result, eff
+}
+
+// Plain topological sort. Nodes on cycles are left out
+func (g *depGraph) topological() []int {
+	indegree := make([]int, len(g.nodes))
+	var result []int
+	for v := range g.nodes {
+		indegree[v] = len(g.in[v])
+		if indegree[v] == 0 {
+			result = append(result, v)
+		}
+	}
+
+	for i := 0; i < len(result); i++ {
+		for _, w := range g.out[result[i]] {
+			if indegree[w]--; indegree[w] == 0 {
+				result = append(result, w)
+			}
+		}
+	}
+	return result
+}
+
+func (g *depGraph) item(v int, eff []*time.Time) PlanItem {
+	it := PlanItem{TaskNode: g.nodes[v], EffectiveDeadline: eff[v]}
+	for _, u := range g.in[v] {
+		it.BlockedBy = append(it.BlockedBy, g.nodes[u].Ref())
+	}
+	return it
+}
+
+// Orders open tasks so that every task comes after its blockers.
+// Among tasks that can go next, the one with earliest effective deadline is chosen
+func Plan(nodes []TaskNode, deps []Dependency) []PlanItem {
+	g := newDepGraph(nodes, deps)
+	order, eff := g.order()
+
+	result := make([]PlanItem, 0, len(order))
+	for _, v := range order {
+		result = append(result, g.item(v, eff))
+	}
+	return result
+}
+
+// Returns open tasks without open blockers, most urgent first
+func Actionable(nodes []TaskNode, deps []Dependency) []PlanItem {
+	result := []PlanItem{}
+	for _, it := range Plan(nodes, deps) {
+		if len(it.BlockedBy) == 0 {
+			result = append(result, it)
+		}
+	}
+	return result
+}
+
+// Returns the longest chain of open dependent tasks ending at a task which is due at some time.
+// Chains of equal length are compared by due date of their last task, earlier wins
+func CriticalPath(nodes []TaskNode, deps []Dependency) []PlanItem {
+	g := newDepGraph(nodes, deps)
+	order, eff := g.order()
+
+	length := make([]int, len(g.nodes))
+	prev := make([]int, len(g.nodes))
+	best, bestDue := -1, time.Time{}
+
+	for _, v := range order {
+		length[v], prev[v] = 1, -1
+		for _, u := range g.in[v] {
+			if length[u]+1 > length[v] {
+				length[v], prev[v] = length[u]+1, u
+			}
+		}
+
+		due, ok := DueDate(g.nodes[v])
+		if !ok {
+			continue
+		}
+		if best == -1 || length[v] > length[best] || length[v] == length[best] && due.Before(bestDue) {
+			best, bestDue = v, due
+		}
+	}
+
+	result := []PlanItem{}
+	for v := best; v != -1; v = prev[v] {
+		result = append(result, g.item(v, eff))
+	}
+	slices.Reverse(result)
+	return result
+}
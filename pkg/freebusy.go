@@ -0,0 +1,127 @@
+package tasks
+
+import (
+	"slices"
+	"time"
+)
+
+type Interval struct {
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+type FreeBusy struct {
+	Username string     `json:"username"`
+	Busy     []Interval `json:"busy"`
+	Free     []Interval `json:"free"`
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// Rounds t down to the closest from + k*granularity
+func floorTo(t, from time.Time, granularity time.Duration) time.Time {
+	return from.Add(t.Sub(from) / granularity * granularity)
+}
+
+func ceilTo(t, from time.Time, granularity time.Duration) time.Time {
+	floor := floorTo(t, from, granularity)
+	if floor.Before(t) {
+		return floor.Add(granularity)
+	}
+	return floor
+}
+
+// Returns merged intervals occupied by slots inside [from, to).
+// Bounds of intervals are widened to multiples of granularity counting from `from`
+func BusyIntervals(slots []Slot, from, to time.Time, granularity time.Duration) []Interval {
+	result := []Interval{}
+
+	for _, s := range slots {
+		start, end := s.StartsAt, s.EndsAt
+		if !start.Before(end) {
+			continue
+		}
+
+		start = floorTo(latest(start, from), from, granularity)
+		end = earliest(ceilTo(end, from, granularity), to)
+		if !start.Before(end) {
+			continue
+		}
+
+		result = append(result, Interval{start, end})
+	}
+
+	slices.SortFunc(result, func(a, b Interval) int {
+		return a.StartsAt.Compare(b.StartsAt)
+	})
+
+	merged := result[:0]
+	for _, in := range result {
+		if n := len(merged); n > 0 && !in.StartsAt.After(merged[n-1].EndsAt) {
+			merged[n-1].EndsAt = latest(merged[n-1].EndsAt, in.EndsAt)
+			continue
+		}
+		merged = append(merged, in)
+	}
+
+	return merged
+}
+
+// Returns parts of [from, to) not covered by sorted merged busy intervals
+func FreeIntervals(busy []Interval, from, to time.Time) []Interval {
+	result := []Interval{}
+	cur := from
+
+	for _, b := range busy {
+		if cur.Before(b.StartsAt) {
+			result = append(result, Interval{cur, b.StartsAt})
+		}
+		cur = latest(cur, b.EndsAt)
+	}
+
+	if cur.Before(to) {
+		result = append(result, Interval{cur, to})
+	}
+
+	return result
+}
+
+// Returns intersection of two sorted lists of disjoint intervals
+func IntersectIntervals(a, b []Interval) []Interval {
+	result := []Interval{}
+
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		start := latest(a[i].StartsAt, b[j].StartsAt)
+		end := earliest(a[i].EndsAt, b[j].EndsAt)
+		if start.Before(end) {
+			result = append(result, Interval{start, end})
+		}
+
+		if a[i].EndsAt.Before(b[j].EndsAt) {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	return result
+}
+
+// Drops intervals shorter than d
+func DropShorter(intervals []Interval, d time.Duration) []Interval {
+	return slices.DeleteFunc(intervals, func(in Interval) bool {
+		return in.EndsAt.Sub(in.StartsAt) < d
+	})
+}
@@ -0,0 +1,88 @@
+package tasks
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// Value of field before and after change, nil if field was unset or unknown
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// Version of task recorded on its every change
+type HistoryEntry struct {
+	ID          int64  `json:"id"`
+	TaskType    string `json:"tasktype"`
+	TaskID      int    `json:"task_id"`
+	WorkspaceID int    `json:"workspace_id"`
+	// User who made the change
+	Actor *string `json:"actor,omitempty"`
+	// Task event which made the change
+	Action string `json:"action"`
+	// Fields changed since the previous version. Fields of the first recorded version are all new
+	Changes map[string]FieldChange `json:"changes"`
+	// Task as JSON after the change, deleted tasks as they were before deletion
+	Snapshot map[string]any `json:"snapshot"`
+	At       time.Time      `json:"at"`
+}
+
+// Fields of snapshot which are maintained by server and are not changes of task
+var historyIgnoredFields = map[string]bool{
+	"id": true, "owner": true, "created_at": true, "status_changed_at": true, "comment_count": true, "checklist": true,
+	"deleted_at": true,
+}
+
+// Makes entry of task with snapshot of it, changes are left for the time it is appended
+func NewHistoryEntry(action, taskType string, task any) (HistoryEntry, error) {
+	base := Base(task)
+	entry := HistoryEntry{
+		TaskType:    taskType,
+		TaskID:      base.ID,
+		WorkspaceID: base.WorkspaceID,
+		Action:      action,
+		At:          time.Now(),
+	}
+
+	raw, err := json.Marshal(task)
+	if err != nil {
+		return entry, err
+	}
+	return entry, json.Unmarshal(raw, &entry.Snapshot)
+}
+
+// Returns fields which differ between snapshots. Times are compared as instants
+func DiffSnapshots(before, after map[string]any) map[string]FieldChange {
+	result := map[string]FieldChange{}
+	check := func(field string) {
+		if historyIgnoredFields[field] || snapshotValuesEqual(before[field], after[field]) {
+			return
+		}
+		result[field] = FieldChange{Before: before[field], After: after[field]}
+	}
+
+	for field := range before {
+		check(field)
+	}
+	for field := range after {
+		if _, ok := before[field]; !ok {
+			check(field)
+		}
+	}
+	return result
+}
+
+func snapshotValuesEqual(a, b any) bool {
+	if sa, ok := a.(string); ok {
+		if sb, ok := b.(string); ok {
+			ta, errA := time.Parse(time.RFC3339Nano, sa)
+			tb, errB := time.Parse(time.RFC3339Nano, sb)
+			if errA == nil && errB == nil {
+				return ta.Equal(tb)
+			}
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
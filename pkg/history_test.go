@@ -0,0 +1,81 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	tests := []struct {
+		name   string
+		before map[string]any
+		after  map[string]any
+		want   map[string]FieldChange
+	}{
+		{
+			name:   "first version",
+			before: nil,
+			after:  map[string]any{"id": 1.0, "title": "Buy milk", "done": false},
+			want: map[string]FieldChange{
+				"title": {Before: nil, After: "Buy milk"},
+				"done":  {Before: nil, After: false},
+			},
+		},
+		{
+			name:   "nothing changed",
+			before: map[string]any{"title": "Buy milk", "tags": []any{"home"}},
+			after:  map[string]any{"title": "Buy milk", "tags": []any{"home"}},
+			want:   map[string]FieldChange{},
+		},
+		{
+			name:   "changed field",
+			before: map[string]any{"title": "Buy milk", "priority": 1.0},
+			after:  map[string]any{"title": "Buy bread", "priority": 1.0},
+			want:   map[string]FieldChange{"title": {Before: "Buy milk", After: "Buy bread"}},
+		},
+		{
+			name:   "removed and added fields",
+			before: map[string]any{"assignee": "bob"},
+			after:  map[string]any{"parent_id": 3.0},
+			want: map[string]FieldChange{
+				"assignee":  {Before: "bob", After: nil},
+				"parent_id": {Before: nil, After: 3.0},
+			},
+		},
+		{
+			name:   "changed tags",
+			before: map[string]any{"tags": []any{"home"}},
+			after:  map[string]any{"tags": []any{"home", "work"}},
+			want:   map[string]FieldChange{"tags": {Before: []any{"home"}, After: []any{"home", "work"}}},
+		},
+		{
+			name:   "same instant in other zone",
+			before: map[string]any{"deadline": "2024-05-01T12:00:00Z"},
+			after:  map[string]any{"deadline": "2024-05-01T15:00:00+03:00"},
+			want:   map[string]FieldChange{},
+		},
+		{
+			name:   "moved time",
+			before: map[string]any{"deadline": "2024-05-01T12:00:00Z"},
+			after:  map[string]any{"deadline": "2024-05-02T12:00:00Z"},
+			want: map[string]FieldChange{
+				"deadline": {Before: "2024-05-01T12:00:00Z", After: "2024-05-02T12:00:00Z"},
+			},
+		},
+		{
+			name:   "ignored fields",
+			before: map[string]any{"id": 1.0, "comment_count": 0.0, "status_changed_at": "2024-05-01T12:00:00Z"},
+			after:  map[string]any{"id": 1.0, "comment_count": 2.0, "status_changed_at": "2024-05-02T12:00:00Z", "deleted_at": "2024-05-02T12:00:00Z"},
+			want:   map[string]FieldChange{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffSnapshots(tt.before, tt.after)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffSnapshots() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
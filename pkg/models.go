@@ -15,11 +15,34 @@ type BaseTask struct {
 	ID          int    `json:"id"`
 	Title       string `json:"title"`
 	Description string `json:"description"`
-	Done        bool   `json:"done"`
-	Owner       string `json:"owner"`
-	Topic       string `json:"topic"`
+	// Derived from Status: whether it is a done state of task workflow
+	Done    bool   `json:"done"`
+	Owner   string `json:"owner"`
+	Topic   string `json:"topic"`
+	TopicID int    `json:"topic_id"`
+	// Workspace owning the task, always the one of its topic
+	WorkspaceID int `json:"workspace_id"`
+	// User working on the task, nil if unassigned
+	Assignee     *string           `json:"assignee,omitempty"`
+	CommentCount int               `json:"comment_count"`
+	Checklist    ChecklistProgress `json:"checklist"`
+	// Both are either set or nil
+	ParentType *string `json:"parent_tasktype,omitempty"`
+	ParentID   *int    `json:"parent_id,omitempty"`
+	// Names of tags sorted alphabetically
+	Tags []string `json:"tags,omitempty"`
+	// From 0 (none) to MaxPriority
+	Priority  int       `json:"priority"`
+	CreatedAt time.Time `json:"created_at"`
+	// State of task workflow
+	Status          string    `json:"status"`
+	StatusChangedAt time.Time `json:"status_changed_at"`
+	// Time task was moved to trash, nil for tasks not in it
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
+const MaxPriority = 4
+
 type Event struct {
 	BaseTask
 	StartsAt time.Time `json:"starts_at"`
@@ -31,10 +54,272 @@ type TaskWithDeadline struct {
 	Deadline time.Time `json:"deadline"`
 }
 
-// For every `Loop` tasks those at places in Except are considered turned off
+// For every `Loop` tasks those at places in Except are considered turned off.
+// Task with positive Months repeats every that many calendar months and its Period is ignored
 type RepeatingTask struct {
 	Event
 	Period int64   `json:"period"`
+	Months int64   `json:"months"`
 	Loop   int64   `json:"loop"`
 	Except []int64 `json:"except"`
 }
+
+// Values of `tasktype` form field, also used to reference tasks of any kind
+const (
+	KindBaseTask         = "basetask"
+	KindEvent            = "event"
+	KindTaskWithDeadline = "deadline"
+	KindRepeatingTask    = "repeat"
+)
+
+var Kinds = []string{KindBaseTask, KindEvent, KindTaskWithDeadline, KindRepeatingTask}
+
+// Name of topic tasks are put into when none is given
+const DefaultTopic = "default"
+
+// Separates names in path of topic from root topic like "work/auth", so topic names can't contain it
+const TopicPathSeparator = "/"
+
+type Topic struct {
+	ID          int    `json:"id"`
+	Owner       string `json:"owner"`
+	WorkspaceID int    `json:"workspace_id"`
+	Name        string `json:"name"`
+	// Nil for top-level topics
+	ParentID *int `json:"parent_id"`
+	// Hex color like #1e90ff, empty if not set
+	Color     string    `json:"color"`
+	Icon      string    `json:"icon"`
+	SortOrder int       `json:"sort_order"`
+	Archived  bool      `json:"archived"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TopicCounts struct {
+	Open int `json:"open"`
+	Done int `json:"done"`
+	// Not done tasks after their deadline or end
+	Overdue int `json:"overdue"`
+}
+
+type TopicStats struct {
+	Topic
+	// Tasks of the topic itself
+	TopicCounts
+	// Tasks of the topic and all its descendants
+	Subtree  TopicCounts  `json:"subtree"`
+	Children []TopicStats `json:"children,omitempty"`
+}
+
+type Tag struct {
+	ID        int       `json:"id"`
+	Owner     string    `json:"owner"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	// Amount of tasks with the tag
+	Usage int `json:"usage"`
+}
+
+// Reference to task of any kind
+type TaskRef struct {
+	Kind string `json:"tasktype"`
+	ID   int    `json:"id"`
+}
+
+// Returns nil if task has no parent
+func (t BaseTask) Parent() *TaskRef {
+	if t.ParentType == nil || t.ParentID == nil {
+		return nil
+	}
+	return &TaskRef{*t.ParentType, *t.ParentID}
+}
+
+func (t *BaseTask) SetParent(parent *TaskRef) {
+	if parent == nil {
+		t.ParentType, t.ParentID = nil, nil
+		return
+	}
+	t.ParentType, t.ParentID = &parent.Kind, &parent.ID
+}
+
+// Task of any kind in hierarchy
+type TaskNode struct {
+	TaskType string `json:"tasktype"`
+	// One of *BaseTask, *Event, *TaskWithDeadline and *RepeatingTask
+	Task any `json:"task"`
+	// Percent of done descendants, absent for tasks without children
+	Progress *float64   `json:"progress,omitempty"`
+	Children []TaskNode `json:"children,omitempty"`
+}
+
+// Policies applied to children when parent is deleted or completed
+const (
+	ChildrenCascade = "cascade"
+	ChildrenOrphan  = "orphan"
+	ChildrenBlock   = "block"
+)
+
+type SearchResult struct {
+	TaskType string  `json:"tasktype"`
+	ID       int     `json:"id"`
+	Title    string  `json:"title"`
+	Topic    string  `json:"topic"`
+	Done     bool    `json:"done"`
+	Rank     float64 `json:"rank"`
+	// Matched fragments of title and description with matches wrapped in <b></b>
+	Snippet string `json:"snippet"`
+}
+
+// Reminder statuses
+const (
+	ReminderPending   = "pending"
+	ReminderSending   = "sending"
+	ReminderFired     = "fired"
+	ReminderDismissed = "dismissed"
+	ReminderFailed    = "failed"
+)
+
+// Reminder delivery channels
+const (
+	ChannelLog     = "log"
+	ChannelWebhook = "webhook"
+	ChannelEmail   = "email"
+)
+
+type Reminder struct {
+	ID       int    `json:"id"`
+	Owner    string `json:"owner"`
+	TaskType string `json:"tasktype"`
+	TaskID   int    `json:"task_id"`
+	// Occurrence of repeating task the reminder is about
+	Occurrence *int64    `json:"occurrence,omitempty"`
+	RemindAt   time.Time `json:"remind_at"`
+	// Seconds before deadline or start, absent for reminders at absolute time
+	Offset *int64 `json:"offset,omitempty"`
+	// Reminder of repeating task moves to the next occurrence after firing
+	Recurring bool   `json:"recurring"`
+	Channel   string `json:"channel"`
+	// Webhook url or email address
+	Target  string     `json:"target"`
+	Status  string     `json:"status"`
+	FiredAt *time.Time `json:"fired_at,omitempty"`
+	// Failed attempts to send the reminder, reset once it is sent
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	Error         *string    `json:"error,omitempty"`
+}
+
+// Task lifecycle events
+const (
+	EventTaskCreated   = "task.created"
+	EventTaskUpdated   = "task.updated"
+	EventTaskCompleted = "task.completed"
+	EventTaskDeleted   = "task.deleted"
+	EventTaskRestored  = "task.restored"
+	EventTaskPurged    = "task.purged"
+	// Sent to task owner
+	EventCommentCreated = "comment.created"
+	// Sent to users mentioned in comment
+	EventCommentMentioned = "comment.mentioned"
+	// Sent only on request to check webhook
+	EventWebhookTest = "webhook.test"
+)
+
+var TaskEvents = []string{
+	EventTaskCreated, EventTaskUpdated, EventTaskCompleted, EventTaskDeleted, EventTaskRestored, EventTaskPurged,
+	EventCommentCreated, EventCommentMentioned,
+}
+
+type TaskEvent struct {
+	Event      string    `json:"event"`
+	TaskType   string    `json:"tasktype"`
+	Task       any       `json:"task"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+type CommentEvent struct {
+	Event      string    `json:"event"`
+	Comment    Comment   `json:"comment"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+type Webhook struct {
+	ID    int    `json:"id"`
+	Owner string `json:"owner"`
+	URL   string `json:"url"`
+	// Key for HMAC-SHA256 signature of payloads, shown only on creation
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+type WebhookDelivery struct {
+	ID            int        `json:"id"`
+	WebhookID     int        `json:"webhook_id"`
+	Event         string     `json:"event"`
+	Payload       string     `json:"payload"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	ResponseCode  *int       `json:"response_code,omitempty"`
+	Error         *string    `json:"error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+}
+
+// File attached to task, its content is kept in blob store
+type Attachment struct {
+	ID       int    `json:"id"`
+	TaskType string `json:"tasktype"`
+	TaskID   int    `json:"task_id"`
+	// User who uploaded the file
+	Owner     string    `json:"owner"`
+	BlobKey   string    `json:"-"`
+	Name      string    `json:"name"`
+	MIME      string    `json:"mime"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Total size of attachments uploaded by user, in bytes
+type AttachmentUsage struct {
+	Used  int64 `json:"used"`
+	Quota int64 `json:"quota"`
+}
+
+const MaxChecklistItemTitle = 256
+
+// Step of task checklist
+type ChecklistItem struct {
+	ID       int    `json:"id"`
+	TaskType string `json:"tasktype"`
+	TaskID   int    `json:"task_id"`
+	Title    string `json:"title"`
+	Checked  bool   `json:"checked"`
+	// From 0, items are listed by it
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	// Both are set for checked items only
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	CheckedBy *string    `json:"checked_by,omitempty"`
+}
+
+type ChecklistProgress struct {
+	Total int `json:"total"`
+	Done  int `json:"done"`
+	// Task is completed once all items get checked
+	AutoComplete bool `json:"auto_complete"`
+}
+
+// Reports whether task with the checklist should be completed automatically
+func (p ChecklistProgress) Completes() bool {
+	return p.AutoComplete && p.Total > 0 && p.Done == p.Total
+}
@@ -0,0 +1,115 @@
+package tasks
+
+import (
+	"slices"
+	"time"
+)
+
+// Weights of score components of "next up" ordering
+type NextWeights struct {
+	Priority float64 `json:"priority" yaml:"priority"`
+	Deadline float64 `json:"deadline" yaml:"deadline"`
+	Event    float64 `json:"event" yaml:"event"`
+	Age      float64 `json:"age" yaml:"age"`
+	Blocked  float64 `json:"blocked" yaml:"blocked"`
+}
+
+// Per user settings, absent fields fall back to config
+type UserSettings struct {
+	NextWeights *NextWeights `json:"next_weights,omitempty"`
+}
+
+// Age after which age component stops growing
+const maxAge = 30 * 24 * time.Hour
+
+type NextItem struct {
+	TaskNode
+	Score   float64 `json:"score"`
+	Blocked bool    `json:"blocked"`
+	// Unweighted components of score, every one is in [0, 1]
+	Components map[string]float64 `json:"components"`
+}
+
+// Returns 1 for moments in the past and decreases as at gets further from now
+func proximity(at, now time.Time) float64 {
+	days := at.Sub(now).Hours() / 24
+	if days <= 0 {
+		return 1
+	}
+	return 1 / (1 + days)
+}
+
+// Refs of open tasks with open blockers
+func BlockedRefs(nodes []TaskNode, deps []Dependency) map[TaskRef]bool {
+	g := newDepGraph(nodes, deps)
+	result := map[TaskRef]bool{}
+	for v, blockers := range g.in {
+		if len(blockers) > 0 {
+			result[g.nodes[v].Ref()] = true
+		}
+	}
+	return result
+}
+
+// Ranks open tasks by weighted sum of priority, deadline proximity, proximity of
+// upcoming event or occurrence, and age. Blocked tasks are penalized
+func NextUp(nodes []TaskNode, deps []Dependency, w NextWeights, now time.Time) []NextItem {
+	blocked := BlockedRefs(nodes, deps)
+	result := []NextItem{}
+
+	for _, n := range nodes {
+		base := Base(n.Task)
+		if base.Done {
+			continue
+		}
+
+		c := map[string]float64{
+			"priority": float64(base.Priority) / MaxPriority,
+			"deadline": 0,
+			"event":    0,
+			"age":      0,
+			"blocked":  0,
+		}
+
+		switch t := n.Task.(type) {
+		case *TaskWithDeadline:
+			c["deadline"] = proximity(t.Deadline, now)
+		case *Event:
+			if t.EndsAt.After(now) {
+				c["event"] = proximity(t.StartsAt, now)
+			}
+		case *RepeatingTask:
+			if o, ok := t.OccurrenceAfter(now); ok {
+				c["event"] = proximity(o.StartsAt, now)
+			}
+		}
+
+		if !base.CreatedAt.IsZero() {
+			c["age"] = min(float64(now.Sub(base.CreatedAt))/float64(maxAge), 1)
+		}
+
+		if blocked[n.Ref()] {
+			c["blocked"] = 1
+		}
+
+		result = append(result, NextItem{
+			TaskNode: n,
+			Score: w.Priority*c["priority"] + w.Deadline*c["deadline"] + w.Event*c["event"] +
+				w.Age*c["age"] - w.Blocked*c["blocked"],
+			Blocked:    c["blocked"] == 1,
+			Components: c,
+		})
+	}
+
+	slices.SortStableFunc(result, func(a, b NextItem) int {
+		if a.Score > b.Score {
+			return -1
+		}
+		if a.Score < b.Score {
+			return 1
+		}
+		return 0
+	})
+
+	return result
+}
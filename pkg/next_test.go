@@ -0,0 +1,109 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNextUpComponents(t *testing.T) {
+	now := time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name string
+		node TaskNode
+		want map[string]float64
+	}{
+		{
+			name: "priority and age",
+			node: TaskNode{TaskType: KindBaseTask, Task: &BaseTask{ID: 1, Priority: 2, CreatedAt: now.Add(-15 * day)}},
+			want: map[string]float64{"priority": 0.5, "deadline": 0, "event": 0, "age": 0.5, "blocked": 0},
+		},
+		{
+			name: "age is capped",
+			node: TaskNode{TaskType: KindBaseTask, Task: &BaseTask{ID: 1, CreatedAt: now.Add(-60 * day)}},
+			want: map[string]float64{"priority": 0, "deadline": 0, "event": 0, "age": 1, "blocked": 0},
+		},
+		{
+			name: "deadline tomorrow",
+			node: TaskNode{TaskType: KindTaskWithDeadline, Task: &TaskWithDeadline{BaseTask: BaseTask{ID: 1}, Deadline: now.Add(day)}},
+			want: map[string]float64{"priority": 0, "deadline": 0.5, "event": 0, "age": 0, "blocked": 0},
+		},
+		{
+			name: "overdue",
+			node: TaskNode{TaskType: KindTaskWithDeadline, Task: &TaskWithDeadline{BaseTask: BaseTask{ID: 1, Priority: 4}, Deadline: now.Add(-day)}},
+			want: map[string]float64{"priority": 1, "deadline": 1, "event": 0, "age": 0, "blocked": 0},
+		},
+		{
+			name: "upcoming event",
+			node: TaskNode{TaskType: KindEvent, Task: &Event{BaseTask: BaseTask{ID: 1}, StartsAt: now.Add(3 * day), EndsAt: now.Add(3*day + time.Hour)}},
+			want: map[string]float64{"priority": 0, "deadline": 0, "event": 0.25, "age": 0, "blocked": 0},
+		},
+		{
+			name: "event in progress",
+			node: TaskNode{TaskType: KindEvent, Task: &Event{BaseTask: BaseTask{ID: 1}, StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour)}},
+			want: map[string]float64{"priority": 0, "deadline": 0, "event": 1, "age": 0, "blocked": 0},
+		},
+		{
+			name: "past event",
+			node: TaskNode{TaskType: KindEvent, Task: &Event{BaseTask: BaseTask{ID: 1}, StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour)}},
+			want: map[string]float64{"priority": 0, "deadline": 0, "event": 0, "age": 0, "blocked": 0},
+		},
+		{
+			name: "next occurrence",
+			node: TaskNode{TaskType: KindRepeatingTask, Task: &RepeatingTask{
+				Event:  Event{BaseTask: BaseTask{ID: 1}, StartsAt: now.Add(-5*day - 12*time.Hour), EndsAt: now.Add(-5 * day)},
+				Period: 24 * 60 * 60,
+				Loop:   1,
+			}},
+			want: map[string]float64{"priority": 0, "deadline": 0, "event": 1 / 1.5, "age": 0, "blocked": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NextUp([]TaskNode{tt.node}, nil, NextWeights{}, now)
+			if len(got) != 1 {
+				t.Fatalf("NextUp() returned %d items, want 1", len(got))
+			}
+			if !reflect.DeepEqual(got[0].Components, tt.want) {
+				t.Errorf("components = %v, want %v", got[0].Components, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextUpOrder(t *testing.T) {
+	now := time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC)
+	w := NextWeights{Priority: 1, Deadline: 2, Event: 1, Age: 0.5, Blocked: 10}
+
+	nodes := []TaskNode{
+		{TaskType: KindBaseTask, Task: &BaseTask{ID: 1, Priority: 1}},
+		{TaskType: KindTaskWithDeadline, Task: &TaskWithDeadline{BaseTask: BaseTask{ID: 2, Priority: 1}, Deadline: now.Add(-time.Hour)}},
+		{TaskType: KindBaseTask, Task: &BaseTask{ID: 3, Priority: 4}},
+		{TaskType: KindBaseTask, Task: &BaseTask{ID: 4, Priority: 4, Done: true}},
+		{TaskType: KindBaseTask, Task: &BaseTask{ID: 5, Priority: 4}},
+		{TaskType: KindBaseTask, Task: &BaseTask{ID: 6, Priority: 2}},
+		{TaskType: KindBaseTask, Task: &BaseTask{ID: 7}},
+	}
+	deps := []Dependency{
+		// Open blocker penalizes task
+		{Blocker: TaskRef{KindBaseTask, 1}, Blocked: TaskRef{KindBaseTask, 5}},
+		// Done one doesn't
+		{Blocker: TaskRef{KindBaseTask, 4}, Blocked: TaskRef{KindBaseTask, 6}},
+	}
+
+	var got []TaskRef
+	for _, item := range NextUp(nodes, deps, w, now) {
+		got = append(got, item.Ref())
+		if item.Blocked != (item.Ref() == TaskRef{KindBaseTask, 5}) {
+			t.Errorf("task %v blocked = %v", item.Ref(), item.Blocked)
+		}
+	}
+
+	want := []TaskRef{{KindTaskWithDeadline, 2}, {KindBaseTask, 3}, {KindBaseTask, 6}, {KindBaseTask, 1}, {KindBaseTask, 7}, {KindBaseTask, 5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NextUp() order = %v, want %v", got, want)
+	}
+}
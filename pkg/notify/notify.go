@@ -0,0 +1,184 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+var ErrUnknownChannel = errors.New("Unknown notification channel")
+
+type Notification struct {
+	Channel string
+	// Webhook url or email address
+	Target  string
+	Subject string
+	Body    string
+	// Sent as is by webhook notifier
+	Data any
+}
+
+type Notifier interface {
+	Notify(context.Context, Notification) error
+}
+
+// Passes notifications to notifier of their channel
+type Router map[string]Notifier
+
+func (r Router) Notify(ctx context.Context, n Notification) error {
+	notifier, ok := r[n.Channel]
+	if !ok {
+		return ErrUnknownChannel
+	}
+	return notifier.Notify(ctx, n)
+}
+
+type logNotifier struct{}
+
+func (l logNotifier) Notify(ctx context.Context, n Notification) error {
+	log.Printf("Notification: %s: %s", n.Subject, n.Body)
+	return nil
+}
+
+func NewLogNotifier() Notifier {
+	return logNotifier{}
+}
+
+type webhookNotifier struct {
+	client *http.Client
+}
+
+func (wh webhookNotifier) Notify(ctx context.Context, n Notification) error {
+	payload, err := json.Marshal(map[string]any{
+		"subject": n.Subject,
+		"body":    n.Body,
+		"data":    n.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.Target, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func NewWebhookNotifier(timeout time.Duration) Notifier {
+	return webhookNotifier{NewPublicClient(timeout)}
+}
+
+type smtpNotifier struct {
+	host string
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func (s smtpNotifier) Notify(ctx context.Context, n Notification) error {
+	// Line breaks in subject would let it inject headers of its own
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(n.Subject)
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", n.Target)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(n.Body)
+
+	return s.send(ctx, n.Target, []byte(msg.String()))
+}
+
+// Same as smtp.SendMail, but gives up once ctx is done
+func (s smtpNotifier) send(ctx context.Context, to string, msg []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+	if s.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("SMTP server doesn't support AUTH")
+		}
+		if err := c.Auth(s.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(s.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+
+	wc, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := wc.Write(msg); err != nil {
+		return err
+	}
+	if err := wc.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// Authenticates only if username is not empty
+func NewSMTPNotifier(host string, port int, from, username, password string) Notifier {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return smtpNotifier{
+		host: host,
+		addr: fmt.Sprintf("%s:%d", host, port),
+		from: from,
+		auth: auth,
+	}
+}
@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCheckPublicURL(t *testing.T) {
+	tests := []struct {
+		url    string
+		public bool
+	}{
+		{"https://93.184.215.14/hook", true},
+		{"http://[2606:4700::1111]:8080/hook", true},
+		{"http://127.0.0.1/hook", false},
+		{"http://localhost:8080/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://10.0.0.5/hook", false},
+		{"http://172.16.3.4/hook", false},
+		{"http://192.168.1.1/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://100.100.100.200/hook", false},
+		{"http://0.0.0.0/hook", false},
+		{"http://[::ffff:127.0.0.1]/hook", false},
+		{"http://[fd00:ec2::254]/hook", false},
+		{"ftp://93.184.215.14/hook", false},
+		{"http:///hook", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := CheckPublicURL(context.Background(), tt.url)
+			if (err == nil) != tt.public {
+				t.Errorf("CheckPublicURL() = %v, want public %v", err, tt.public)
+			}
+		})
+	}
+}
+
+func TestDialPublic(t *testing.T) {
+	if err := dialPublic("tcp4", "93.184.215.14:443", nil); err != nil {
+		t.Errorf("dialPublic() = %v for public address", err)
+	}
+	if err := dialPublic("tcp4", "169.254.169.254:80", nil); err != ErrNotPublic {
+		t.Errorf("dialPublic() = %v for metadata address, want %v", err, ErrNotPublic)
+	}
+}
@@ -0,0 +1,791 @@
+// Package quickadd parses tasks written as a line of English or Russian text like
+// "Pay rent every month on the 1st #finance !high" or "Позвонить маме завтра в 15:00"
+package quickadd
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+const (
+	// Time of day in minutes given to events and repeating tasks which have date but no time
+	defaultTime = 9 * 60
+	// Time of day in minutes of deadlines which have date but no time
+	endOfDay = 23*60 + 59
+)
+
+// Days searched for the first one matching task, enough to reach any day of month
+const searchDays = 366
+
+// Task parsed from text. Times are set according to TaskType, all of them in location of now given to Parse
+type Result struct {
+	TaskType string     `json:"tasktype"`
+	Title    string     `json:"title"`
+	Topic    string     `json:"topic,omitempty"`
+	Tags     []string   `json:"tags,omitempty"`
+	Priority int        `json:"priority"`
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+	Deadline *time.Time `json:"deadline,omitempty"`
+	Period   int64      `json:"period,omitempty"`
+	Months   int64      `json:"months,omitempty"`
+	Loop     int64      `json:"loop,omitempty"`
+	Except   []int64    `json:"except,omitempty"`
+	// Recurrence of repeating task like "every 2 weeks on monday, friday"
+	Recurrence string `json:"recurrence,omitempty"`
+	// Parts of text which were recognized and are not in title
+	Matched []string `json:"matched"`
+}
+
+type token struct {
+	raw string
+	// Lowercase without trailing punctuation
+	key string
+}
+
+type recurrence struct {
+	unit  string
+	every int
+	// Days of weekly recurrence, every day of week if empty
+	weekdays []time.Weekday
+}
+
+type parser struct {
+	now    time.Time
+	tokens []token
+
+	topic    string
+	tags     []string
+	priority int
+
+	// Midnight of the day and time of day in minutes
+	date     *time.Time
+	start    *int
+	end      *int
+	duration time.Duration
+	// Date and time are what task must be done by
+	deadline bool
+	// Day of month like "1st" without month
+	monthDay int
+	repeat   *recurrence
+}
+
+// Parses text relative to now. Words which are not recognized make title, if one thing is mentioned
+// twice only the first mention counts and the rest is left in title
+func Parse(text string, now time.Time) Result {
+	p := parser{now: now}
+	for _, f := range strings.Fields(text) {
+		p.tokens = append(p.tokens, token{raw: f, key: strings.ToLower(strings.TrimRight(f, ",;.?"))})
+	}
+
+	var title, matched []string
+	for i := 0; i < len(p.tokens); {
+		n := p.match(i, "")
+		if n == 0 {
+			title = append(title, p.tokens[i].raw)
+			i++
+			continue
+		}
+
+		var parts []string
+		for _, t := range p.tokens[i : i+n] {
+			parts = append(parts, t.raw)
+		}
+		matched = append(matched, strings.Join(parts, " "))
+		i += n
+	}
+
+	r := Result{
+		TaskType: tasks.KindBaseTask,
+		Title:    strings.Join(title, " "),
+		Topic:    p.topic,
+		Tags:     p.tags,
+		Priority: p.priority,
+		Matched:  matched,
+	}
+	if r.Matched == nil {
+		r.Matched = []string{}
+	}
+
+	switch {
+	case p.repeat != nil:
+		p.resolveRepeat(&r)
+	case p.deadline || (p.end == nil && p.duration == 0 && (p.date != nil || p.start != nil || p.monthDay != 0)):
+		p.resolveDeadline(&r)
+	case p.end != nil || p.duration > 0:
+		p.resolveEvent(&r)
+	}
+	return r
+}
+
+func (p *parser) key(i int) string {
+	if i < 0 || i >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[i].key
+}
+
+// Tries to recognize something starting at token i, returns number of tokens taken.
+// Preposition is the word before i if the match is attempted on behalf of it
+func (p *parser) match(i int, preposition string) int {
+	if i >= len(p.tokens) {
+		return 0
+	}
+	if preposition == "" {
+		if n := p.matchSigil(i); n > 0 {
+			return n
+		}
+		if n := p.matchDuration(i); n > 0 {
+			return n
+		}
+	}
+
+	for _, m := range []func(int, string) int{
+		p.matchRepeat,
+		p.matchRange,
+		p.matchTime,
+		p.matchRelative,
+		p.matchDate,
+		p.matchOrdinal,
+		p.matchPreposition,
+	} {
+		if n := m(i, preposition); n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// #tag, @topic and priority like !high, !3 or !!!
+func (p *parser) matchSigil(i int) int {
+	raw := strings.TrimRight(p.tokens[i].raw, ",;.")
+	if len(raw) < 2 {
+		return 0
+	}
+	name := raw[1:]
+
+	switch raw[0] {
+	case '#':
+		if !slices.Contains(p.tags, name) {
+			p.tags = append(p.tags, name)
+		}
+	case '@':
+		if p.topic != "" {
+			return 0
+		}
+		p.topic = name
+	case '!':
+		priority, ok := priorities[strings.ToLower(name)]
+		if !ok {
+			n, err := strconv.Atoi(name)
+			priority, ok = n, err == nil
+		}
+		if !ok && strings.Trim(raw, "!") == "" {
+			priority, ok = len(raw), true
+		}
+		if !ok || priority < 0 || priority > tasks.MaxPriority || p.priority != 0 {
+			return 0
+		}
+		p.priority = priority
+	default:
+		return 0
+	}
+	return 1
+}
+
+// Count and unit like "2 hours", "an hour", "час" or "30m"
+func (p *parser) amount(i int) (count int, unit string, n int) {
+	if unit, ok := units[p.key(i)]; ok {
+		return 1, unit, 1
+	}
+	if count, ok := parseNumber(p.key(i)); ok {
+		if unit, ok := units[p.key(i+1)]; ok {
+			return count, unit, 2
+		}
+	}
+
+	key := p.key(i)
+	digits := strings.TrimLeft(key, "0123456789")
+	if digits != key {
+		count, err := strconv.Atoi(strings.TrimSuffix(key, digits))
+		if unit, ok := units[digits]; ok && err == nil && count > 0 {
+			return count, unit, 1
+		}
+	}
+	return 0, "", 0
+}
+
+// Adds count of units to t
+func shift(t time.Time, count int, unit string) time.Time {
+	switch unit {
+	case unitMinute:
+		return t.Add(time.Duration(count) * time.Minute)
+	case unitHour:
+		return t.Add(time.Duration(count) * time.Hour)
+	case unitDay:
+		return t.AddDate(0, 0, count)
+	case unitWeek:
+		return t.AddDate(0, 0, 7*count)
+	case unitMonth:
+		return t.AddDate(0, count, 0)
+	}
+	return t.AddDate(count, 0, 0)
+}
+
+// "for 30 min", "на час"
+func (p *parser) matchDuration(i int) int {
+	if !forWords[p.key(i)] || p.duration != 0 {
+		return 0
+	}
+	count, unit, n := p.amount(i + 1)
+	if n == 0 || unit == unitMonth || unit == unitYear {
+		return 0
+	}
+
+	start := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	p.duration = shift(start, count, unit).Sub(start)
+	return n + 1
+}
+
+// "in 2 days", "через неделю"
+func (p *parser) matchRelative(i int, _ string) int {
+	if !inWords[p.key(i)] || p.date != nil {
+		return 0
+	}
+	count, unit, n := p.amount(i + 1)
+	if n == 0 {
+		return 0
+	}
+
+	at := shift(p.now, count, unit)
+	date := midnight(at)
+	p.date = &date
+	if unit == unitMinute || unit == unitHour {
+		if p.start != nil {
+			return 0
+		}
+		minutes := at.Hour()*60 + at.Minute()
+		p.start = &minutes
+	}
+	return n + 1
+}
+
+// Weekday at i, abbreviations like "fri" or "пт" only if allowed
+func (p *parser) weekday(i int, abbreviations bool) (time.Weekday, bool) {
+	if d, ok := weekdays[p.key(i)]; ok {
+		return d, true
+	}
+	d, ok := weekdayAbbreviations[p.key(i)]
+	return d, ok && abbreviations
+}
+
+// Weekdays like "monday, wed and fri" or "понедельникам и средам"
+func (p *parser) weekdayList(i int) ([]time.Weekday, int) {
+	var days []time.Weekday
+	j := i
+	for {
+		d, ok := p.weekday(j, true)
+		if !ok {
+			d, ok = weekdaysPlural[p.key(j)]
+		}
+		if !ok {
+			break
+		}
+		if !slices.Contains(days, d) {
+			days = append(days, d)
+		}
+		j++
+
+		if andWords[p.key(j)] {
+			if _, ok := p.weekday(j+1, true); ok {
+				j++
+			} else if _, ok := weekdaysPlural[p.key(j+1)]; ok {
+				j++
+			}
+		}
+	}
+	return days, j - i
+}
+
+var (
+	workdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	weekend  = []time.Weekday{time.Saturday, time.Sunday}
+)
+
+// "daily", "every 2 weeks", "every monday and friday", "каждый будний день", "по средам"
+func (p *parser) matchRepeat(i int, _ string) int {
+	if p.repeat != nil {
+		return 0
+	}
+
+	key := p.key(i)
+	if unit, ok := repeatWords[key]; ok {
+		p.repeat = &recurrence{unit: unit, every: 1}
+		return 1
+	}
+
+	switch {
+	case key == "по" && p.key(i+1) == "будням":
+		p.repeat = &recurrence{unit: unitWeek, every: 1, weekdays: workdays}
+		return 2
+	case key == "по" && p.key(i+1) == "выходным":
+		p.repeat = &recurrence{unit: unitWeek, every: 1, weekdays: weekend}
+		return 2
+	case key == "по":
+		if _, ok := weekdaysPlural[p.key(i+1)]; !ok {
+			return 0
+		}
+		days, n := p.weekdayList(i + 1)
+		p.repeat = &recurrence{unit: unitWeek, every: 1, weekdays: days}
+		return n + 1
+	}
+	if _, ok := weekdaysPlural[key]; ok {
+		days, n := p.weekdayList(i)
+		p.repeat = &recurrence{unit: unitWeek, every: 1, weekdays: days}
+		return n
+	}
+	if !everyWords[key] {
+		return 0
+	}
+
+	j, every := i+1, 1
+	if otherWords[p.key(j)] {
+		j, every = j+1, 2
+	}
+
+	if count, unit, n := p.amount(j); n > 0 {
+		if every == 1 {
+			every = count
+		}
+		p.repeat = &recurrence{unit: unit, every: every}
+		return j + n - i
+	}
+
+	switch p.key(j) {
+	case "weekday", "weekdays":
+		p.repeat = &recurrence{unit: unitWeek, every: every, weekdays: workdays}
+		return j + 1 - i
+	case "будний":
+		p.repeat = &recurrence{unit: unitWeek, every: every, weekdays: workdays}
+		if units[p.key(j+1)] == unitDay {
+			j++
+		}
+		return j + 1 - i
+	case "weekend", "weekends", "выходные":
+		p.repeat = &recurrence{unit: unitWeek, every: every, weekdays: weekend}
+		return j + 1 - i
+	}
+
+	days, n := p.weekdayList(j)
+	if n == 0 {
+		return 0
+	}
+	p.repeat = &recurrence{unit: unitWeek, every: every, weekdays: days}
+	return j + n - i
+}
+
+// Time of day at i like 15:00, 3pm, "3 pm" or noon. Bare hours like "at 9" are taken if allowed
+func (p *parser) clock(i int, bare bool) (minutes int, n int, ok bool) {
+	key := p.key(i)
+	if minutes, ok := noonWords[key]; ok {
+		return minutes, 1, true
+	}
+	if minutes, ok := parseClock(key); ok {
+		return minutes, 1, true
+	}
+	if next := p.key(i + 1); next == "am" || next == "pm" {
+		if minutes, ok := parseClock(key + next); ok {
+			return minutes, 2, true
+		}
+	}
+	if hour, err := strconv.Atoi(key); bare && err == nil && hour >= 0 && hour <= 23 {
+		return hour * 60, 1, true
+	}
+	return 0, 0, false
+}
+
+// Prepositions after which bare number is an hour
+var hourPrepositions = map[string]bool{"at": true, "by": true, "в": true, "во": true, "до": true, "к": true}
+
+func (p *parser) matchTime(i int, preposition string) int {
+	if p.start != nil {
+		return 0
+	}
+	minutes, n, ok := p.clock(i, hourPrepositions[preposition])
+	if !ok {
+		return 0
+	}
+	p.start = &minutes
+	return n
+}
+
+// Times of range written as one token like 15:00-15:30 or 3-4pm
+func parseClockRange(s string, bare bool) (start, end int, ok bool) {
+	for _, sep := range []string{"-", "–", "—"} {
+		a, b, found := strings.Cut(s, sep)
+		if !found {
+			continue
+		}
+
+		if end, ok = parseClock(b); !ok {
+			return 0, 0, false
+		}
+		// Meridiem of end is shared by start without one
+		for _, m := range []string{"am", "pm"} {
+			if strings.HasSuffix(b, m) && !strings.HasSuffix(a, "am") && !strings.HasSuffix(a, "pm") {
+				if start, ok = parseClock(a + m); ok && start <= end {
+					return start, end, true
+				}
+			}
+		}
+
+		if start, ok = parseClock(a); ok {
+			return start, end, true
+		}
+		if hour, err := strconv.Atoi(a); bare && err == nil && hour >= 0 && hour <= 23 {
+			return hour * 60, end, true
+		}
+		return 0, 0, false
+	}
+	return 0, 0, false
+}
+
+// "15:00-15:30", "from 3pm to 4pm", "с 10 до 12"
+func (p *parser) matchRange(i int, _ string) int {
+	if p.start != nil || p.end != nil {
+		return 0
+	}
+
+	j := i
+	if fromWords[p.key(i)] {
+		j++
+	}
+	bare := j > i
+
+	if start, end, ok := parseClockRange(p.key(j), bare); ok {
+		p.start, p.end = &start, &end
+		return j + 1 - i
+	}
+
+	start, n, ok := p.clock(j, bare)
+	if !ok || !toWords[p.key(j+n)] {
+		return 0
+	}
+	end, m, ok := p.clock(j+n+1, bare)
+	if !ok {
+		return 0
+	}
+	// Like "from 3 to 4pm"
+	startMeridiem := n == 2 || strings.HasSuffix(p.key(j), "am") || strings.HasSuffix(p.key(j), "pm")
+	if !startMeridiem && strings.HasSuffix(p.key(j+n+m), "pm") && start < 12*60 && start+12*60 <= end {
+		start += 12 * 60
+	}
+	p.start, p.end = &start, &end
+	return j + n + 1 + m - i
+}
+
+func midnight(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// Day at i which is number like 15 or ordinal like 15th
+func (p *parser) dayOfMonth(i int) (int, bool) {
+	if day, ok := parseOrdinal(p.key(i)); ok {
+		return day, true
+	}
+	day, err := strconv.Atoi(p.key(i))
+	return day, err == nil && day >= 1 && day <= 31
+}
+
+func (p *parser) year(i int) (int, bool) {
+	year, err := strconv.Atoi(p.key(i))
+	return year, err == nil && len(p.key(i)) == 4 && year >= 1970
+}
+
+// Date of day and month in given year or, if it is 0, the nearest one not earlier than today
+func (p *parser) dayAndMonth(day int, month time.Month, year int) (time.Time, bool) {
+	today := midnight(p.now)
+	y := year
+	if y == 0 {
+		y = today.Year()
+	}
+
+	date := time.Date(y, month, day, 0, 0, 0, 0, p.now.Location())
+	if year == 0 && date.Before(today) {
+		date = time.Date(y+1, month, day, 0, 0, 0, 0, p.now.Location())
+	}
+	return date, date.Day() == day
+}
+
+// Date like "dd.mm" or "dd.mm.yyyy"
+func (p *parser) dotted(key string) (time.Time, bool) {
+	parts := strings.Split(key, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return time.Time{}, false
+	}
+
+	day, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return time.Time{}, false
+	}
+	month, err := strconv.Atoi(parts[1])
+	if err != nil || month < 1 || month > 12 {
+		return time.Time{}, false
+	}
+	year := 0
+	if len(parts) == 3 {
+		if year, err = strconv.Atoi(parts[2]); err != nil {
+			return time.Time{}, false
+		}
+		if len(parts[2]) == 2 {
+			year += 2000
+		}
+	}
+	return p.dayAndMonth(day, time.Month(month), year)
+}
+
+// "tomorrow", "friday", "2026-03-15", "15.03", "march 15th", "15 марта 2027"
+func (p *parser) matchDate(i int, preposition string) int {
+	if p.date != nil {
+		return 0
+	}
+
+	key := p.key(i)
+	today := midnight(p.now)
+	date, n := time.Time{}, 0
+
+	if days, ok := relativeDays[key]; ok {
+		date, n = today.AddDate(0, 0, days), 1
+	} else if key == "day" && p.key(i+1) == "after" && p.key(i+2) == "tomorrow" {
+		date, n = today.AddDate(0, 0, 2), 3
+	} else if d, ok := p.weekday(i, preposition != ""); ok {
+		// The nearest one after today
+		days := (int(d)-int(today.Weekday())+6)%7 + 1
+		date, n = today.AddDate(0, 0, days), 1
+	} else if t, err := time.ParseInLocation(time.DateOnly, key, p.now.Location()); err == nil {
+		date, n = t, 1
+	} else if t, ok := p.dotted(key); ok {
+		date, n = t, 1
+	} else if month, ok := months[key]; ok {
+		day, ok := p.dayOfMonth(i + 1)
+		if !ok {
+			return 0
+		}
+		n = 2
+		year, ok := p.year(i + 2)
+		if ok {
+			n++
+		}
+		if date, ok = p.dayAndMonth(day, month, year); !ok {
+			return 0
+		}
+	} else if day, ok := p.dayOfMonth(i); ok {
+		j := i + 1
+		if p.key(j) == "of" {
+			j++
+		}
+		month, ok := months[p.key(j)]
+		if !ok {
+			return 0
+		}
+		n = j + 1 - i
+		year, ok := p.year(j + 1)
+		if ok {
+			n++
+		}
+		if date, ok = p.dayAndMonth(day, month, year); !ok {
+			return 0
+		}
+	} else {
+		return 0
+	}
+
+	p.date = &date
+	return n
+}
+
+// Day of month like "the 1st" or "1-го числа"
+func (p *parser) matchOrdinal(i int, _ string) int {
+	if p.monthDay != 0 {
+		return 0
+	}
+
+	day, ok := parseOrdinal(p.key(i))
+	if !ok {
+		if day, ok = p.dayOfMonth(i); !ok || p.key(i+1) != "числа" {
+			return 0
+		}
+	}
+
+	n := 1
+	if p.key(i+1) == "числа" {
+		n++
+	}
+	p.monthDay = day
+	return n
+}
+
+// Preposition like "at" or "до" followed by something recognized
+func (p *parser) matchPreposition(i int, _ string) int {
+	key := p.key(i)
+	if !prepositions[key] && !deadlineWords[key] {
+		return 0
+	}
+
+	n := p.match(i+1, key)
+	if n == 0 {
+		return 0
+	}
+	if deadlineWords[key] {
+		p.deadline = true
+	}
+	return n + 1
+}
+
+func at(day time.Time, minutes int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, day.Location())
+}
+
+// The first day not earlier than the date of task or today on which f is true.
+// Today counts only if time of day in minutes hasn't passed yet
+func (p *parser) firstDay(minutes int, f func(time.Time) bool) time.Time {
+	day := midnight(p.now)
+	if p.date != nil {
+		day = *p.date
+	} else if at(day, minutes).Before(p.now) {
+		day = day.AddDate(0, 0, 1)
+	}
+
+	for i := 0; i < searchDays && !f(day); i++ {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// Day of task when only its time of day in minutes may be known
+func (p *parser) day(minutes int) time.Time {
+	if p.monthDay != 0 {
+		return p.firstDay(minutes, func(t time.Time) bool { return t.Day() == p.monthDay })
+	}
+	return p.firstDay(minutes, func(time.Time) bool { return true })
+}
+
+func (p *parser) resolveDeadline(r *Result) {
+	minutes := endOfDay
+	if p.start != nil {
+		minutes = *p.start
+	}
+
+	deadline := at(p.day(minutes), minutes)
+	r.TaskType = tasks.KindTaskWithDeadline
+	r.Deadline = &deadline
+}
+
+// Start and end of task beginning on day at time of day in minutes
+func (p *parser) span(day time.Time, minutes int) (time.Time, time.Time) {
+	start := at(day, minutes)
+	if p.end == nil {
+		return start, start.Add(p.duration)
+	}
+
+	end := at(day, *p.end)
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end
+}
+
+func (p *parser) resolveEvent(r *Result) {
+	var start, end time.Time
+	switch {
+	case p.start != nil:
+		start, end = p.span(p.day(*p.start), *p.start)
+	case p.date == nil && p.monthDay == 0:
+		// Starts right away
+		start = p.now.Truncate(time.Minute)
+		end = start.Add(p.duration)
+	default:
+		start, end = p.span(p.day(defaultTime), defaultTime)
+	}
+
+	r.TaskType = tasks.KindEvent
+	r.StartsAt, r.EndsAt = &start, &end
+}
+
+// Calendar days from a to b regardless of daylight saving changes
+func daysBetween(a, b time.Time) int {
+	utc := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return int(utc(b).Sub(utc(a)) / (24 * time.Hour))
+}
+
+func (p *parser) resolveRepeat(r *Result) {
+	rep := p.repeat
+	minutes := defaultTime
+	if p.start != nil {
+		minutes = *p.start
+	}
+
+	r.TaskType = tasks.KindRepeatingTask
+	r.Recurrence = "every " + rep.unit
+	if rep.every > 1 {
+		r.Recurrence = fmt.Sprintf("every %d %ss", rep.every, rep.unit)
+	}
+
+	// Occurs on days for which f is true in cycle of days starting from the first of them
+	daily := func(days int, f func(first, day time.Time) bool) time.Time {
+		first := p.firstDay(minutes, func(day time.Time) bool { return f(day, day) })
+		r.Period, r.Loop = 24*60*60, int64(days)
+		for i := range days {
+			if !f(first, first.AddDate(0, 0, i)) {
+				r.Except = append(r.Except, int64(i))
+			}
+		}
+		return first
+	}
+
+	var first time.Time
+	switch {
+	case rep.unit == unitMinute || rep.unit == unitHour:
+		first = p.now.Truncate(time.Minute)
+		if p.start != nil || p.date != nil {
+			first = at(p.day(minutes), minutes)
+		}
+		r.Period, r.Loop = int64(shift(first, rep.every, rep.unit).Sub(first)/time.Second), 1
+		minutes = first.Hour()*60 + first.Minute()
+	case rep.unit == unitDay || (rep.unit == unitWeek && len(rep.weekdays) == 0):
+		first = p.day(minutes)
+		r.Period, r.Loop = int64(rep.every)*24*60*60, 1
+		if rep.unit == unitWeek {
+			r.Period *= 7
+		}
+	case rep.unit == unitMonth:
+		first = p.day(minutes)
+		r.Recurrence += fmt.Sprintf(" on day %d", first.Day())
+		r.Months, r.Loop = int64(rep.every), 1
+	case rep.unit == unitYear:
+		first = p.day(minutes)
+		r.Recurrence += " on " + strings.ToLower(first.Month().String()) + " " + strconv.Itoa(first.Day())
+		r.Months, r.Loop = 12*int64(rep.every), 1
+	default:
+		// Weekly on given weekdays
+		var names []string
+		for _, d := range rep.weekdays {
+			names = append(names, strings.ToLower(d.String()))
+		}
+		r.Recurrence += " on " + strings.Join(names, ", ")
+		first = daily(7*rep.every, func(first, day time.Time) bool {
+			return slices.Contains(rep.weekdays, day.Weekday()) && daysBetween(first, day) < 7
+		})
+	}
+
+	start, end := p.span(first, minutes)
+	r.StartsAt, r.EndsAt = &start, &end
+}
@@ -0,0 +1,150 @@
+package quickadd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	tasks "github.com/Kry0z1/fancytasks/pkg"
+)
+
+func TestParse(t *testing.T) {
+	// Wednesday
+	now := time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC)
+	// Days past the end of May are the ones of June
+	at := func(day, hour, minute int) *time.Time {
+		t := time.Date(2024, 5, day, hour, minute, 0, 0, time.UTC)
+		return &t
+	}
+
+	tests := []struct {
+		text string
+		want Result
+	}{
+		{
+			text: "Buy milk",
+			want: Result{TaskType: tasks.KindBaseTask, Title: "Buy milk", Matched: []string{}},
+		},
+		{
+			text: "Call Bob tomorrow 15:00-15:30",
+			want: Result{
+				TaskType: tasks.KindEvent, Title: "Call Bob",
+				StartsAt: at(16, 15, 0), EndsAt: at(16, 15, 30),
+				Matched: []string{"tomorrow", "15:00-15:30"},
+			},
+		},
+		{
+			text: "Pay rent by the 1st #finance !high",
+			want: Result{
+				TaskType: tasks.KindTaskWithDeadline, Title: "Pay rent", Tags: []string{"finance"}, Priority: 3,
+				Deadline: at(32, 23, 59),
+				Matched:  []string{"by the 1st", "#finance", "!high"},
+			},
+		},
+		{
+			text: "Gym on friday @health",
+			want: Result{
+				TaskType: tasks.KindTaskWithDeadline, Title: "Gym", Topic: "health",
+				Deadline: at(17, 23, 59),
+				Matched:  []string{"on friday", "@health"},
+			},
+		},
+		{
+			text: "Send report in 2 days",
+			want: Result{
+				TaskType: tasks.KindTaskWithDeadline, Title: "Send report",
+				Deadline: at(17, 23, 59),
+				Matched:  []string{"in 2 days"},
+			},
+		},
+		{
+			text: "Read book in 2 hours !!",
+			want: Result{
+				TaskType: tasks.KindTaskWithDeadline, Title: "Read book", Priority: 2,
+				Deadline: at(15, 12, 0),
+				Matched:  []string{"in 2 hours", "!!"},
+			},
+		},
+		{
+			text: "Standup every weekday at 9:30 for 15 min",
+			want: Result{
+				TaskType: tasks.KindRepeatingTask, Title: "Standup",
+				StartsAt: at(16, 9, 30), EndsAt: at(16, 9, 45),
+				Period: 24 * 60 * 60, Loop: 7, Except: []int64{2, 3},
+				Recurrence: "every week on monday, tuesday, wednesday, thursday, friday",
+				Matched:    []string{"every weekday", "at 9:30", "for 15 min"},
+			},
+		},
+		{
+			text: "Water plants daily",
+			want: Result{
+				TaskType: tasks.KindRepeatingTask, Title: "Water plants",
+				StartsAt: at(16, 9, 0), EndsAt: at(16, 9, 0),
+				Period: 24 * 60 * 60, Loop: 1, Recurrence: "every day",
+				Matched: []string{"daily"},
+			},
+		},
+		{
+			text: "Позвонить маме завтра в 15:00",
+			want: Result{
+				TaskType: tasks.KindTaskWithDeadline, Title: "Позвонить маме",
+				Deadline: at(16, 15, 0),
+				Matched:  []string{"завтра", "в 15:00"},
+			},
+		},
+		{
+			text: "Встреча с 10 до 12 в пятницу @работа",
+			want: Result{
+				TaskType: tasks.KindEvent, Title: "Встреча", Topic: "работа",
+				StartsAt: at(17, 10, 0), EndsAt: at(17, 12, 0),
+				Matched: []string{"с 10 до 12", "в пятницу", "@работа"},
+			},
+		},
+		{
+			text: "Уборка по субботам",
+			want: Result{
+				TaskType: tasks.KindRepeatingTask, Title: "Уборка",
+				StartsAt: at(18, 9, 0), EndsAt: at(18, 9, 0),
+				Period: 24 * 60 * 60, Loop: 7, Except: []int64{1, 2, 3, 4, 5, 6},
+				Recurrence: "every week on saturday",
+				Matched:    []string{"по субботам"},
+			},
+		},
+		{
+			text: "Pay rent every month on the 1st #finance !high",
+			want: Result{
+				TaskType: tasks.KindRepeatingTask, Title: "Pay rent", Tags: []string{"finance"}, Priority: 3,
+				StartsAt: at(32, 9, 0), EndsAt: at(32, 9, 0),
+				Months: 1, Loop: 1, Recurrence: "every month on day 1",
+				Matched: []string{"every month", "on the 1st", "#finance", "!high"},
+			},
+		},
+		{
+			text: "Renew insurance yearly",
+			want: Result{
+				TaskType: tasks.KindRepeatingTask, Title: "Renew insurance",
+				StartsAt: at(16, 9, 0), EndsAt: at(16, 9, 0),
+				Months: 12, Loop: 1, Recurrence: "every year on may 16",
+				Matched: []string{"yearly"},
+			},
+		},
+		{
+			text: "Платить за квартиру ежемесячно",
+			want: Result{
+				TaskType: tasks.KindRepeatingTask, Title: "Платить за квартиру",
+				StartsAt: at(16, 9, 0), EndsAt: at(16, 9, 0),
+				Months: 1, Loop: 1, Recurrence: "every month on day 16",
+				Matched: []string{"ежемесячно"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got := Parse(tt.text, now)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
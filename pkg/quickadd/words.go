@@ -0,0 +1,200 @@
+package quickadd
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Units of relative times, durations and recurrences
+const (
+	unitMinute = "minute"
+	unitHour   = "hour"
+	unitDay    = "day"
+	unitWeek   = "week"
+	unitMonth  = "month"
+	unitYear   = "year"
+)
+
+var units = map[string]string{
+	"m": unitMinute, "min": unitMinute, "mins": unitMinute, "minute": unitMinute, "minutes": unitMinute,
+	"h": unitHour, "hr": unitHour, "hrs": unitHour, "hour": unitHour, "hours": unitHour,
+	"d": unitDay, "day": unitDay, "days": unitDay,
+	"w": unitWeek, "wk": unitWeek, "week": unitWeek, "weeks": unitWeek,
+	"month": unitMonth, "months": unitMonth,
+	"year": unitYear, "years": unitYear,
+
+	"мин": unitMinute, "минута": unitMinute, "минуту": unitMinute, "минуты": unitMinute, "минут": unitMinute,
+	"ч": unitHour, "час": unitHour, "часа": unitHour, "часов": unitHour,
+	"день": unitDay, "дня": unitDay, "дней": unitDay, "сутки": unitDay,
+	"неделя": unitWeek, "неделю": unitWeek, "недели": unitWeek, "недель": unitWeek,
+	"месяц": unitMonth, "месяца": unitMonth, "месяцев": unitMonth,
+	"год": unitYear, "года": unitYear, "лет": unitYear,
+}
+
+var numbers = map[string]int{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+	"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10,
+
+	"один": 1, "одну": 1, "одна": 1, "два": 2, "две": 2, "три": 3, "четыре": 4, "пять": 5,
+	"шесть": 6, "семь": 7, "восемь": 8, "девять": 9, "десять": 10,
+}
+
+// Full names match anywhere, abbreviations only after prepositions and recurrence words
+var weekdays = map[string]time.Weekday{
+	"monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday, "thursday": time.Thursday,
+	"friday": time.Friday, "saturday": time.Saturday, "sunday": time.Sunday,
+
+	// Nominative, accusative and genitive
+	"понедельник": time.Monday, "понедельника": time.Monday,
+	"вторник": time.Tuesday, "вторника": time.Tuesday,
+	"среда": time.Wednesday, "среду": time.Wednesday, "среды": time.Wednesday,
+	"четверг": time.Thursday, "четверга": time.Thursday,
+	"пятница": time.Friday, "пятницу": time.Friday, "пятницы": time.Friday,
+	"суббота": time.Saturday, "субботу": time.Saturday, "субботы": time.Saturday,
+	"воскресенье": time.Sunday, "воскресенья": time.Sunday,
+}
+
+var weekdayAbbreviations = map[string]time.Weekday{
+	"mon": time.Monday, "tue": time.Tuesday, "tues": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "fri": time.Friday,
+	"sat": time.Saturday, "sun": time.Sunday,
+
+	"пн": time.Monday, "вт": time.Tuesday, "ср": time.Wednesday, "чт": time.Thursday,
+	"пт": time.Friday, "сб": time.Saturday, "вс": time.Sunday,
+}
+
+// Dative plural used as "по понедельникам"
+var weekdaysPlural = map[string]time.Weekday{
+	"mondays": time.Monday, "tuesdays": time.Tuesday, "wednesdays": time.Wednesday, "thursdays": time.Thursday,
+	"fridays": time.Friday, "saturdays": time.Saturday, "sundays": time.Sunday,
+
+	"понедельникам": time.Monday, "вторникам": time.Tuesday, "средам": time.Wednesday, "четвергам": time.Thursday,
+	"пятницам": time.Friday, "субботам": time.Saturday, "воскресеньям": time.Sunday,
+}
+
+var months = map[string]time.Month{
+	"january": time.January, "jan": time.January, "february": time.February, "feb": time.February,
+	"march": time.March, "mar": time.March, "april": time.April, "apr": time.April, "may": time.May,
+	"june": time.June, "jun": time.June, "july": time.July, "jul": time.July,
+	"august": time.August, "aug": time.August, "september": time.September, "sep": time.September, "sept": time.September,
+	"october": time.October, "oct": time.October, "november": time.November, "nov": time.November,
+	"december": time.December, "dec": time.December,
+
+	// Genitive as in "5 марта"
+	"января": time.January, "февраля": time.February, "марта": time.March, "апреля": time.April,
+	"мая": time.May, "июня": time.June, "июля": time.July, "августа": time.August,
+	"сентября": time.September, "октября": time.October, "ноября": time.November, "декабря": time.December,
+}
+
+var priorities = map[string]int{
+	"low": 1, "medium": 2, "normal": 2, "high": 3, "urgent": 4,
+	"низкий": 1, "средний": 2, "обычный": 2, "высокий": 3, "срочно": 4, "срочный": 4,
+}
+
+// Days from today
+var relativeDays = map[string]int{
+	"today": 0, "tomorrow": 1, "tmr": 1,
+	"сегодня": 0, "завтра": 1, "послезавтра": 2,
+}
+
+// Recurrences written as one word
+var repeatWords = map[string]string{
+	"hourly": unitHour, "daily": unitDay, "weekly": unitWeek, "monthly": unitMonth, "yearly": unitYear, "annually": unitYear,
+	"ежечасно": unitHour, "ежедневно": unitDay, "еженедельно": unitWeek, "ежемесячно": unitMonth, "ежегодно": unitYear,
+}
+
+var everyWords = map[string]bool{
+	"every": true, "each": true,
+	"каждый": true, "каждую": true, "каждое": true, "каждые": true, "каждого": true,
+}
+
+// Prepositions taken along with date or time following them
+var prepositions = map[string]bool{
+	"at": true, "on": true, "next": true, "this": true, "the": true,
+	"в": true, "во": true, "на": true,
+}
+
+// Prepositions making date or time following them a deadline
+var deadlineWords = map[string]bool{
+	"by": true, "due": true, "before": true, "until": true,
+	"до": true, "к": true,
+}
+
+var noonWords = map[string]int{
+	"noon": 12 * 60, "midday": 12 * 60, "midnight": 0,
+	"полдень": 12 * 60, "полночь": 0,
+}
+
+var (
+	fromWords  = map[string]bool{"from": true, "с": true, "со": true}
+	toWords    = map[string]bool{"to": true, "till": true, "until": true, "-": true, "–": true, "—": true, "до": true, "по": true}
+	forWords   = map[string]bool{"for": true, "на": true}
+	inWords    = map[string]bool{"in": true, "через": true}
+	andWords   = map[string]bool{"and": true, "&": true, "и": true}
+	otherWords = map[string]bool{"other": true, "second": true, "второй": true}
+)
+
+// Parses count like 3 or three
+func parseNumber(s string) (int, bool) {
+	if n, ok := numbers[s]; ok {
+		return n, true
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil && n > 0
+}
+
+var ordinalSuffixes = []string{"st", "nd", "rd", "th", "-го", "-е", "-ое"}
+
+// Parses day of month like 1st, 22nd or 5-го
+func parseOrdinal(s string) (int, bool) {
+	for _, suffix := range ordinalSuffixes {
+		if digits, ok := strings.CutSuffix(s, suffix); ok {
+			n, err := strconv.Atoi(digits)
+			return n, err == nil && n >= 1 && n <= 31
+		}
+	}
+	return 0, false
+}
+
+// Parses time of day like 15:00, 9:30, 3pm or 3:30am into minutes from midnight.
+// Bare hours are not times unless meridiem is given
+func parseClock(s string) (int, bool) {
+	meridiem := ""
+	for _, m := range []string{"am", "pm"} {
+		if rest, ok := strings.CutSuffix(s, m); ok {
+			meridiem, s = m, rest
+			break
+		}
+	}
+
+	hours, minutes, hasMinutes := strings.Cut(s, ":")
+	if !hasMinutes && meridiem == "" {
+		return 0, false
+	}
+
+	h, err := strconv.Atoi(hours)
+	if err != nil || h < 0 || h > 23 {
+		return 0, false
+	}
+	m := 0
+	if hasMinutes {
+		if len(minutes) != 2 {
+			return 0, false
+		}
+		if m, err = strconv.Atoi(minutes); err != nil || m < 0 || m > 59 {
+			return 0, false
+		}
+	}
+
+	if meridiem != "" {
+		if h < 1 || h > 12 {
+			return 0, false
+		}
+		h %= 12
+		if meridiem == "pm" {
+			h += 12
+		}
+	}
+	return h*60 + m, true
+}
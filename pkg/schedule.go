@@ -0,0 +1,212 @@
+package tasks
+
+import (
+	"slices"
+	"time"
+)
+
+// Upper bound of occurrences expanded from one repeating task
+const MaxOccurrences = 10000
+
+type Occurrence struct {
+	// Number of occurrence counting from 0
+	Index    int64     `json:"index"`
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+// Reports whether [start, end) intersects [from, to).
+// Zero-length intervals intersect if they are inside [from, to)
+func Overlaps(start, end, from, to time.Time) bool {
+	return start.Before(to) && (end.After(from) || !start.Before(from))
+}
+
+func (t RepeatingTask) Skipped(index int64) bool {
+	return t.Loop > 0 && slices.Contains(t.Except, index%t.Loop)
+}
+
+// Returns occurrences intersecting [from, to) which are not skipped.
+// Period is measured in seconds, task with non-positive period and months occurs once
+func (t RepeatingTask) Occurrences(from, to time.Time) []Occurrence {
+	var result []Occurrence
+	duration := t.EndsAt.Sub(t.StartsAt)
+
+	if !t.repeats() {
+		if Overlaps(t.StartsAt, t.EndsAt, from, to) {
+			result = append(result, Occurrence{0, t.StartsAt, t.EndsAt})
+		}
+		return result
+	}
+
+	anchor := t.anchor()
+	for index := t.indexBefore(anchor, from.Add(-duration)); len(result) < MaxOccurrences; index++ {
+		start := t.start(anchor, index)
+		if !start.Before(to) {
+			break
+		}
+
+		end := start.Add(duration)
+		if t.Skipped(index) || !Overlaps(start, end, from, to) {
+			continue
+		}
+		result = append(result, Occurrence{index, start, end})
+	}
+
+	return result
+}
+
+func (t RepeatingTask) repeats() bool {
+	return t.Period > 0 || t.Months > 0
+}
+
+// StartsAt in location calendar months are counted in, which is configured timezone
+func (t RepeatingTask) anchor() time.Time {
+	if t.Months <= 0 {
+		return t.StartsAt
+	}
+	return t.StartsAt.In(Cfg.FreeBusy.GetLocation())
+}
+
+// Start of occurrence with given index. Months keep day of month of anchor,
+// shorter months which don't have it get their last day instead
+func (t RepeatingTask) start(anchor time.Time, index int64) time.Time {
+	if t.Months <= 0 {
+		return anchor.Add(time.Duration(index) * time.Duration(t.Period) * time.Second)
+	}
+
+	months := int64(anchor.Month()-1) + index*t.Months
+	year, month := anchor.Year()+int(months/12), time.Month(months%12+1)
+	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	return time.Date(year, month, min(anchor.Day(), lastDay),
+		anchor.Hour(), anchor.Minute(), anchor.Second(), anchor.Nanosecond(), anchor.Location())
+}
+
+// Returns index of occurrence starting not later than at, or 0 if at is before the first one
+func (t RepeatingTask) indexBefore(anchor, at time.Time) int64 {
+	if !at.After(anchor) {
+		return 0
+	}
+	if t.Months <= 0 {
+		return int64(at.Sub(anchor) / (time.Duration(t.Period) * time.Second))
+	}
+
+	at = at.In(anchor.Location())
+	months := int64(at.Year()-anchor.Year())*12 + int64(at.Month()-anchor.Month())
+	return max(months/t.Months-1, 0)
+}
+
+type AgendaItem struct {
+	TaskType string    `json:"tasktype"`
+	ID       int       `json:"id"`
+	Title    string    `json:"title"`
+	Topic    string    `json:"topic"`
+	Done     bool      `json:"done"`
+	StartsAt time.Time `json:"starts_at"`
+	// Absent for deadlines
+	EndsAt *time.Time `json:"ends_at,omitempty"`
+	// Present for repeating tasks only
+	Occurrence *int64 `json:"occurrence,omitempty"`
+}
+
+type Agenda struct {
+	Items   []AgendaItem `json:"items"`
+	Undated []BaseTask   `json:"undated"`
+}
+
+// Merges dated tasks of user intersecting [from, to) into one chronological stream.
+// Base tasks have no dates and are put into separate bucket
+func BuildAgenda(user *User, from, to time.Time) Agenda {
+	agenda := Agenda{
+		Items:   []AgendaItem{},
+		Undated: user.BaseTasks,
+	}
+	if agenda.Undated == nil {
+		agenda.Undated = []BaseTask{}
+	}
+
+	item := func(kind string, t BaseTask, start time.Time) AgendaItem {
+		return AgendaItem{
+			TaskType: kind,
+			ID:       t.ID,
+			Title:    t.Title,
+			Topic:    t.Topic,
+			Done:     t.Done,
+			StartsAt: start,
+		}
+	}
+
+	for _, e := range user.Events {
+		if !Overlaps(e.StartsAt, e.EndsAt, from, to) {
+			continue
+		}
+		it := item(KindEvent, e.BaseTask, e.StartsAt)
+		it.EndsAt = &e.EndsAt
+		agenda.Items = append(agenda.Items, it)
+	}
+
+	for _, d := range user.TasksWithDeadline {
+		if d.Deadline.Before(from) || !d.Deadline.Before(to) {
+			continue
+		}
+		agenda.Items = append(agenda.Items, item(KindTaskWithDeadline, d.BaseTask, d.Deadline))
+	}
+
+	for _, rt := range user.RepeatingTasks {
+		for _, o := range rt.Occurrences(from, to) {
+			it := item(KindRepeatingTask, rt.BaseTask, o.StartsAt)
+			it.EndsAt = &o.EndsAt
+			it.Occurrence = &o.Index
+			agenda.Items = append(agenda.Items, it)
+		}
+	}
+
+	slices.SortStableFunc(agenda.Items, func(a, b AgendaItem) int {
+		if c := a.StartsAt.Compare(b.StartsAt); c != 0 {
+			return c
+		}
+		return a.ID - b.ID
+	})
+
+	return agenda
+}
+
+// Returns first not skipped occurrence with index greater than after.
+// Returns false if task doesn't repeat or every occurrence is skipped
+func (t RepeatingTask) NextOccurrence(after int64) (Occurrence, bool) {
+	if !t.repeats() {
+		return Occurrence{}, false
+	}
+
+	for index := after + 1; index <= after+max(t.Loop, 1); index++ {
+		if !t.Skipped(index) {
+			return t.Occurrence(index), true
+		}
+	}
+
+	return Occurrence{}, false
+}
+
+// Returns occurrence with given index whether it is skipped or not
+func (t RepeatingTask) Occurrence(index int64) Occurrence {
+	start := t.start(t.anchor(), index)
+	return Occurrence{index, start, start.Add(t.EndsAt.Sub(t.StartsAt))}
+}
+
+// Returns first not skipped occurrence starting not earlier than at
+func (t RepeatingTask) OccurrenceAfter(at time.Time) (Occurrence, bool) {
+	if !t.repeats() {
+		o := t.Occurrence(0)
+		return o, !o.StartsAt.Before(at)
+	}
+
+	anchor := t.anchor()
+	index := t.indexBefore(anchor, at)
+	for t.start(anchor, index).Before(at) {
+		index++
+	}
+
+	if !t.Skipped(index) {
+		return t.Occurrence(index), true
+	}
+	return t.NextOccurrence(index)
+}
@@ -0,0 +1,72 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMonthlyOccurrences(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 9, 0, 0, 0, time.UTC)
+	}
+	task := func(start time.Time, months int64) RepeatingTask {
+		return RepeatingTask{Event: Event{StartsAt: start, EndsAt: start.Add(time.Hour)}, Months: months, Loop: 1}
+	}
+
+	tests := []struct {
+		name     string
+		task     RepeatingTask
+		from, to time.Time
+		want     []time.Time
+	}{
+		{
+			name: "monthly",
+			task: task(date(2024, 1, 1), 1),
+			from: date(2024, 2, 15),
+			to:   date(2024, 5, 15),
+			want: []time.Time{date(2024, 3, 1), date(2024, 4, 1), date(2024, 5, 1)},
+		},
+		{
+			name: "end of month",
+			task: task(date(2024, 1, 31), 1),
+			from: date(2024, 1, 1),
+			to:   date(2024, 5, 1),
+			want: []time.Time{date(2024, 1, 31), date(2024, 2, 29), date(2024, 3, 31), date(2024, 4, 30)},
+		},
+		{
+			name: "leap day yearly",
+			task: task(date(2024, 2, 29), 12),
+			from: date(2025, 1, 1),
+			to:   date(2029, 1, 1),
+			want: []time.Time{date(2025, 2, 28), date(2026, 2, 28), date(2027, 2, 28), date(2028, 2, 29)},
+		},
+		{
+			name: "every 18 months",
+			task: task(date(2020, 6, 10), 18),
+			from: date(2023, 1, 1),
+			to:   date(2026, 1, 1),
+			want: []time.Time{date(2023, 6, 10), date(2024, 12, 10)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []time.Time
+			for _, o := range tt.task.Occurrences(tt.from, tt.to) {
+				got = append(got, o.StartsAt)
+				if o != tt.task.Occurrence(o.Index) {
+					t.Errorf("Occurrence(%d) = %v, want %v", o.Index, tt.task.Occurrence(o.Index), o)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Occurrences() = %v, want %v", got, tt.want)
+			}
+
+			o, ok := tt.task.OccurrenceAfter(tt.from)
+			if !ok || !o.StartsAt.Equal(tt.want[0]) {
+				t.Errorf("OccurrenceAfter() = %v, %v, want %v", o.StartsAt, ok, tt.want[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package tasks
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MaxTemplateName = 128
+	// Tasks in template including the root one
+	MaxTemplateTasks = 200
+)
+
+var (
+	ErrInvalidTemplateTask = errors.New("Template tasks must have known type, title and valid times")
+	ErrTooLargeTemplate    = errors.New("Template has too many tasks")
+)
+
+// Variable every template has: date template is instantiated at like 2006-01-02
+const TemplateDateVariable = "date"
+
+// Placeholders like {{name}} in titles, descriptions and checklist items
+var templateVariableRegexp = regexp.MustCompile(`\{\{\s*([\w.-]+)\s*\}\}`)
+
+// Task of template and its children. Times are offsets in seconds from the moment template is instantiated at
+type TemplateTask struct {
+	TaskType    string `json:"tasktype"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	// Name of topic in workspace of instantiation, default one if empty
+	Topic    string   `json:"topic,omitempty"`
+	Priority int      `json:"priority,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	// Titles of checklist items in order
+	Checklist    []string `json:"checklist,omitempty"`
+	AutoComplete bool     `json:"auto_complete,omitempty"`
+	// Start and length of events and repeating tasks
+	StartOffset int64 `json:"start_offset,omitempty"`
+	Duration    int64 `json:"duration,omitempty"`
+	// Deadline of tasks with deadline
+	DeadlineOffset int64          `json:"deadline_offset,omitempty"`
+	Period         int64          `json:"period,omitempty"`
+	Months         int64          `json:"months,omitempty"`
+	Loop           int64          `json:"loop,omitempty"`
+	Except         []int64        `json:"except,omitempty"`
+	Children       []TemplateTask `json:"children,omitempty"`
+}
+
+type Template struct {
+	ID          int          `json:"id"`
+	Owner       string       `json:"owner"`
+	WorkspaceID int          `json:"workspace_id"`
+	Name        string       `json:"name"`
+	Task        TemplateTask `json:"task"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// Calls f for t and every its descendant, parents before children
+func (t *TemplateTask) walk(f func(*TemplateTask)) {
+	f(t)
+	for i := range t.Children {
+		t.Children[i].walk(f)
+	}
+}
+
+func (t *TemplateTask) Validate() error {
+	count := 0
+	var err error
+	t.walk(func(t *TemplateTask) {
+		count++
+		if err == nil && !t.valid() {
+			err = ErrInvalidTemplateTask
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if count > MaxTemplateTasks {
+		return ErrTooLargeTemplate
+	}
+	return nil
+}
+
+func (t *TemplateTask) valid() bool {
+	if !slices.Contains(Kinds, t.TaskType) || strings.TrimSpace(t.Title) == "" {
+		return false
+	}
+	if t.Priority < 0 || t.Priority > MaxPriority || t.Duration < 0 {
+		return false
+	}
+	if t.TaskType == KindRepeatingTask && t.Loop <= 0 {
+		return false
+	}
+	for _, item := range t.Checklist {
+		if strings.TrimSpace(item) == "" || utf8.RuneCountInString(item) > MaxChecklistItemTitle {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns names of variables used by t and its descendants, sorted alphabetically.
+// Date variable is not included as it is always known
+func (t *TemplateTask) Variables() []string {
+	result := []string{}
+	collect := func(s string) {
+		for _, m := range templateVariableRegexp.FindAllStringSubmatch(s, -1) {
+			if m[1] != TemplateDateVariable {
+				result = append(result, m[1])
+			}
+		}
+	}
+
+	t.walk(func(t *TemplateTask) {
+		collect(t.Title)
+		collect(t.Description)
+		for _, item := range t.Checklist {
+			collect(item)
+		}
+	})
+
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+// Replaces placeholders of known variables in s, unknown ones are kept
+func substituteVariables(s string, vars map[string]string) string {
+	return templateVariableRegexp.ReplaceAllStringFunc(s, func(m string) string {
+		if value, ok := vars[templateVariableRegexp.FindStringSubmatch(m)[1]]; ok {
+			return value
+		}
+		return m
+	})
+}
+
+// Task made from template which is not saved yet
+type TemplateInstance struct {
+	TaskType string
+	// One of *BaseTask, *Event, *TaskWithDeadline and *RepeatingTask
+	Task      any
+	Checklist []string
+	Children  []TemplateInstance
+}
+
+// Makes tasks of t and its descendants with times relative to at and variables substituted.
+// Only fields coming from template are set, topics are left for the caller to resolve by name
+func (t *TemplateTask) Instantiate(at time.Time, vars map[string]string) TemplateInstance {
+	all := map[string]string{TemplateDateVariable: at.Format(time.DateOnly)}
+	for name, value := range vars {
+		if name != TemplateDateVariable {
+			all[name] = value
+		}
+	}
+	return t.instantiate(at, all)
+}
+
+func (t *TemplateTask) instantiate(at time.Time, vars map[string]string) TemplateInstance {
+	base := BaseTask{
+		Title:       substituteVariables(t.Title, vars),
+		Description: substituteVariables(t.Description, vars),
+		Topic:       t.Topic,
+		Priority:    t.Priority,
+		Tags:        slices.Clone(t.Tags),
+	}
+	base.Checklist.AutoComplete = t.AutoComplete
+
+	offset := func(seconds int64) time.Time {
+		return at.Add(time.Duration(seconds) * time.Second)
+	}
+
+	var task any
+	switch t.TaskType {
+	case KindBaseTask:
+		task = &base
+	case KindEvent:
+		task = &Event{BaseTask: base, StartsAt: offset(t.StartOffset), EndsAt: offset(t.StartOffset + t.Duration)}
+	case KindTaskWithDeadline:
+		task = &TaskWithDeadline{BaseTask: base, Deadline: offset(t.DeadlineOffset)}
+	case KindRepeatingTask:
+		task = &RepeatingTask{
+			Event:  Event{BaseTask: base, StartsAt: offset(t.StartOffset), EndsAt: offset(t.StartOffset + t.Duration)},
+			Period: t.Period,
+			Months: t.Months,
+			Loop:   t.Loop,
+			Except: slices.Clone(t.Except),
+		}
+	}
+
+	result := TemplateInstance{TaskType: t.TaskType, Task: task}
+	for _, item := range t.Checklist {
+		result.Checklist = append(result.Checklist, substituteVariables(item, vars))
+	}
+	for i := range t.Children {
+		result.Children = append(result.Children, t.Children[i].instantiate(at, vars))
+	}
+	return result
+}
+
+// Calls f for i and every its descendant with their parents, parents before children
+func (i *TemplateInstance) Walk(f func(instance, parent *TemplateInstance)) {
+	var walk func(instance, parent *TemplateInstance)
+	walk = func(instance, parent *TemplateInstance) {
+		f(instance, parent)
+		for c := range instance.Children {
+			walk(&instance.Children[c], instance)
+		}
+	}
+	walk(i, nil)
+}
+
+// Returns tree of tasks made from i
+func (i *TemplateInstance) Node() TaskNode {
+	node := TaskNode{TaskType: i.TaskType, Task: i.Task}
+	for c := range i.Children {
+		node.Children = append(node.Children, i.Children[c].Node())
+	}
+	return node
+}
+
+// Returns start of events and repeating tasks and deadline of tasks with deadline
+func taskTime(task any) (time.Time, bool) {
+	switch t := task.(type) {
+	case *Event:
+		return t.StartsAt, true
+	case *TaskWithDeadline:
+		return t.Deadline, true
+	case *RepeatingTask:
+		return t.StartsAt, true
+	}
+	return time.Time{}, false
+}
+
+// Returns the earliest start or deadline in tree of node, zero time if it has none
+func TemplateAnchor(node TaskNode) time.Time {
+	anchor, found := taskTime(node.Task)
+	for _, c := range node.Children {
+		if t := TemplateAnchor(c); !t.IsZero() && (!found || t.Before(anchor)) {
+			anchor, found = t, true
+		}
+	}
+	return anchor
+}
+
+// Captures node and its children into template task with times relative to anchor.
+// Checklists are titles of items by task
+func NewTemplateTask(node TaskNode, anchor time.Time, checklists map[TaskRef][]string) TemplateTask {
+	base := Base(node.Task)
+	result := TemplateTask{
+		TaskType:     node.TaskType,
+		Title:        base.Title,
+		Description:  base.Description,
+		Topic:        base.Topic,
+		Priority:     base.Priority,
+		Tags:         base.Tags,
+		Checklist:    checklists[node.Ref()],
+		AutoComplete: base.Checklist.AutoComplete,
+	}
+
+	offset := func(t time.Time) int64 {
+		return int64(t.Sub(anchor) / time.Second)
+	}
+
+	switch t := node.Task.(type) {
+	case *Event:
+		result.StartOffset, result.Duration = offset(t.StartsAt), int64(t.EndsAt.Sub(t.StartsAt)/time.Second)
+	case *TaskWithDeadline:
+		result.DeadlineOffset = offset(t.Deadline)
+	case *RepeatingTask:
+		result.StartOffset, result.Duration = offset(t.StartsAt), int64(t.EndsAt.Sub(t.StartsAt)/time.Second)
+		result.Period, result.Months, result.Loop, result.Except = t.Period, t.Months, t.Loop, t.Except
+	}
+
+	for _, c := range node.Children {
+		result.Children = append(result.Children, NewTemplateTask(c, anchor, checklists))
+	}
+	return result
+}
@@ -0,0 +1,120 @@
+package tasks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInstantiate(t *testing.T) {
+	at := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		task TemplateTask
+		vars map[string]string
+		want TemplateInstance
+	}{
+		{
+			name: "variables",
+			task: TemplateTask{
+				TaskType:     KindBaseTask,
+				Title:        "Onboard {{ name }} on {{date}}",
+				Description:  "Team {{team}}",
+				Topic:        "hr",
+				Priority:     2,
+				Tags:         []string{"people"},
+				Checklist:    []string{"Laptop for {{name}}"},
+				AutoComplete: true,
+			},
+			vars: map[string]string{"name": "Alice", "date": "ignored"},
+			want: TemplateInstance{
+				TaskType: KindBaseTask,
+				Task: &BaseTask{
+					Title:       "Onboard Alice on 2024-05-01",
+					Description: "Team {{team}}",
+					Topic:       "hr",
+					Priority:    2,
+					Tags:        []string{"people"},
+					Checklist:   ChecklistProgress{AutoComplete: true},
+				},
+				Checklist: []string{"Laptop for Alice"},
+			},
+		},
+		{
+			name: "event",
+			task: TemplateTask{TaskType: KindEvent, Title: "Kickoff", StartOffset: 3600, Duration: 1800},
+			want: TemplateInstance{
+				TaskType: KindEvent,
+				Task: &Event{
+					BaseTask: BaseTask{Title: "Kickoff"},
+					StartsAt: at.Add(time.Hour),
+					EndsAt:   at.Add(90 * time.Minute),
+				},
+			},
+		},
+		{
+			name: "deadline before instantiation",
+			task: TemplateTask{TaskType: KindTaskWithDeadline, Title: "Prepare", DeadlineOffset: -86400},
+			want: TemplateInstance{
+				TaskType: KindTaskWithDeadline,
+				Task:     &TaskWithDeadline{BaseTask: BaseTask{Title: "Prepare"}, Deadline: at.Add(-24 * time.Hour)},
+			},
+		},
+		{
+			name: "repeating",
+			task: TemplateTask{TaskType: KindRepeatingTask, Title: "Standup", Duration: 900, Period: 86400, Loop: 5, Except: []int64{2}},
+			want: TemplateInstance{
+				TaskType: KindRepeatingTask,
+				Task: &RepeatingTask{
+					Event:  Event{BaseTask: BaseTask{Title: "Standup"}, StartsAt: at, EndsAt: at.Add(15 * time.Minute)},
+					Period: 86400,
+					Loop:   5,
+					Except: []int64{2},
+				},
+			},
+		},
+		{
+			name: "children",
+			task: TemplateTask{
+				TaskType: KindBaseTask,
+				Title:    "Release {{version}}",
+				Children: []TemplateTask{
+					{TaskType: KindTaskWithDeadline, Title: "Tag {{version}}", DeadlineOffset: 60},
+				},
+			},
+			vars: map[string]string{"version": "1.2"},
+			want: TemplateInstance{
+				TaskType: KindBaseTask,
+				Task:     &BaseTask{Title: "Release 1.2"},
+				Children: []TemplateInstance{{
+					TaskType: KindTaskWithDeadline,
+					Task:     &TaskWithDeadline{BaseTask: BaseTask{Title: "Tag 1.2"}, Deadline: at.Add(time.Minute)},
+				}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.task.Instantiate(at, tt.vars)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Instantiate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateVariables(t *testing.T) {
+	task := TemplateTask{
+		TaskType:  KindBaseTask,
+		Title:     "{{name}} starts on {{date}}",
+		Checklist: []string{"Badge for {{ name }}", "Desk in {{office}}"},
+		Children:  []TemplateTask{{TaskType: KindBaseTask, Title: "Meet {{buddy}}"}},
+	}
+
+	want := []string{"buddy", "name", "office"}
+	if got := task.Variables(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Variables() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,67 @@
+package tasks
+
+func (c *TopicCounts) add(o TopicCounts) {
+	c.Open += o.Open
+	c.Done += o.Done
+	c.Overdue += o.Overdue
+}
+
+// Fills Subtree counts of every topic. Topics are expected to form a forest
+func RollUpTopics(topics []TopicStats) {
+	index := make(map[int]int, len(topics))
+	for i, t := range topics {
+		index[t.ID] = i
+		topics[i].Subtree = t.TopicCounts
+	}
+
+	// Every topic adds own counts to all its ancestors
+	for _, t := range topics {
+		seen := map[int]bool{t.ID: true}
+		for p := t.ParentID; p != nil && !seen[*p]; {
+			i, ok := index[*p]
+			if !ok {
+				break
+			}
+			seen[*p] = true
+			topics[i].Subtree.add(t.TopicCounts)
+			p = topics[i].ParentID
+		}
+	}
+}
+
+// Nests topics into their parents keeping original order of siblings.
+// Topics whose parent is not among topics become roots
+func BuildTopicTree(topics []TopicStats) []TopicStats {
+	index := make(map[int]int, len(topics))
+	for i, t := range topics {
+		index[t.ID] = i
+	}
+
+	children := make([][]int, len(topics))
+	var roots []int
+	for i, t := range topics {
+		if t.ParentID != nil {
+			if p, ok := index[*t.ParentID]; ok && p != i {
+				children[p] = append(children[p], i)
+				continue
+			}
+		}
+		roots = append(roots, i)
+	}
+
+	var build func(i int) TopicStats
+	build = func(i int) TopicStats {
+		t := topics[i]
+		t.Children = nil
+		for _, c := range children[i] {
+			t.Children = append(t.Children, build(c))
+		}
+		return t
+	}
+
+	result := make([]TopicStats, 0, len(roots))
+	for _, i := range roots {
+		result = append(result, build(i))
+	}
+	return result
+}
@@ -0,0 +1,95 @@
+package tasks
+
+// Returns base of task of any kind, nil for unknown types
+func Base(task any) *BaseTask {
+	switch t := task.(type) {
+	case *BaseTask:
+		return t
+	case *Event:
+		return &t.BaseTask
+	case *TaskWithDeadline:
+		return &t.BaseTask
+	case *RepeatingTask:
+		return &t.BaseTask
+	}
+	return nil
+}
+
+func (n TaskNode) Ref() TaskRef {
+	return TaskRef{n.TaskType, Base(n.Task).ID}
+}
+
+// Flattens tasks of user into nodes
+func UserNodes(user *User) []TaskNode {
+	var nodes []TaskNode
+	for i := range user.BaseTasks {
+		nodes = append(nodes, TaskNode{TaskType: KindBaseTask, Task: &user.BaseTasks[i]})
+	}
+	for i := range user.Events {
+		nodes = append(nodes, TaskNode{TaskType: KindEvent, Task: &user.Events[i]})
+	}
+	for i := range user.TasksWithDeadline {
+		nodes = append(nodes, TaskNode{TaskType: KindTaskWithDeadline, Task: &user.TasksWithDeadline[i]})
+	}
+	for i := range user.RepeatingTasks {
+		nodes = append(nodes, TaskNode{TaskType: KindRepeatingTask, Task: &user.RepeatingTasks[i]})
+	}
+	return nodes
+}
+
+// Links nodes into forest by their parents keeping original order of siblings.
+// Nodes whose parent is not among nodes become roots.
+// Progress of every node with children is computed from given nodes only
+func BuildTree(nodes []TaskNode) []TaskNode {
+	index := make(map[TaskRef]int, len(nodes))
+	for i, n := range nodes {
+		index[n.Ref()] = i
+	}
+
+	children := make([][]int, len(nodes))
+	var roots []int
+	for i, n := range nodes {
+		parent := Base(n.Task).Parent()
+		if parent == nil {
+			roots = append(roots, i)
+			continue
+		}
+		if p, ok := index[*parent]; ok && p != i {
+			children[p] = append(children[p], i)
+		} else {
+			roots = append(roots, i)
+		}
+	}
+
+	// Returns node with its subtree and amounts of done and all descendants
+	var build func(i int) (TaskNode, int, int)
+	build = func(i int) (TaskNode, int, int) {
+		node := nodes[i]
+		node.Children = nil
+		var done, total int
+
+		for _, c := range children[i] {
+			child, childDone, childTotal := build(c)
+			node.Children = append(node.Children, child)
+
+			done += childDone
+			total += childTotal + 1
+			if Base(child.Task).Done {
+				done++
+			}
+		}
+
+		if total > 0 {
+			progress := float64(done) * 100 / float64(total)
+			node.Progress = &progress
+		}
+		return node, done, total
+	}
+
+	result := make([]TaskNode, 0, len(roots))
+	for _, i := range roots {
+		node, _, _ := build(i)
+		result = append(result, node)
+	}
+	return result
+}
@@ -0,0 +1,170 @@
+package tasks
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+const MaxStateName = 32
+
+var (
+	ErrInvalidState      = errors.New("Workflow states must have unique non-empty names")
+	ErrNoDoneState       = errors.New("Workflow must have both done and not done states")
+	ErrUnknownTransition = errors.New("Transitions must reference states of workflow")
+)
+
+type WorkflowState struct {
+	Name string `json:"name" yaml:"name"`
+	// Tasks in done states are considered done
+	Done bool `json:"done" yaml:"done"`
+}
+
+// States tasks move through and allowed moves between them
+type Workflow struct {
+	ID    int    `json:"id,omitempty" yaml:"-"`
+	Owner string `json:"owner,omitempty" yaml:"-"`
+	// Nil for default workflow of owner
+	TopicID *int `json:"topic_id,omitempty" yaml:"-"`
+	// In board order. First not done state is given to new tasks
+	States []WorkflowState `json:"states" yaml:"states"`
+	// Allowed target states of every state. States without entry can move to any state
+	Transitions map[string][]string `json:"transitions" yaml:"transitions"`
+}
+
+func (w *Workflow) Validate() error {
+	var hasDone, hasOpen bool
+	names := map[string]bool{}
+	for _, s := range w.States {
+		if s.Name == "" || len(s.Name) > MaxStateName || names[s.Name] {
+			return ErrInvalidState
+		}
+		names[s.Name] = true
+		hasDone = hasDone || s.Done
+		hasOpen = hasOpen || !s.Done
+	}
+	if !hasDone || !hasOpen {
+		return ErrNoDoneState
+	}
+
+	for from, targets := range w.Transitions {
+		if !names[from] {
+			return ErrUnknownTransition
+		}
+		for _, to := range targets {
+			if !names[to] {
+				return ErrUnknownTransition
+			}
+		}
+	}
+	return nil
+}
+
+func (w *Workflow) State(name string) (WorkflowState, bool) {
+	i := slices.IndexFunc(w.States, func(s WorkflowState) bool { return s.Name == name })
+	if i < 0 {
+		return WorkflowState{}, false
+	}
+	return w.States[i], true
+}
+
+// First not done state
+func (w *Workflow) Initial() string {
+	for _, s := range w.States {
+		if !s.Done {
+			return s.Name
+		}
+	}
+	return ""
+}
+
+// First done state, used when task is completed without explicit status
+func (w *Workflow) DoneState() string {
+	for _, s := range w.States {
+		if s.Done {
+			return s.Name
+		}
+	}
+	return ""
+}
+
+// Staying in the same state is always allowed. Tasks in states unknown to workflow,
+// e.g. after workflow change, can move to any state of it
+func (w *Workflow) CanTransition(from, to string) bool {
+	if _, ok := w.State(to); !ok {
+		return false
+	}
+	if from == to {
+		return true
+	}
+	if _, ok := w.State(from); !ok {
+		return true
+	}
+
+	targets, ok := w.Transitions[from]
+	return !ok || slices.Contains(targets, to)
+}
+
+// Moves task to status updating Done and time of change.
+// Done is kept if status is unknown to workflow
+func (t *BaseTask) SetStatus(w *Workflow, status string, now time.Time) {
+	if t.Status != status {
+		t.StatusChangedAt = now
+	}
+	t.Status = status
+	if state, ok := w.State(status); ok {
+		t.Done = state.Done
+	}
+}
+
+type Transition struct {
+	// Nil for status task was created with
+	From *string   `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+// Workflows of user: default one and ones of topics
+type Workflows struct {
+	Default *Workflow         `json:"default"`
+	ByTopic map[int]*Workflow `json:"topics"`
+}
+
+func (ws Workflows) For(topicID int) *Workflow {
+	if w, ok := ws.ByTopic[topicID]; ok {
+		return w
+	}
+	return ws.Default
+}
+
+type BoardColumn struct {
+	WorkflowState
+	Tasks []TaskNode `json:"tasks"`
+}
+
+// Groups tasks by status into columns of every state of workflow in order.
+// Statuses unknown to workflow get columns after them
+func BuildBoard(w *Workflow, nodes []TaskNode) []BoardColumn {
+	result := make([]BoardColumn, 0, len(w.States))
+	index := map[string]int{}
+	for _, s := range w.States {
+		index[s.Name] = len(result)
+		result = append(result, BoardColumn{WorkflowState: s, Tasks: []TaskNode{}})
+	}
+
+	for _, n := range nodes {
+		base := Base(n.Task)
+		i, ok := index[base.Status]
+		if !ok {
+			i = len(result)
+			index[base.Status] = i
+			result = append(result, BoardColumn{
+				WorkflowState: WorkflowState{Name: base.Status, Done: base.Done},
+				Tasks:         []TaskNode{},
+			})
+		}
+		result[i].Tasks = append(result[i].Tasks, n)
+	}
+
+	return result
+}
@@ -0,0 +1,109 @@
+package tasks
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWorkflowValidate(t *testing.T) {
+	states := []WorkflowState{{Name: "todo"}, {Name: "doing"}, {Name: "done", Done: true}}
+
+	tests := []struct {
+		name     string
+		workflow Workflow
+		want     error
+	}{
+		{
+			name:     "valid",
+			workflow: Workflow{States: states, Transitions: map[string][]string{"todo": {"doing"}, "doing": {"done", "todo"}}},
+			want:     nil,
+		},
+		{
+			name:     "without transitions",
+			workflow: Workflow{States: states},
+			want:     nil,
+		},
+		{
+			name:     "empty name",
+			workflow: Workflow{States: []WorkflowState{{Name: ""}, {Name: "done", Done: true}}},
+			want:     ErrInvalidState,
+		},
+		{
+			name:     "too long name",
+			workflow: Workflow{States: []WorkflowState{{Name: strings.Repeat("a", MaxStateName+1)}, {Name: "done", Done: true}}},
+			want:     ErrInvalidState,
+		},
+		{
+			name:     "duplicate name",
+			workflow: Workflow{States: []WorkflowState{{Name: "todo"}, {Name: "todo", Done: true}}},
+			want:     ErrInvalidState,
+		},
+		{
+			name:     "no done state",
+			workflow: Workflow{States: []WorkflowState{{Name: "todo"}, {Name: "doing"}}},
+			want:     ErrNoDoneState,
+		},
+		{
+			name:     "no open state",
+			workflow: Workflow{States: []WorkflowState{{Name: "done", Done: true}}},
+			want:     ErrNoDoneState,
+		},
+		{
+			name:     "unknown source",
+			workflow: Workflow{States: states, Transitions: map[string][]string{"review": {"done"}}},
+			want:     ErrUnknownTransition,
+		},
+		{
+			name:     "unknown target",
+			workflow: Workflow{States: states, Transitions: map[string][]string{"todo": {"review"}}},
+			want:     ErrUnknownTransition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.workflow.Validate(); got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkflowCanTransition(t *testing.T) {
+	w := Workflow{
+		States: []WorkflowState{{Name: "todo"}, {Name: "doing"}, {Name: "review"}, {Name: "done", Done: true}},
+		Transitions: map[string][]string{
+			"todo":   {"doing"},
+			"doing":  {"review", "todo"},
+			"review": {},
+		},
+	}
+
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"todo", "doing", true},
+		{"todo", "done", false},
+		{"doing", "review", true},
+		{"doing", "done", false},
+		// State without entry can move anywhere
+		{"done", "todo", true},
+		// Empty entry allows nothing but staying
+		{"review", "done", false},
+		{"review", "review", true},
+		{"todo", "todo", true},
+		// Unknown source, like after workflow change
+		{"archived", "review", true},
+		{"todo", "archived", false},
+		{"archived", "archived", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			if got := w.CanTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
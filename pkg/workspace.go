@@ -0,0 +1,38 @@
+package tasks
+
+import "time"
+
+// Roles of workspace members, every next one includes previous ones.
+// Owner is the only one who cannot be removed
+const (
+	RoleGuest  = "guest"
+	RoleMember = "member"
+	RoleAdmin  = "admin"
+	RoleOwner  = "owner"
+)
+
+var roleRanks = map[string]int{RoleGuest: 1, RoleMember: 2, RoleAdmin: 3, RoleOwner: 4}
+
+// Returns 0 for unknown roles
+func RoleRank(role string) int {
+	return roleRanks[role]
+}
+
+// Owns tasks and topics of its members. Every user has personal workspace
+// which holds tasks created without choosing workspace
+type Workspace struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner"`
+	Personal  bool      `json:"personal"`
+	CreatedAt time.Time `json:"created_at"`
+	// Role of user the workspace was loaded for
+	Role string `json:"role,omitempty"`
+}
+
+type WorkspaceMember struct {
+	WorkspaceID int       `json:"workspace_id"`
+	Username    string    `json:"username"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+}